@@ -0,0 +1,251 @@
+// Package approval keep the change requests which need to be approved by another user before applied.
+package approval
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Bucket is the bucket to save the change requests.
+const Bucket = "approval"
+
+// Status of the change request.
+const (
+	Pending  = "pending"
+	Approved = "approved"
+	Applied  = "applied"
+	Failed   = "failed"
+	Rejected = "rejected"
+	Canceled = "canceled"
+	Expired  = "expired"
+)
+
+var (
+	// ErrNotFound is returned if the change request not exist.
+	ErrNotFound = errors.New("change request not found")
+	// ErrNotPending is returned if the change request is reviewed or expired already.
+	ErrNotPending = errors.New("change request is not pending")
+	// ErrSelfApprove is returned if the requester approve the change request itself.
+	ErrSelfApprove = errors.New("change request could not be approved by the requester")
+)
+
+// Cluster is the store used by the change requests.
+type Cluster interface {
+	// Get returns the value for the given key.
+	View(bucket, key []byte) ([]byte, error)
+
+	// Set sets the value for the given key, via distributed consensus.
+	Update(bucket []byte, key []byte, value []byte) error
+
+	// ViewPrefix returns the value for the keys has the keyPrefix.
+	ViewPrefix(bucket, keyPrefix []byte) (map[string][]byte, error)
+
+	// Create a bucket via distributed consensus if not exist.
+	CreateBucketIfNotExist(name []byte) error
+}
+
+// Event is a step in the history of the change request.
+type Event struct {
+	Time    time.Time `json:"time"`
+	Actor   string    `json:"actor"`
+	Status  string    `json:"status"`
+	Comment string    `json:"comment,omitempty"`
+}
+
+// Request is a change waiting for approval, which is the HTTP request replayed once approved.
+type Request struct {
+	ID        string `json:"id"`
+	Status    string `json:"status"`
+	Requester string `json:"requester"`
+	Action    string `json:"action"`
+	NS        string `json:"ns,omitempty"`
+	Type      string `json:"type,omitempty"`
+	// Approvers is the groups whose members could approve the change.
+	Approvers []string `json:"approvers"`
+
+	Method string            `json:"method"`
+	URI    string            `json:"uri"`
+	Query  string            `json:"query,omitempty"`
+	Header map[string]string `json:"header,omitempty"`
+	Body   string            `json:"body,omitempty"`
+
+	CreatedAt time.Time `json:"createdat"`
+	ExpireAt  time.Time `json:"expireat"`
+	Reviewer  string    `json:"reviewer,omitempty"`
+	// Result is the response of the change applied.
+	Result  string  `json:"result,omitempty"`
+	History []Event `json:"history"`
+}
+
+func (r *Request) record(actor, status, comment string) {
+	r.Status = status
+	r.History = append(r.History, Event{Time: time.Now(), Actor: actor, Status: status, Comment: comment})
+}
+
+// Store save the change requests in the cluster.
+type Store struct {
+	mu      sync.Mutex
+	cluster Cluster
+}
+
+// New return the store of the change requests, create the bucket if not exist.
+func New(cluster Cluster) (*Store, error) {
+	if err := cluster.CreateBucketIfNotExist([]byte(Bucket)); err != nil {
+		return nil, err
+	}
+	return &Store{cluster: cluster}, nil
+}
+
+func genID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (s *Store) save(r Request) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return s.cluster.Update([]byte(Bucket), []byte(r.ID), b)
+}
+
+// Create save the pending change request, which expire after ttl.
+func (s *Store) Create(r Request, ttl time.Duration) (Request, error) {
+	id, err := genID()
+	if err != nil {
+		return r, err
+	}
+	r.ID = id
+	r.CreatedAt = time.Now()
+	r.ExpireAt = r.CreatedAt.Add(ttl)
+	r.History = nil
+	r.record(r.Requester, Pending, "")
+	return r, s.save(r)
+}
+
+// Get return the change request by ID.
+func (s *Store) Get(id string) (Request, error) {
+	r := Request{}
+	b, err := s.cluster.View([]byte(Bucket), []byte(id))
+	if err != nil {
+		return r, err
+	}
+	if len(b) == 0 {
+		return r, ErrNotFound
+	}
+	return r, json.Unmarshal(b, &r)
+}
+
+// List return the change requests of the status, all if empty, the latest first.
+func (s *Store) List(status string) ([]Request, error) {
+	reqMap, err := s.cluster.ViewPrefix([]byte(Bucket), []byte{})
+	if err != nil {
+		return nil, err
+	}
+	reqs := make([]Request, 0, len(reqMap))
+	for _, b := range reqMap {
+		if len(b) == 0 {
+			continue
+		}
+		var r Request
+		if err := json.Unmarshal(b, &r); err != nil {
+			return nil, err
+		}
+		if status == "" || r.Status == status {
+			reqs = append(reqs, r)
+		}
+	}
+	sort.Slice(reqs, func(i, j int) bool { return reqs[i].CreatedAt.After(reqs[j].CreatedAt) })
+	return reqs, nil
+}
+
+// review change the pending request to the status.
+func (s *Store) review(id, actor, status, comment string) (Request, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, err := s.Get(id)
+	if err != nil {
+		return r, err
+	}
+	if r.Status != Pending {
+		return r, ErrNotPending
+	}
+	if time.Now().After(r.ExpireAt) {
+		r.record("", Expired, "")
+		if err := s.save(r); err != nil {
+			return r, err
+		}
+		return r, ErrNotPending
+	}
+	if status == Approved {
+		if actor == r.Requester {
+			return r, ErrSelfApprove
+		}
+		r.Reviewer = actor
+	}
+	r.record(actor, status, comment)
+	return r, s.save(r)
+}
+
+// Approve approve the pending request by another user, the change should be applied then.
+func (s *Store) Approve(id, reviewer, comment string) (Request, error) {
+	return s.review(id, reviewer, Approved, comment)
+}
+
+// Reject reject the pending request.
+func (s *Store) Reject(id, reviewer, comment string) (Request, error) {
+	return s.review(id, reviewer, Rejected, comment)
+}
+
+// Cancel cancel the pending request by the requester.
+func (s *Store) Cancel(id, requester string) (Request, error) {
+	return s.review(id, requester, Canceled, "")
+}
+
+// Finish record the result of the approved request applied.
+func (s *Store) Finish(id string, ok bool, result string) (Request, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, err := s.Get(id)
+	if err != nil {
+		return r, err
+	}
+	status := Applied
+	if !ok {
+		status = Failed
+	}
+	r.Result = result
+	r.record(r.Reviewer, status, "")
+	return r, s.save(r)
+}
+
+// ExpireStale mark the pending requests expired, return the number expired.
+func (s *Store) ExpireStale() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reqs, err := s.List(Pending)
+	if err != nil {
+		return 0, err
+	}
+	expired := 0
+	now := time.Now()
+	for _, r := range reqs {
+		if now.Before(r.ExpireAt) {
+			continue
+		}
+		r.record("", Expired, "")
+		if err := s.save(r); err != nil {
+			return expired, err
+		}
+		expired++
+	}
+	return expired, nil
+}
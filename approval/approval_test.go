@@ -0,0 +1,127 @@
+package approval
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memCluster is the cluster in memory.
+type memCluster struct {
+	sync.Mutex
+	data map[string][]byte
+}
+
+func (c *memCluster) View(bucket, key []byte) ([]byte, error) {
+	c.Lock()
+	defer c.Unlock()
+	return c.data[string(key)], nil
+}
+
+func (c *memCluster) Update(bucket []byte, key []byte, value []byte) error {
+	c.Lock()
+	defer c.Unlock()
+	c.data[string(key)] = value
+	return nil
+}
+
+func (c *memCluster) ViewPrefix(bucket, keyPrefix []byte) (map[string][]byte, error) {
+	c.Lock()
+	defer c.Unlock()
+	result := map[string][]byte{}
+	for k, v := range c.data {
+		if strings.HasPrefix(k, string(keyPrefix)) {
+			result[k] = v
+		}
+	}
+	return result, nil
+}
+
+func (c *memCluster) CreateBucketIfNotExist(name []byte) error { return nil }
+
+func newStore(t *testing.T) *Store {
+	s, err := New(&memCluster{data: map[string][]byte{}})
+	if err != nil {
+		t.Fatal("New fail:", err)
+	}
+	return s
+}
+
+func TestApprove(t *testing.T) {
+	s := newStore(t)
+	r, err := s.Create(Request{Requester: "user1", Action: "ns.delete", NS: "product0.loda", Method: "DELETE",
+		URI: "/api/v1/ns", Query: "ns=product0.loda", Approvers: []string{"loda-admin"}}, time.Hour)
+	if err != nil || r.ID == "" || r.Status != Pending || len(r.History) != 1 {
+		t.Fatalf("Create not match expect: %+v %v", r, err)
+	}
+
+	if _, err := s.Approve(r.ID, "user1", ""); err != ErrSelfApprove {
+		t.Fatalf("requester should not approve the request itself, got %v", err)
+	}
+	if r, err = s.Approve(r.ID, "user2", "lgtm"); err != nil || r.Status != Approved || r.Reviewer != "user2" {
+		t.Fatalf("Approve not match expect: %+v %v", r, err)
+	}
+	if _, err := s.Reject(r.ID, "user3", ""); err != ErrNotPending {
+		t.Fatalf("reviewed request should not be reviewed again, got %v", err)
+	}
+	if r, err = s.Finish(r.ID, true, "200 success"); err != nil || r.Status != Applied || r.Result != "200 success" {
+		t.Fatalf("Finish not match expect: %+v %v", r, err)
+	}
+
+	r, err = s.Get(r.ID)
+	if err != nil || len(r.History) != 3 || r.History[1].Actor != "user2" || r.History[1].Comment != "lgtm" ||
+		r.History[2].Status != Applied {
+		t.Fatalf("history not match expect: %+v %v", r.History, err)
+	}
+	if _, err := s.Get("unknown"); err != ErrNotFound {
+		t.Fatalf("unknown request should be not found, got %v", err)
+	}
+}
+
+func TestRejectAndCancel(t *testing.T) {
+	s := newStore(t)
+	r1, _ := s.Create(Request{Requester: "user1", Action: "ns.delete"}, time.Hour)
+	r2, _ := s.Create(Request{Requester: "user1", Action: "ns.trash.update"}, time.Hour)
+
+	if r, err := s.Reject(r1.ID, "user2", "not now"); err != nil || r.Status != Rejected {
+		t.Fatalf("Reject not match expect: %+v %v", r, err)
+	}
+	if r, err := s.Cancel(r2.ID, "user1"); err != nil || r.Status != Canceled {
+		t.Fatalf("Cancel not match expect: %+v %v", r, err)
+	}
+	if _, err := s.Approve(r2.ID, "user2", ""); err != ErrNotPending {
+		t.Fatalf("canceled request should not be approved, got %v", err)
+	}
+
+	if reqs, err := s.List(""); err != nil || len(reqs) != 2 || reqs[0].ID != r2.ID {
+		t.Fatalf("List not match expect: %+v %v", reqs, err)
+	}
+	if reqs, err := s.List(Rejected); err != nil || len(reqs) != 1 || reqs[0].ID != r1.ID {
+		t.Fatalf("List of status not match expect: %+v %v", reqs, err)
+	}
+}
+
+func TestExpireStale(t *testing.T) {
+	s := newStore(t)
+	stale, _ := s.Create(Request{Requester: "user1"}, -time.Minute)
+	fresh, _ := s.Create(Request{Requester: "user1"}, time.Hour)
+
+	if expired, err := s.ExpireStale(); err != nil || expired != 1 {
+		t.Fatalf("ExpireStale not match expect: %d %v", expired, err)
+	}
+	if r, _ := s.Get(stale.ID); r.Status != Expired {
+		t.Fatalf("stale request should be expired: %+v", r)
+	}
+	if r, _ := s.Get(fresh.ID); r.Status != Pending {
+		t.Fatalf("fresh request should be pending: %+v", r)
+	}
+
+	stale, _ = s.Create(Request{Requester: "user1"}, -time.Minute)
+	if _, err := s.Approve(stale.ID, "user2", ""); err != ErrNotPending {
+		t.Fatalf("stale request should not be approved, got %v", err)
+	}
+	if r, _ := s.Get(stale.ID); r.Status != Expired {
+		t.Fatalf("stale request should be expired once reviewed: %+v", r)
+	}
+}
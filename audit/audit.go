@@ -0,0 +1,260 @@
+// Package audit record who changed what in the registry, kept in the audit bucket until expired.
+package audit
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	m "github.com/lodastack/store/model"
+)
+
+const (
+	// Bucket is the bucket to save the audit entries.
+	Bucket = "audit"
+
+	// DefaultLimit is the default max number of entries a query return.
+	DefaultLimit = 100
+
+	// untilSkew is added to now if the query has no Until, for the entries of the node whose clock is ahead.
+	untilSkew = time.Minute
+)
+
+// Cluster is the store used by the audit log.
+type Cluster interface {
+	// Batch update values for given keys in given buckets, via distributed consensus.
+	Batch(rows []m.Row) error
+
+	// ViewPrefix returns the value for the keys has the keyPrefix.
+	ViewPrefix(bucket, keyPrefix []byte) (map[string][]byte, error)
+
+	// Create a bucket via distributed consensus if not exist.
+	CreateBucketIfNotExist(name []byte) error
+
+	// RemoveKey removes the key from the bucket.
+	RemoveKey(bucket, key []byte) error
+}
+
+// Entry is a change of the registry.
+// Before and After is the value of the target, empty if not exist.
+type Entry struct {
+	ID         string            `json:"id"`
+	Time       time.Time         `json:"time"`
+	Actor      string            `json:"actor"`
+	IP         string            `json:"ip"`
+	Method     string            `json:"method"`
+	URI        string            `json:"uri"`
+	Action     string            `json:"action"`
+	Status     int               `json:"status"`
+	NS         string            `json:"ns,omitempty"`
+	Type       string            `json:"type,omitempty"`
+	ResourceID string            `json:"resourceid,omitempty"`
+	Params     map[string]string `json:"params,omitempty"`
+	Before     json.RawMessage   `json:"before,omitempty"`
+	After      json.RawMessage   `json:"after,omitempty"`
+}
+
+// key is ordered by time, so the entries are listed in order.
+func (e Entry) key() []byte {
+	return []byte(fmt.Sprintf("%019d-%s", e.Time.UnixNano(), e.ID))
+}
+
+// Query filter the entries, the empty field is not filtered.
+// NS match the ns and its children, Action ending with * match the prefix.
+type Query struct {
+	Actor  string
+	NS     string
+	Action string
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+}
+
+// Match return whether the entry match the query.
+func (q Query) Match(e Entry) bool {
+	if q.Actor != "" && e.Actor != q.Actor {
+		return false
+	}
+	if q.NS != "" && e.NS != q.NS && !strings.HasSuffix(e.NS, "."+q.NS) {
+		return false
+	}
+	if q.Action != "" {
+		if prefix := strings.TrimSuffix(q.Action, "*"); prefix != q.Action {
+			if !strings.HasPrefix(e.Action, prefix) {
+				return false
+			}
+		} else if e.Action != q.Action {
+			return false
+		}
+	}
+	if !q.Since.IsZero() && e.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && e.Time.After(q.Until) {
+		return false
+	}
+	return true
+}
+
+// Log is the audit log saved in the cluster.
+type Log struct {
+	cluster Cluster
+}
+
+// New return the audit log, create the bucket if not exist.
+func New(cluster Cluster) (*Log, error) {
+	if err := cluster.CreateBucketIfNotExist([]byte(Bucket)); err != nil {
+		return nil, err
+	}
+	return &Log{cluster: cluster}, nil
+}
+
+func genID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Record save the entries in one batch, the ID and time are set if empty.
+func (l *Log) Record(entries ...Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	rows := make([]m.Row, 0, len(entries))
+	for _, e := range entries {
+		if e.ID == "" {
+			id, err := genID()
+			if err != nil {
+				return err
+			}
+			e.ID = id
+		}
+		if e.Time.IsZero() {
+			e.Time = time.Now()
+		}
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, m.Row{Bucket: []byte(Bucket), Key: e.key(), Value: b})
+	}
+	return l.cluster.Batch(rows)
+}
+
+// keyPrefixes return the key prefixes cover the time in nanoseconds between from and to exactly,
+// the latest first, so that the entries could be scanned by range.
+func keyPrefixes(from, to int64) []string {
+	if from < 0 {
+		from = 0
+	}
+	if from > to {
+		return nil
+	}
+	low, high := fmt.Sprintf("%019d", from), fmt.Sprintf("%019d", to)
+	prefixes := []string{}
+	var cover func(prefix string, lowTight, highTight bool)
+	cover = func(prefix string, lowTight, highTight bool) {
+		// the bound is not tight if the rest of it is the min or max.
+		lowTight = lowTight && strings.Trim(low[len(prefix):], "0") != ""
+		highTight = highTight && strings.Trim(high[len(prefix):], "9") != ""
+		if (!lowTight && !highTight) || len(prefix) == len(high) {
+			prefixes = append(prefixes, prefix)
+			return
+		}
+		lo, hi := byte('0'), byte('9')
+		if lowTight {
+			lo = low[len(prefix)]
+		}
+		if highTight {
+			hi = high[len(prefix)]
+		}
+		for d := hi; d >= lo; d-- {
+			cover(prefix+string(d), lowTight && d == lo, highTight && d == hi)
+		}
+	}
+	cover("", true, true)
+	return prefixes
+}
+
+// view return the entries of the key prefix, the latest first.
+func (l *Log) view(prefix string) ([]Entry, error) {
+	entryMap, err := l.cluster.ViewPrefix([]byte(Bucket), []byte(prefix))
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(entryMap))
+	for k, v := range entryMap {
+		if len(v) != 0 {
+			keys = append(keys, k)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+	entries := make([]Entry, 0, len(keys))
+	for _, k := range keys {
+		var e Entry
+		if err := json.Unmarshal(entryMap[k], &e); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// Query return the entries match the query, the latest first.
+// The entries are scanned by time from Until back to Since, and stop once the limit reached.
+func (l *Log) Query(q Query) ([]Entry, error) {
+	if q.Limit <= 0 {
+		q.Limit = DefaultLimit
+	}
+	from, to := int64(0), time.Now().Add(untilSkew).UnixNano()
+	if !q.Since.IsZero() {
+		from = q.Since.UnixNano()
+	}
+	if !q.Until.IsZero() {
+		to = q.Until.UnixNano()
+	}
+	result := []Entry{}
+	for _, prefix := range keyPrefixes(from, to) {
+		entries, err := l.view(prefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if !q.Match(e) {
+				continue
+			}
+			result = append(result, e)
+			if len(result) >= q.Limit {
+				return result, nil
+			}
+		}
+	}
+	return result, nil
+}
+
+// Purge remove the entries before the time, return the number removed.
+// Only the entries before the time are scanned, the blank keys left by the old purge are removed but not counted.
+func (l *Log) Purge(before time.Time) (int, error) {
+	purged := 0
+	for _, prefix := range keyPrefixes(0, before.UnixNano()-1) {
+		entryMap, err := l.cluster.ViewPrefix([]byte(Bucket), []byte(prefix))
+		if err != nil {
+			return purged, err
+		}
+		for k, v := range entryMap {
+			if err := l.cluster.RemoveKey([]byte(Bucket), []byte(k)); err != nil {
+				return purged, err
+			}
+			if len(v) != 0 {
+				purged++
+			}
+		}
+	}
+	return purged, nil
+}
@@ -0,0 +1,137 @@
+package audit
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	m "github.com/lodastack/store/model"
+)
+
+// memCluster is the cluster in memory, the empty value is kept as the store do.
+// viewed is the number of entries read.
+type memCluster struct {
+	sync.Mutex
+	data   map[string][]byte
+	viewed int
+}
+
+func (c *memCluster) Batch(rows []m.Row) error {
+	c.Lock()
+	defer c.Unlock()
+	for _, row := range rows {
+		c.data[string(row.Key)] = row.Value
+	}
+	return nil
+}
+
+func (c *memCluster) ViewPrefix(bucket, keyPrefix []byte) (map[string][]byte, error) {
+	c.Lock()
+	defer c.Unlock()
+	result := map[string][]byte{}
+	for k, v := range c.data {
+		if strings.HasPrefix(k, string(keyPrefix)) {
+			result[k] = v
+			c.viewed++
+		}
+	}
+	return result, nil
+}
+
+func (c *memCluster) CreateBucketIfNotExist(name []byte) error { return nil }
+
+func (c *memCluster) RemoveKey(bucket, key []byte) error {
+	c.Lock()
+	defer c.Unlock()
+	delete(c.data, string(key))
+	return nil
+}
+
+func TestLog(t *testing.T) {
+	c := &memCluster{data: map[string][]byte{}}
+	l, err := New(c)
+	if err != nil {
+		t.Fatal("New fail:", err)
+	}
+	now := time.Now()
+	err = l.Record(
+		Entry{Time: now.Add(-48 * time.Hour), Actor: "user1", NS: "server0.product0.loda", Action: "resource.create"},
+		Entry{Time: now.Add(-time.Hour), Actor: "user2", NS: "product0.loda", Action: "resource.update"},
+		Entry{Time: now, Actor: "user1", NS: "product1.loda", Action: "ns.delete", Before: []byte(`{"name":"product1"}`)},
+	)
+	if err != nil {
+		t.Fatal("Record fail:", err)
+	}
+
+	for _, c := range []struct {
+		q      Query
+		expect []string
+	}{
+		{Query{}, []string{"ns.delete", "resource.update", "resource.create"}},
+		{Query{Actor: "user1"}, []string{"ns.delete", "resource.create"}},
+		{Query{NS: "product0.loda"}, []string{"resource.update", "resource.create"}},
+		{Query{Action: "resource.*"}, []string{"resource.update", "resource.create"}},
+		{Query{Action: "resource"}, []string{}},
+		{Query{Since: now.Add(-2 * time.Hour)}, []string{"ns.delete", "resource.update"}},
+		{Query{Until: now.Add(-2 * time.Hour)}, []string{"resource.create"}},
+		{Query{Limit: 1}, []string{"ns.delete"}},
+	} {
+		entries, err := l.Query(c.q)
+		if err != nil {
+			t.Fatal("Query fail:", err)
+		}
+		actions := []string{}
+		for _, e := range entries {
+			if e.ID == "" {
+				t.Fatalf("ID of entry should be set: %+v", e)
+			}
+			actions = append(actions, e.Action)
+		}
+		if strings.Join(actions, ",") != strings.Join(c.expect, ",") {
+			t.Fatalf("Query %+v not match expect: %v", c.q, actions)
+		}
+	}
+
+	// only the entries in the time range are read.
+	c.viewed = 0
+	if entries, err := l.Query(Query{Limit: 1}); err != nil || len(entries) != 1 || c.viewed != 1 {
+		t.Fatalf("Query should stop once the limit reached: %d %+v %v", c.viewed, entries, err)
+	}
+	c.viewed = 0
+	if entries, err := l.Query(Query{Until: now.Add(-2 * time.Hour)}); err != nil || len(entries) != 1 || c.viewed != 1 {
+		t.Fatalf("Query should only read the entries until the time: %d %+v %v", c.viewed, entries, err)
+	}
+
+	c.viewed = 0
+	purged, err := l.Purge(now.Add(-24 * time.Hour))
+	if err != nil || purged != 1 || c.viewed != 1 {
+		t.Fatalf("Purge not match expect: %d %d %v", purged, c.viewed, err)
+	}
+	if entries, _ := l.Query(Query{}); len(entries) != 2 || len(c.data) != 2 {
+		t.Fatalf("expired entry should be purged: %+v %d", entries, len(c.data))
+	}
+
+	// the blank key left by the old purge is removed, but not counted again.
+	blank := Entry{Time: now.Add(-72 * time.Hour), ID: "blank"}
+	c.data[string(blank.key())] = []byte{}
+	if purged, err := l.Purge(now.Add(-24 * time.Hour)); err != nil || purged != 0 || len(c.data) != 2 {
+		t.Fatalf("Purge blank key not match expect: %d %d %v", purged, len(c.data), err)
+	}
+}
+
+func TestKeyPrefixes(t *testing.T) {
+	for _, c := range []struct {
+		from, to int64
+		expect   string
+	}{
+		{120, 139, "000000000000000013,000000000000000012"},
+		{118, 131, "0000000000000000131,0000000000000000130,000000000000000012,0000000000000000119,0000000000000000118"},
+		{100, 199, "00000000000000001"},
+		{135, 120, ""},
+	} {
+		if prefixes := strings.Join(keyPrefixes(c.from, c.to), ","); prefixes != c.expect {
+			t.Fatalf("keyPrefixes of %d-%d not match expect: %s", c.from, c.to, prefixes)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// Package authenticate verify who the user is by a chain of providers,
+// e.g. local password, LDAP, Wework, OpenID Connect and static tokens.
+// What the user could do is decided by package authorize.
+package authenticate
+
+import (
+	"errors"
+
+	"github.com/lodastack/registry/config"
+	"github.com/lodastack/registry/oidc"
+)
+
+var (
+	// ErrNotSupported is returned if no provider could verify the credential.
+	ErrNotSupported = errors.New("credential not supported by the provider")
+	// ErrUserNotFound is returned by the provider which does not know the user.
+	ErrUserNotFound = errors.New("user not found")
+	// ErrInvalidCredential is returned if the credential is wrong.
+	ErrInvalidCredential = errors.New("invalid username or password")
+)
+
+// Credential is what the user present to signin, each provider use the part it supports.
+// Provider is set to use the named provider only.
+type Credential struct {
+	Provider string
+
+	// password signin.
+	Username string
+	Password string
+
+	// code of the third party signin, and the PKCE verifier and nonce of OpenID Connect.
+	Code     string
+	Verifier string
+	Nonce    string
+
+	// static token.
+	Token string
+}
+
+// Identity is the user verified by the provider.
+// Groups is the registry groups the provider say the user belongs to,
+// only the membership of the Managed groups follow it.
+type Identity struct {
+	Provider string
+	Username string
+	Groups   []string
+	Managed  []string
+}
+
+// Authenticator is a provider to verify the credential.
+type Authenticator interface {
+	// Name return the name of the provider.
+	Name() string
+
+	// Supports return whether the provider could verify the credential.
+	Supports(c Credential) bool
+
+	// Authenticate return the identity of the credential,
+	// ErrUserNotFound if the provider does not know the user.
+	Authenticate(c Credential) (Identity, error)
+}
+
+// UserLookup is the provider could tell whether a user exist.
+type UserLookup interface {
+	UserExist(username string) (bool, error)
+}
+
+// Chain try the providers in order until one verify the credential.
+type Chain []Authenticator
+
+// Authenticate return the identity of the first provider verify the credential.
+// ErrNotSupported is returned if no provider supports the credential.
+func (c Chain) Authenticate(cred Credential) (Identity, error) {
+	var lastErr error
+	supported := false
+	for _, a := range c {
+		if (cred.Provider != "" && cred.Provider != a.Name()) || !a.Supports(cred) {
+			continue
+		}
+		supported = true
+		id, err := a.Authenticate(cred)
+		if err == nil {
+			id.Provider = a.Name()
+			return id, nil
+		}
+		if err != ErrUserNotFound {
+			lastErr = err
+		}
+	}
+	if !supported {
+		return Identity{}, ErrNotSupported
+	}
+	if lastErr == nil {
+		lastErr = ErrInvalidCredential
+	}
+	return Identity{}, lastErr
+}
+
+// UserExist return whether any provider know the user.
+func (c Chain) UserExist(username string) bool {
+	for _, a := range c {
+		lookup, ok := a.(UserLookup)
+		if !ok {
+			continue
+		}
+		if exist, err := lookup.UserExist(username); err == nil && exist {
+			return true
+		}
+	}
+	return false
+}
+
+// Get return the provider by name.
+func (c Chain) Get(name string) (Authenticator, bool) {
+	for _, a := range c {
+		if a.Name() == name {
+			return a, true
+		}
+	}
+	return nil, false
+}
+
+// New return the chain of the providers enabled by the config, in order of
+// static token, local password, LDAP, Wework and OpenID Connect.
+func New(c config.Config, store PasswordStore) Chain {
+	chain := Chain{}
+	if len(c.AuthConf.StaticTokens) != 0 {
+		chain = append(chain, NewStatic(c.AuthConf.StaticTokens))
+	}
+	if c.AuthConf.Local {
+		chain = append(chain, NewLocal(store))
+	}
+	if c.LDAPConf.Enable {
+		chain = append(chain, NewLDAP(c.LDAPConf))
+	}
+	if c.WeworkConf.Enable {
+		chain = append(chain, NewWework(c.WeworkConf))
+	}
+	if c.OIDCConf.Enable {
+		for _, conf := range c.OIDCConf.Providers {
+			chain = append(chain, NewOIDC(oidc.NewProvider(conf)))
+		}
+	}
+	return chain
+}
+
+// Enforced return whether the API should be authenticated,
+// which is true if any provider except Wework is enabled.
+func Enforced(c config.Config) bool {
+	return len(c.AuthConf.StaticTokens) != 0 || c.AuthConf.Local || c.LDAPConf.Enable || c.OIDCConf.Enable
+}
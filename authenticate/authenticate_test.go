@@ -0,0 +1,249 @@
+package authenticate
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-ldap/ldap"
+	"github.com/lodastack/registry/common"
+	"github.com/lodastack/registry/config"
+)
+
+type fakeStore map[string]string
+
+func (s fakeStore) PasswordHash(username string) (string, error) { return s[username], nil }
+
+func (s fakeStore) SetPasswordHash(username, hash string) error {
+	s[username] = hash
+	return nil
+}
+
+// fakeLDAP has the password of the users by dn, the password of the bind user is "bind".
+// The entries are the attributes by dn, the filter could only be (attr=value).
+type fakeLDAP struct {
+	users   map[string]string
+	entries map[string]map[string][]string
+}
+
+func (f *fakeLDAP) Bind(username, password string) error {
+	if username == "cn=bind" && password == "bind" {
+		return nil
+	}
+	if pass, ok := f.users[username]; ok && pass == password {
+		return nil
+	}
+	return errors.New("invalid credentials")
+}
+
+func (f *fakeLDAP) Search(req *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	newEntry := func(dn string, attrs map[string][]string) *ldap.Entry {
+		e := &ldap.Entry{DN: dn}
+		for _, name := range req.Attributes {
+			if v, ok := attrs[name]; ok {
+				e.Attributes = append(e.Attributes, &ldap.EntryAttribute{Name: name, Values: v})
+			}
+		}
+		return e
+	}
+	sr := &ldap.SearchResult{}
+	if req.Scope == ldap.ScopeBaseObject {
+		attrs, ok := f.entries[req.BaseDN]
+		if !ok {
+			return nil, ldap.NewError(ldap.LDAPResultNoSuchObject, errors.New("no such object"))
+		}
+		sr.Entries = append(sr.Entries, newEntry(req.BaseDN, attrs))
+		return sr, nil
+	}
+	kv := strings.SplitN(strings.Trim(req.Filter, "()"), "=", 2)
+	for dn, attrs := range f.entries {
+		if _, ok := common.ContainString(attrs[kv[0]], kv[1]); ok {
+			sr.Entries = append(sr.Entries, newEntry(dn, attrs))
+		}
+	}
+	return sr, nil
+}
+
+func (f *fakeLDAP) Close() {}
+
+// newFakeLDAP return the LDAP provider of the users, the password is by uid.
+func newFakeLDAP(users map[string]string, entries map[string]map[string][]string) *LDAP {
+	f := &fakeLDAP{users: map[string]string{}, entries: map[string]map[string][]string{}}
+	for uid, pass := range users {
+		f.users["uid="+uid] = pass
+		f.entries["uid="+uid] = map[string][]string{"uid": {uid}}
+	}
+	for dn, attrs := range entries {
+		f.entries[dn] = attrs
+	}
+	l := NewLDAP(config.LDAPConfig{Binddn: "cn=bind", Password: "bind", UID: "uid"})
+	l.dial = func() (ldapConn, error) { return f, nil }
+	return l
+}
+
+func TestLocal(t *testing.T) {
+	store := fakeStore{}
+	l := NewLocal(store)
+	if err := l.SetPassword("user1", "pass1"); err != nil {
+		t.Fatal("SetPassword fail:", err)
+	}
+	if store["user1"] == "" || store["user1"] == "pass1" {
+		t.Fatalf("password not hashed: %q", store["user1"])
+	}
+
+	if id, err := l.Authenticate(Credential{Username: "user1", Password: "pass1"}); err != nil || id.Username != "user1" {
+		t.Fatalf("Authenticate not match expect: %+v %v", id, err)
+	}
+	if _, err := l.Authenticate(Credential{Username: "user1", Password: "wrong"}); err != ErrInvalidCredential {
+		t.Fatalf("wrong password should be invalid, got %v", err)
+	}
+	if _, err := l.Authenticate(Credential{Username: "user2", Password: "pass1"}); err != ErrUserNotFound {
+		t.Fatalf("unknown user should be not found, got %v", err)
+	}
+	if exist, _ := l.UserExist("user1"); !exist {
+		t.Fatal("user1 should exist")
+	}
+}
+
+func TestLDAP(t *testing.T) {
+	l := newFakeLDAP(map[string]string{"user1": "pass1"}, nil)
+	if id, err := l.Authenticate(Credential{Username: "user1", Password: "pass1"}); err != nil || id.Username != "user1" {
+		t.Fatalf("Authenticate not match expect: %+v %v", id, err)
+	}
+	if _, err := l.Authenticate(Credential{Username: "user1", Password: "wrong"}); err != ErrInvalidCredential {
+		t.Fatalf("wrong password should be invalid, got %v", err)
+	}
+	if _, err := l.Authenticate(Credential{Username: "user2", Password: "pass1"}); err != ErrUserNotFound {
+		t.Fatalf("unknown user should be not found, got %v", err)
+	}
+	if exist, err := l.UserExist("user1"); err != nil || !exist {
+		t.Fatalf("user1 should exist: %v", err)
+	}
+	if exist, err := l.UserExist("user2"); err != nil || exist {
+		t.Fatalf("user2 should not exist: %v", err)
+	}
+}
+
+func TestLDAPGroupMembers(t *testing.T) {
+	l := newFakeLDAP(nil, map[string]map[string][]string{
+		"uid=user1":   {"uid": {"User1"}, "mobile": {"123"}, "mail": {"user1@loda"}, "dept": {"ops"}},
+		"uid=user2":   {"uid": {"user2"}, "dept": {"dev"}},
+		"cn=ops":      {"member": {"uid=user1", "cn=nested", "uid=left"}},
+		"cn=nested":   {"member": {"uid=user2"}},
+		"cn=empty":    {},
+		"uid=nothing": {"dept": {"ops"}},
+	})
+
+	users, err := l.GroupMembers(config.LDAPGroupConfig{Group: "ops-loda", DN: "cn=ops"})
+	if err != nil {
+		t.Fatal("GroupMembers fail:", err)
+	}
+	if len(users) != 1 || users[0] != (LDAPUser{Username: "user1", Mobile: "123", Mail: "user1@loda"}) {
+		t.Fatalf("GroupMembers of dn not match expect: %+v", users)
+	}
+	users, err = l.GroupMembers(config.LDAPGroupConfig{Group: "ops-loda", Filter: "(dept=ops)"})
+	if err != nil || len(users) != 1 || users[0].Username != "user1" {
+		t.Fatalf("GroupMembers of filter not match expect: %+v %v", users, err)
+	}
+	if users, err = l.GroupMembers(config.LDAPGroupConfig{Group: "ops-loda", DN: "cn=empty"}); err != nil || len(users) != 0 {
+		t.Fatalf("GroupMembers of empty group not match expect: %+v %v", users, err)
+	}
+	if _, err = l.GroupMembers(config.LDAPGroupConfig{Group: "ops-loda", DN: "cn=unknown"}); err == nil {
+		t.Fatal("GroupMembers of unknown group should fail")
+	}
+}
+
+func TestWework(t *testing.T) {
+	tokenQueries := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/gettoken":
+			tokenQueries++
+			w.Write([]byte(`{"access_token":"token1","expires_in":7200}`))
+		case "/user/getuserinfo":
+			if r.FormValue("access_token") != "token1" || r.FormValue("code") != "code1" {
+				w.Write([]byte(`{"errcode":40029,"errmsg":"invalid code"}`))
+				return
+			}
+			w.Write([]byte(`{"errcode":0,"UserId":"user1"}`))
+		}
+	}))
+	defer ts.Close()
+
+	ww := NewWework(config.WeworkConfig{Enable: true})
+	ww.api = ts.URL
+	if ww.Supports(Credential{Code: "code1"}) {
+		t.Fatal("wework should only support the credential of its provider")
+	}
+	if id, err := ww.Authenticate(Credential{Provider: WeworkName, Code: "code1"}); err != nil || id.Username != "user1" {
+		t.Fatalf("Authenticate not match expect: %+v %v", id, err)
+	}
+	if _, err := ww.Authenticate(Credential{Provider: WeworkName, Code: "code2"}); err == nil {
+		t.Fatal("invalid code should fail")
+	}
+	if tokenQueries != 1 {
+		t.Fatalf("access token should be cached, queried %d times", tokenQueries)
+	}
+}
+
+func TestChain(t *testing.T) {
+	store := fakeStore{}
+	local := NewLocal(store)
+	local.SetPassword("user1", "local")
+	local.SetPassword("user2", "local")
+	chain := Chain{
+		NewStatic(map[string]string{"secret1": "robot"}),
+		local,
+		newFakeLDAP(map[string]string{"user2": "ldap", "user3": "ldap"}, nil),
+	}
+
+	for _, c := range []struct {
+		cred Credential
+		user string
+		from string
+		err  error
+	}{
+		{Credential{Username: "user1", Password: "local"}, "user1", LocalName, nil},
+		// the password of the local user is wrong, but LDAP verify it.
+		{Credential{Username: "user2", Password: "ldap"}, "user2", LDAPName, nil},
+		{Credential{Username: "user3", Password: "ldap"}, "user3", LDAPName, nil},
+		{Credential{Username: "user3", Password: "wrong"}, "", "", ErrInvalidCredential},
+		{Credential{Username: "user4", Password: "wrong"}, "", "", ErrInvalidCredential},
+		{Credential{Provider: LocalName, Username: "user3", Password: "ldap"}, "", "", ErrInvalidCredential},
+		{Credential{Token: "secret1"}, "robot", StaticName, nil},
+		{Credential{Token: "secret2"}, "", "", ErrInvalidCredential},
+		{Credential{Provider: WeworkName, Code: "code"}, "", "", ErrNotSupported},
+	} {
+		id, err := chain.Authenticate(c.cred)
+		if err != c.err || id.Username != c.user || id.Provider != c.from {
+			t.Fatalf("Authenticate %+v not match expect: %+v %v", c.cred, id, err)
+		}
+	}
+
+	if !chain.UserExist("user1") || !chain.UserExist("user3") || chain.UserExist("user4") {
+		t.Fatal("UserExist not match expect")
+	}
+	if _, ok := chain.Get(LDAPName); !ok {
+		t.Fatal("Get ldap fail")
+	}
+}
+
+func TestNew(t *testing.T) {
+	c := config.Config{}
+	if chain := New(c, fakeStore{}); len(chain) != 0 || Enforced(c) {
+		t.Fatalf("no provider should be enabled: %v", chain)
+	}
+
+	c.AuthConf.Local = true
+	c.AuthConf.StaticTokens = map[string]string{"secret1": "robot"}
+	c.WeworkConf.Enable = true
+	chain := New(c, fakeStore{})
+	if len(chain) != 3 || chain[0].Name() != StaticName || chain[1].Name() != LocalName || chain[2].Name() != WeworkName {
+		t.Fatalf("chain not match expect: %v", chain)
+	}
+	if !Enforced(c) {
+		t.Fatal("local password should enforce the authentication")
+	}
+}
@@ -0,0 +1,204 @@
+package authenticate
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-ldap/ldap"
+	"github.com/lodastack/registry/config"
+)
+
+// LDAPName is the name of the LDAP provider.
+const LDAPName = "ldap"
+
+// ldapConn is the LDAP connection used by the provider, replaced by fakes in test.
+type ldapConn interface {
+	Bind(username, password string) error
+	Search(searchRequest *ldap.SearchRequest) (*ldap.SearchResult, error)
+	Close()
+}
+
+// LDAP verify the password by binding as the user.
+type LDAP struct {
+	conf config.LDAPConfig
+	dial func() (ldapConn, error)
+}
+
+// NewLDAP return the LDAP provider of the config.
+func NewLDAP(conf config.LDAPConfig) *LDAP {
+	return &LDAP{conf: conf, dial: func() (ldapConn, error) {
+		return ldap.Dial("tcp", conf.Server)
+	}}
+}
+
+// Name return the name of the provider.
+func (l *LDAP) Name() string { return LDAPName }
+
+// Supports return whether the credential has username and password.
+func (l *LDAP) Supports(c Credential) bool {
+	return c.Username != "" && c.Password != ""
+}
+
+// connect dial the server and bind with the read only user.
+func (l *LDAP) connect() (ldapConn, error) {
+	conn, err := l.dial()
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.Bind(l.conf.Binddn, l.conf.Password); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// userDN search the DN of the user, ErrUserNotFound if not found.
+func (l *LDAP) userDN(conn ldapConn, username string) (string, error) {
+	searchRequest := ldap.NewSearchRequest(
+		l.conf.Base,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		fmt.Sprintf("(%s=%s)", l.conf.UID, ldap.EscapeFilter(username)),
+		[]string{"dn"},
+		nil,
+	)
+	sr, err := conn.Search(searchRequest)
+	if err != nil {
+		return "", err
+	}
+	switch len(sr.Entries) {
+	case 0:
+		return "", ErrUserNotFound
+	case 1:
+		return sr.Entries[0].DN, nil
+	}
+	return "", fmt.Errorf("too many entries returned: %d", len(sr.Entries))
+}
+
+// Authenticate search the user and bind as it to verify the password.
+func (l *LDAP) Authenticate(c Credential) (Identity, error) {
+	conn, err := l.connect()
+	if err != nil {
+		return Identity{}, err
+	}
+	defer conn.Close()
+
+	dn, err := l.userDN(conn, c.Username)
+	if err != nil {
+		return Identity{}, err
+	}
+	if err := conn.Bind(dn, c.Password); err != nil {
+		return Identity{}, ErrInvalidCredential
+	}
+	return Identity{Username: c.Username}, nil
+}
+
+// UserExist return whether the user could be found in LDAP.
+func (l *LDAP) UserExist(username string) (bool, error) {
+	if username == "" {
+		return false, nil
+	}
+	conn, err := l.connect()
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	if _, err := l.userDN(conn, username); err != nil {
+		if err == ErrUserNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// LDAPUser is the user found in LDAP.
+type LDAPUser struct {
+	Username string
+	Mobile   string
+	Mail     string
+}
+
+func attrOrDefault(attr, def string) string {
+	if attr == "" {
+		return def
+	}
+	return attr
+}
+
+// userAttrs return the attributes read from the user entry.
+func (l *LDAP) userAttrs() []string {
+	return []string{l.conf.UID, attrOrDefault(l.conf.MobileAttr, "mobile"), attrOrDefault(l.conf.MailAttr, "mail")}
+}
+
+func (l *LDAP) readUser(e *ldap.Entry) LDAPUser {
+	return LDAPUser{
+		Username: strings.ToLower(e.GetAttributeValue(l.conf.UID)),
+		Mobile:   e.GetAttributeValue(attrOrDefault(l.conf.MobileAttr, "mobile")),
+		Mail:     e.GetAttributeValue(attrOrDefault(l.conf.MailAttr, "mail")),
+	}
+}
+
+// GroupMembers return the users of the group, which are the members of the group DN,
+// or the users match the filter under the base. The member not a user, e.g. a nested group, is ignored.
+func (l *LDAP) GroupMembers(g config.LDAPGroupConfig) ([]LDAPUser, error) {
+	if g.DN == "" && g.Filter == "" {
+		return nil, fmt.Errorf("no dn or filter of group %s", g.Group)
+	}
+	conn, err := l.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if g.Filter != "" {
+		sr, err := conn.Search(ldap.NewSearchRequest(
+			l.conf.Base,
+			ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+			g.Filter, l.userAttrs(), nil,
+		))
+		if err != nil {
+			return nil, err
+		}
+		users := make([]LDAPUser, 0, len(sr.Entries))
+		for _, e := range sr.Entries {
+			if u := l.readUser(e); u.Username != "" {
+				users = append(users, u)
+			}
+		}
+		return users, nil
+	}
+
+	memberAttr := attrOrDefault(l.conf.MemberAttr, "member")
+	sr, err := conn.Search(ldap.NewSearchRequest(
+		g.DN,
+		ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)", []string{memberAttr}, nil,
+	))
+	if err != nil {
+		return nil, err
+	}
+	if len(sr.Entries) != 1 {
+		return nil, fmt.Errorf("group %s not found", g.DN)
+	}
+	users := []LDAPUser{}
+	for _, dn := range sr.Entries[0].GetAttributeValues(memberAttr) {
+		mr, err := conn.Search(ldap.NewSearchRequest(
+			dn,
+			ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false,
+			"(objectClass=*)", l.userAttrs(), nil,
+		))
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(mr.Entries) == 1 {
+			if u := l.readUser(mr.Entries[0]); u.Username != "" {
+				users = append(users, u)
+			}
+		}
+	}
+	return users, nil
+}
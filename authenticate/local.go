@@ -0,0 +1,68 @@
+package authenticate
+
+import (
+	"golang.org/x/crypto/bcrypt"
+)
+
+// LocalName is the name of the local password provider.
+const LocalName = "local"
+
+// PasswordStore save the password hash of the users.
+type PasswordStore interface {
+	// PasswordHash return the password hash of the user, empty if not set.
+	PasswordHash(username string) (string, error)
+
+	// SetPasswordHash set the password hash of the user.
+	SetPasswordHash(username, hash string) error
+}
+
+// Local verify the password by the bcrypt hash saved in the registry.
+type Local struct {
+	store PasswordStore
+}
+
+// NewLocal return the local password provider.
+func NewLocal(store PasswordStore) *Local {
+	return &Local{store: store}
+}
+
+// Name return the name of the provider.
+func (l *Local) Name() string { return LocalName }
+
+// Supports return whether the credential has username and password.
+func (l *Local) Supports(c Credential) bool {
+	return c.Username != "" && c.Password != ""
+}
+
+// Authenticate compare the password with the hash of the user.
+func (l *Local) Authenticate(c Credential) (Identity, error) {
+	hash, err := l.store.PasswordHash(c.Username)
+	if err != nil {
+		return Identity{}, err
+	}
+	if hash == "" {
+		return Identity{}, ErrUserNotFound
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(c.Password)) != nil {
+		return Identity{}, ErrInvalidCredential
+	}
+	return Identity{Username: c.Username}, nil
+}
+
+// UserExist return whether the user has a password.
+func (l *Local) UserExist(username string) (bool, error) {
+	hash, err := l.store.PasswordHash(username)
+	return hash != "", err
+}
+
+// SetPassword save the bcrypt hash of the password.
+func (l *Local) SetPassword(username, password string) error {
+	if username == "" || password == "" {
+		return ErrInvalidCredential
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	return l.store.SetPasswordHash(username, string(hash))
+}
@@ -0,0 +1,46 @@
+package authenticate
+
+import (
+	"github.com/lodastack/registry/oidc"
+)
+
+// OIDCPrefix is the prefix of the name of the OpenID Connect providers.
+const OIDCPrefix = "oidc-"
+
+// OIDC verify the authorization code of the OpenID Connect provider.
+type OIDC struct {
+	provider *oidc.Provider
+}
+
+// NewOIDC return the provider of the OpenID Connect provider.
+func NewOIDC(provider *oidc.Provider) *OIDC {
+	return &OIDC{provider: provider}
+}
+
+// Name return the name of the provider, format as oidc-<name>.
+func (o *OIDC) Name() string { return OIDCPrefix + o.provider.Name() }
+
+// Provider return the OpenID Connect provider.
+func (o *OIDC) Provider() *oidc.Provider { return o.provider }
+
+// Supports return whether the credential has the code and the PKCE verifier of the provider.
+func (o *OIDC) Supports(c Credential) bool {
+	return c.Provider == o.Name() && c.Code != "" && c.Verifier != ""
+}
+
+// Authenticate exchange the code for the ID token, and read the identity from the claims.
+func (o *OIDC) Authenticate(c Credential) (Identity, error) {
+	rawIDToken, err := o.provider.Exchange(c.Code, c.Verifier)
+	if err != nil {
+		return Identity{}, err
+	}
+	claims, err := o.provider.Verify(rawIDToken, c.Nonce)
+	if err != nil {
+		return Identity{}, err
+	}
+	username, groups := o.provider.Identity(claims)
+	if username == "" {
+		return Identity{}, ErrUserNotFound
+	}
+	return Identity{Username: username, Groups: groups, Managed: o.provider.ManagedGroups()}, nil
+}
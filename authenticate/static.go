@@ -0,0 +1,41 @@
+package authenticate
+
+import (
+	"crypto/subtle"
+)
+
+// StaticName is the name of the static token provider.
+const StaticName = "static"
+
+// Static verify the token configured for the user, e.g. for scripts in environments without other provider.
+type Static struct {
+	// tokens is the username by token.
+	tokens map[string]string
+}
+
+// NewStatic return the static token provider of the tokens, the key is the token and the value is the username.
+func NewStatic(tokens map[string]string) *Static {
+	return &Static{tokens: tokens}
+}
+
+// Name return the name of the provider.
+func (s *Static) Name() string { return StaticName }
+
+// Supports return whether the credential has a token.
+func (s *Static) Supports(c Credential) bool {
+	return c.Token != ""
+}
+
+// Authenticate compare the token with every configured token in constant time.
+func (s *Static) Authenticate(c Credential) (Identity, error) {
+	username := ""
+	for token, user := range s.tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(c.Token)) == 1 {
+			username = user
+		}
+	}
+	if username == "" {
+		return Identity{}, ErrInvalidCredential
+	}
+	return Identity{Username: username}, nil
+}
@@ -0,0 +1,104 @@
+package authenticate
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/lodastack/registry/config"
+	"github.com/lodastack/registry/utils"
+)
+
+const (
+	// WeworkName is the name of the Wework provider.
+	WeworkName = "wework"
+
+	// weworkAPI is the API of Wework, https://work.weixin.qq.com/api/doc
+	weworkAPI = "https://qyapi.weixin.qq.com/cgi-bin"
+	// weworkTokenTTL is the time the access token is cached, it expires in 7200 seconds.
+	weworkTokenTTL = 7000 * time.Second
+	// weworkTimeout is the timeout in seconds to query Wework.
+	weworkTimeout = 10
+)
+
+// Wework verify the code of the Wework signin.
+type Wework struct {
+	conf config.WeworkConfig
+	api  string
+
+	mu        sync.Mutex
+	token     string
+	tokenTime time.Time
+}
+
+// NewWework return the Wework provider of the config.
+func NewWework(conf config.WeworkConfig) *Wework {
+	return &Wework{conf: conf, api: weworkAPI}
+}
+
+// Name return the name of the provider.
+func (w *Wework) Name() string { return WeworkName }
+
+// Supports return whether the credential has the code of Wework.
+func (w *Wework) Supports(c Credential) bool {
+	return c.Provider == WeworkName && c.Code != ""
+}
+
+func (w *Wework) get(uri string, v url.Values, resp interface{}) error {
+	q := utils.HttpQuery{Method: http.MethodGet, Url: w.api + uri + "?" + v.Encode(), Timeout: weworkTimeout}
+	if err := q.DoQuery(); err != nil {
+		return err
+	}
+	if q.Result.Status != http.StatusOK {
+		return fmt.Errorf("remote server not 200: %d", q.Result.Status)
+	}
+	return json.Unmarshal(q.Result.Body, resp)
+}
+
+// accessToken return the cached access token, refreshed if expired.
+func (w *Wework) accessToken() (string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.token != "" && time.Since(w.tokenTime) < weworkTokenTTL {
+		return w.token, nil
+	}
+	var resp struct {
+		Token   string `json:"access_token"`
+		Expires int    `json:"expires_in"`
+	}
+	v := url.Values{"corpid": {w.conf.CorpID}, "corpsecret": {w.conf.CorpSecret}}
+	if err := w.get("/gettoken", v, &resp); err != nil {
+		return "", err
+	}
+	if resp.Token == "" {
+		return "", fmt.Errorf("empty token")
+	}
+	w.token, w.tokenTime = resp.Token, time.Now()
+	return w.token, nil
+}
+
+// Authenticate query the user of the code.
+func (w *Wework) Authenticate(c Credential) (Identity, error) {
+	token, err := w.accessToken()
+	if err != nil {
+		return Identity{}, err
+	}
+	var resp struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+		UserID  string `json:"UserId"`
+	}
+	if err := w.get("/user/getuserinfo", url.Values{"access_token": {token}, "code": {c.Code}}, &resp); err != nil {
+		return Identity{}, err
+	}
+	if resp.ErrCode != 0 {
+		return Identity{}, fmt.Errorf("error code not 0, got %d", resp.ErrCode)
+	}
+	if resp.UserID == "" {
+		return Identity{}, ErrUserNotFound
+	}
+	return Identity{Username: resp.UserID}, nil
+}
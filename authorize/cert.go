@@ -0,0 +1,87 @@
+package authorize
+
+import (
+	"crypto/x509"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/lodastack/registry/common"
+	"github.com/lodastack/registry/config"
+)
+
+// CertNames return the names the client certificate identify: the CN, the DNS SAN and the email SAN.
+func CertNames(cert *x509.Certificate) []string {
+	names := []string{}
+	if cert.Subject.CommonName != "" {
+		names = append(names, cert.Subject.CommonName)
+	}
+	names = append(names, cert.DNSNames...)
+	return append(names, cert.EmailAddresses...)
+}
+
+// patternLiterals return the number of the characters the pattern match exactly,
+// the character class is counted as one but not exactly.
+func patternLiterals(pattern string) int {
+	n := 0
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '*', '?':
+		case '[':
+			for i < len(pattern) && pattern[i] != ']' {
+				i++
+			}
+		case '\\':
+			i++
+			n++
+		default:
+			n++
+		}
+	}
+	return n
+}
+
+// certUsername return the username the name mapped to by the certmap config, empty if not mapped.
+// The more specific pattern, which match more characters exactly, is matched first,
+// so that a pattern like * never shadows the others.
+func certUsername(name string, certMap map[string]string) string {
+	patterns := make([]string, 0, len(certMap))
+	for pattern := range certMap {
+		patterns = append(patterns, pattern)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		li, lj := patternLiterals(patterns[i]), patternLiterals(patterns[j])
+		if li != lj {
+			return li > lj
+		}
+		if len(patterns[i]) != len(patterns[j]) {
+			return len(patterns[i]) < len(patterns[j])
+		}
+		return patterns[i] < patterns[j]
+	})
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return certMap[pattern]
+		}
+	}
+	return ""
+}
+
+// AuthCert return the user or service account the verified client certificate identify.
+// The names of certificate are mapped by the certmap config, the user must be mapped explicitly,
+// the name not mapped is only used as the service account name, e.g. svc-<name>.
+func (p *perm) AuthCert(cert *x509.Certificate) (User, error) {
+	for _, name := range CertNames(cert) {
+		username, mapped := certUsername(name, config.C.AuthConf.CertMap), true
+		if username == "" {
+			username, mapped = ServicePrefix+strings.TrimPrefix(name, ServicePrefix), false
+		}
+		u, err := p.GetUser(username)
+		if err == nil && (mapped || u.IsService()) {
+			return u, nil
+		} else if err != nil && err != common.ErrUserNotFound {
+			return u, err
+		}
+	}
+	return User{}, common.ErrUserNotFound
+}
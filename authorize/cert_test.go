@@ -0,0 +1,76 @@
+package authorize
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lodastack/registry/common"
+	"github.com/lodastack/registry/config"
+)
+
+func TestAuthCert(t *testing.T) {
+	s := mustNewStore(t)
+	defer os.RemoveAll(s.Path())
+
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	p, err := NewPerm(s)
+	if err != nil {
+		t.Fatal("NewPerm fail:", err.Error())
+	}
+	if err = p.SetUser("user1", "", "enable", ""); err != nil {
+		t.Fatal("SetUser fail:", err.Error())
+	}
+	if _, _, err := p.CreateService("agent", ServiceAgent, time.Hour); err != nil {
+		t.Fatal("CreateService fail:", err)
+	}
+	defer func(certMap map[string]string) { config.C.AuthConf.CertMap = certMap }(config.C.AuthConf.CertMap)
+	config.C.AuthConf.CertMap = map[string]string{
+		"*.node.loda":     ServicePrefix + "agent",
+		"user1.user.loda": "user1",
+	}
+
+	for cn, username := range map[string]string{
+		"user1.user.loda":       "user1",
+		"agent":                 ServicePrefix + "agent",
+		ServicePrefix + "agent": ServicePrefix + "agent",
+		"host1.node.loda":       ServicePrefix + "agent",
+	} {
+		u, err := p.AuthCert(&x509.Certificate{Subject: pkix.Name{CommonName: cn}})
+		if err != nil || u.Username != username {
+			t.Fatalf("user of %s not match with expect: %+v, %v", cn, u, err)
+		}
+	}
+	u, err := p.AuthCert(&x509.Certificate{Subject: pkix.Name{CommonName: "unknown"}, DNSNames: []string{"host2.node.loda"}})
+	if err != nil || u.Username != ServicePrefix+"agent" {
+		t.Fatalf("user of SAN not match with expect: %+v, %v", u, err)
+	}
+	if _, err := p.AuthCert(&x509.Certificate{Subject: pkix.Name{CommonName: "unknown"}}); err != common.ErrUserNotFound {
+		t.Fatalf("unknown certificate should fail: %v", err)
+	}
+
+	// the user must be mapped explicitly.
+	if _, err := p.AuthCert(&x509.Certificate{Subject: pkix.Name{CommonName: "user1"}}); err != common.ErrUserNotFound {
+		t.Fatalf("certificate of unmapped user should fail: %v", err)
+	}
+
+	// the more specific pattern is matched first.
+	config.C.AuthConf.CertMap["*"] = "user1"
+	config.C.AuthConf.CertMap["host?.node.loda"] = "unknown"
+	for name, username := range map[string]string{
+		"user1.user.loda": "user1",
+		"host1.node.loda": "unknown",
+		"a.node.loda":     ServicePrefix + "agent",
+		"agent":           "user1",
+	} {
+		if u := certUsername(name, config.C.AuthConf.CertMap); u != username {
+			t.Fatalf("username of %s not match with expect: %s", name, u)
+		}
+	}
+}
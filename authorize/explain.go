@@ -0,0 +1,74 @@
+package authorize
+
+import "errors"
+
+// Reason of a group not matched in the explanation.
+const (
+	ReasonGroupNotFound = "group not found"
+	ReasonNoGrant       = "no grant matched"
+)
+
+// GroupExplain is a group of the user which not decide the result.
+type GroupExplain struct {
+	GName  string `json:"gname"`
+	Reason string `json:"reason"`
+}
+
+// Explanation tell why a query has the permission or not.
+// Group and Item is the grant which decide the result, empty if no grant matched,
+// Role is the role binding the grant comes from.
+type Explanation struct {
+	User     string         `json:"user"`
+	NS       string         `json:"ns"`
+	Resource string         `json:"resource"`
+	Method   string         `json:"method"`
+	URI      string         `json:"uri"`
+	Allow    bool           `json:"allow"`
+	Group    string         `json:"group,omitempty"`
+	Item     string         `json:"item,omitempty"`
+	Role     string         `json:"role,omitempty"`
+	Groups   []GroupExplain `json:"groups"`
+}
+
+// Explain check one query has the permission or not and tell the grant decide it.
+func (p *perm) Explain(username, ns, resource, method, uri string) (Explanation, error) {
+	exp := Explanation{User: username, NS: ns, Resource: resource, Method: method, URI: uri, Groups: []GroupExplain{}}
+	u, err := p.GetUser(username)
+	if err != nil {
+		return exp, errors.New("get user fail: " + err.Error())
+	}
+	if len(u.Groups) == 0 {
+		// TODO: log
+		return exp, errors.New("have no group fail")
+	}
+
+	denied, roles := false, map[string]Role{}
+	for _, gName := range u.Groups {
+		g, err := p.GetGroup(gName)
+		if err != nil {
+			exp.Groups = append(exp.Groups, GroupExplain{GName: gName, Reason: ReasonGroupNotFound})
+			continue
+		}
+		matched := false
+		for _, grant := range p.groupGrants(g, roles) {
+			if !grant.Match(ns, resource, method) {
+				continue
+			}
+			matched = true
+			// deny grant take precedence over allow grant.
+			switch {
+			case denied:
+			case grant.Deny:
+				denied, exp.Allow = true, false
+				exp.Group, exp.Item, exp.Role = gName, grant.Item(), grant.Role
+			case !exp.Allow:
+				exp.Allow = true
+				exp.Group, exp.Item, exp.Role = gName, grant.Item(), grant.Role
+			}
+		}
+		if !matched {
+			exp.Groups = append(exp.Groups, GroupExplain{GName: gName, Reason: ReasonNoGrant})
+		}
+	}
+	return exp, nil
+}
@@ -0,0 +1,64 @@
+package authorize
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestExplain(t *testing.T) {
+	s := mustNewStore(t)
+	defer os.RemoveAll(s.Path())
+
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	perm, err := NewPerm(s)
+	if err != nil {
+		t.Fatal("NewPerm fail:", err.Error())
+	}
+	if err = perm.SetUser("user1", "", "enable", ""); err != nil {
+		t.Fatal("SetUser fail:", err.Error())
+	}
+	opGName := GetNsOpGName("web.loda")
+	if err = perm.CreateGroup(opGName, []string{"user1"}, []string{}, []string{"web.loda-machine-PUT", "!db.web.loda-machine-PUT"}); err != nil {
+		t.Fatal("CreateGroup fail:", err)
+	}
+
+	// case 1: allowed by the op group, the default group not matched.
+	exp, err := perm.Explain("user1", "api.web.loda", "machine", "PUT", "/api/v1/resource")
+	if err != nil || !exp.Allow || exp.Group != opGName || exp.Item != "web.loda-machine-PUT" {
+		t.Fatalf("explain not match with expect: %+v, %v", exp, err)
+	}
+	if len(exp.Groups) != 1 || exp.Groups[0].GName != lodaDefaultGName || exp.Groups[0].Reason != ReasonNoGrant {
+		t.Fatalf("not matched groups not match with expect: %+v", exp.Groups)
+	}
+
+	// case 2: denied.
+	if exp, err = perm.Explain("user1", "db.web.loda", "machine", "PUT", ""); err != nil || exp.Allow ||
+		exp.Group != opGName || exp.Item != "!db.web.loda-machine-PUT" {
+		t.Fatalf("explain deny not match with expect: %+v, %v", exp, err)
+	}
+	if ok, _ := perm.Check("user1", "db.web.loda", "machine", "PUT", ""); ok {
+		t.Fatal("check should follow the explain")
+	}
+
+	// case 3: no grant matched.
+	if exp, err = perm.Explain("user1", "web.loda", "machine", "DELETE", ""); err != nil || exp.Allow || exp.Group != "" || len(exp.Groups) != 2 {
+		t.Fatalf("explain not match with expect: %+v, %v", exp, err)
+	}
+
+	// case 4: allowed by the role binding.
+	if err = perm.SetRole("alarm-editor", []string{"alarm-*"}); err != nil {
+		t.Fatal("SetRole fail:", err)
+	}
+	if err = perm.BindRoles(opGName, []RoleBinding{{Role: "alarm-editor", NS: "web.loda"}}); err != nil {
+		t.Fatal("BindRoles fail:", err)
+	}
+	if exp, err = perm.Explain("user1", "api.web.loda", "alarm", "DELETE", ""); err != nil || !exp.Allow ||
+		exp.Role != "alarm-editor@web.loda" || exp.Item != "web.loda-alarm-*" {
+		t.Fatalf("explain role not match with expect: %+v, %v", exp, err)
+	}
+}
@@ -0,0 +1,156 @@
+package authorize
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/lodastack/registry/common"
+	m "github.com/lodastack/store/model"
+)
+
+const (
+	// AnyGrant match any resource type or method.
+	AnyGrant = "*"
+	// denyPrefix is the prefix of the item which deny the permission.
+	denyPrefix = "!"
+)
+
+// Grant is one permission of a group: the method on the resource type in the ns and its children.
+// Empty NS means all ns. Deny grant forbid the permission even if other grant allow it.
+type Grant struct {
+	NS       string `json:"ns"`
+	Resource string `json:"resource"`
+	Method   string `json:"method"`
+	Deny     bool   `json:"deny,omitempty"`
+}
+
+// ParseItem return the grant of the item, the item is format as ns-resource-method,
+// prefixed by "!" if deny.
+func ParseItem(item string) (Grant, error) {
+	g := Grant{}
+	if strings.HasPrefix(item, denyPrefix) {
+		g.Deny, item = true, item[len(denyPrefix):]
+	}
+	methodIndex := strings.LastIndexByte(item, '-')
+	if methodIndex <= 0 || methodIndex == len(item)-1 {
+		return g, common.ErrInvalidParam
+	}
+	g.Method = strings.ToUpper(item[methodIndex+1:])
+	item = item[:methodIndex]
+	if resIndex := strings.LastIndexByte(item, '-'); resIndex >= 0 {
+		g.NS, item = item[:resIndex], item[resIndex+1:]
+		if g.NS == "" {
+			return g, common.ErrInvalidParam
+		}
+	}
+	if item == "" {
+		return g, common.ErrInvalidParam
+	}
+	g.Resource = item
+	return g, nil
+}
+
+// ParseItems return the grants of the items, empty item is ignored.
+func ParseItems(items []string) ([]Grant, error) {
+	grants := make([]Grant, 0, len(items))
+	for _, item := range items {
+		if item == "" {
+			continue
+		}
+		g, err := ParseItem(item)
+		if err != nil {
+			return nil, err
+		}
+		grants = append(grants, g)
+	}
+	return grants, nil
+}
+
+// Item return the grant at form of item.
+func (g Grant) Item() string {
+	item := g.Resource + "-" + g.Method
+	if g.NS != "" {
+		item = g.NS + "-" + item
+	}
+	if g.Deny {
+		item = denyPrefix + item
+	}
+	return item
+}
+
+// Match check the grant cover the method on the resource type of the ns.
+// The ns of grant match itself and its children only, e.g. b.loda match a.b.loda but not ab.loda.
+func (g Grant) Match(ns, resource, method string) bool {
+	if g.Resource != AnyGrant && g.Resource != resource {
+		return false
+	}
+	if g.Method != AnyGrant && g.Method != strings.ToUpper(method) {
+		return false
+	}
+	return g.NS == "" || inNs(ns, g.NS)
+}
+
+// Evaluate return whether the grants allow the method on the resource type of the ns.
+// Deny grant take precedence over allow grant.
+func Evaluate(grants []Grant, ns, resource, method string) bool {
+	allow := false
+	for _, g := range grants {
+		if !g.Match(ns, resource, method) {
+			continue
+		}
+		if g.Deny {
+			return false
+		}
+		allow = true
+	}
+	return allow
+}
+
+// grants return the grants of the group, parsed from the items if not migrated.
+func (g *Group) grants() []Grant {
+	if g.Grants != nil {
+		return g.Grants
+	}
+	grants := []Grant{}
+	for _, item := range g.Items {
+		if grant, err := ParseItem(item); err == nil {
+			grants = append(grants, grant)
+		}
+	}
+	return grants
+}
+
+// migrateGrants set the grants of the groups which only have items.
+// Invalid item is dropped from the grants and kept in the items.
+func (p *perm) migrateGrants() error {
+	p.Lock()
+	defer p.Unlock()
+
+	groupMap, err := p.cluster.ViewPrefix([]byte(AuthBuck), getGKey(""))
+	if err != nil {
+		return err
+	}
+	rows := []m.Row{}
+	for key, gByte := range groupMap {
+		if len(gByte) == 0 {
+			continue
+		}
+		group := Group{}
+		if err := json.Unmarshal(gByte, &group); err != nil {
+			return err
+		}
+		if group.Grants != nil {
+			continue
+		}
+		group.Grants = group.grants()
+		newGByte, err := group.Byte()
+		if err != nil {
+			return err
+		}
+		rows = append(rows, m.Row{Bucket: []byte(AuthBuck), Key: []byte(key), Value: newGByte})
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return p.cluster.Batch(rows)
+}
@@ -0,0 +1,111 @@
+package authorize
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseItem(t *testing.T) {
+	for item, expect := range map[string]Grant{
+		"b.loda-machine-GET":    {NS: "b.loda", Resource: "machine", Method: "GET"},
+		"0-2.loda-alarm-put":    {NS: "0-2.loda", Resource: "alarm", Method: "PUT"},
+		"!b.loda-deploy-DELETE": {NS: "b.loda", Resource: "deploy", Method: "DELETE", Deny: true},
+		"machine-GET":           {Resource: "machine", Method: "GET"},
+		"loda-*-*":              {NS: "loda", Resource: AnyGrant, Method: AnyGrant},
+	} {
+		g, err := ParseItem(item)
+		if err != nil || g != expect {
+			t.Fatalf("parse item %s not match with expect: %+v, %v", item, g, err)
+		}
+		if reparsed, _ := ParseItem(g.Item()); reparsed != g {
+			t.Fatalf("item of grant %+v not match: %s", g, g.Item())
+		}
+	}
+	for _, item := range []string{"GET", "-machine-GET", "b.loda-machine-", "b.loda--GET"} {
+		if _, err := ParseItem(item); err == nil {
+			t.Fatalf("parse invalid item %s should fail", item)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	grants, err := ParseItems([]string{"b.loda-machine-GET", "loda-alarm-GET", "!secret.loda-alarm-GET", ""})
+	if err != nil {
+		t.Fatalf("parse items fail: %s", err.Error())
+	}
+	for _, c := range []struct {
+		ns, resource, method string
+		expect               bool
+	}{
+		{"b.loda", "machine", "GET", true},
+		{"a.b.loda", "machine", "get", true},
+		{"ab.loda", "machine", "GET", false},
+		{"b.loda", "machine", "PUT", false},
+		{"x.loda", "alarm", "GET", true},
+		{"secret.loda", "alarm", "GET", false},
+		{"db.secret.loda", "alarm", "GET", false},
+	} {
+		if ok := Evaluate(grants, c.ns, c.resource, c.method); ok != c.expect {
+			t.Fatalf("evaluate %s %s %s not match with expect %v", c.ns, c.resource, c.method, c.expect)
+		}
+	}
+}
+
+func TestMigrateGrants(t *testing.T) {
+	s := mustNewStore(t)
+	defer os.RemoveAll(s.Path())
+
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	p, err := NewPerm(s)
+	if err != nil {
+		t.Fatal("NewPerm fail:", err.Error())
+	}
+	if err = p.SetUser("user1", "", "enable", ""); err != nil {
+		t.Fatal("SetUser fail:", err.Error())
+	}
+
+	// group saved by old version has items only, user1 could GET all by the default group.
+	legacy, _ := json.Marshal(map[string]interface{}{
+		"gname":    "legacy",
+		"managers": []string{},
+		"members":  []string{},
+		"items":    []string{"b.loda-machine-PUT", "invalid"},
+	})
+	if err := s.Update([]byte(AuthBuck), getGKey("legacy"), legacy); err != nil {
+		t.Fatal("save legacy group fail:", err.Error())
+	}
+	if err := p.UpdateMember("legacy", []string{"user1"}, []string{}); err != nil {
+		t.Fatal("UpdateMember fail:", err.Error())
+	}
+	if ok, _ := p.Check("user1", "a.b.loda", "machine", "PUT", ""); !ok {
+		t.Fatal("check legacy group before migrate fail")
+	}
+
+	if err := p.(*perm).migrateGrants(); err != nil {
+		t.Fatal("migrate grants fail:", err.Error())
+	}
+	g, err := p.GetGroup("legacy")
+	if err != nil || len(g.Grants) != 1 || g.Grants[0].NS != "b.loda" || len(g.Items) != 2 {
+		t.Fatalf("migrated group not match with expect: %+v, %v", g, err)
+	}
+	if ok, _ := p.Check("user1", "ab.loda", "machine", "PUT", ""); ok {
+		t.Fatal("grant of b.loda should not match ab.loda")
+	}
+
+	// deny item forbid the permission granted by other group.
+	if err := p.UpdateItems("legacy", []string{"b.loda-machine-PUT", "!c.b.loda-machine-PUT"}); err != nil {
+		t.Fatal("UpdateItems fail:", err.Error())
+	}
+	if ok, _ := p.Check("user1", "c.b.loda", "machine", "PUT", ""); ok {
+		t.Fatal("denied permission should not pass")
+	}
+	if err := p.UpdateItems("legacy", []string{"GET"}); err == nil {
+		t.Fatal("UpdateItems with invalid item should fail")
+	}
+}
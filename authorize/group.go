@@ -24,6 +24,10 @@ type Group struct {
 	Managers []string `json:"managers"`
 	Members  []string `json:"members"`
 	Items    []string `json:"items"`
+	// Grants is parsed from the items, used to check the permission.
+	Grants []Grant `json:"grants,omitempty"`
+	// Roles is the role bindings of the group.
+	Roles []RoleBinding `json:"roles"`
 
 	cluster Cluster `json:"-"`
 }
@@ -135,11 +139,17 @@ func (g *Group) createGroup(gName string, managers, members, items []string) (m.
 		return updateRow, err
 	}
 
+	grants, err := ParseItems(items)
+	if err != nil {
+		return updateRow, err
+	}
 	gByte, err := (&Group{
 		GName:    gName,
 		Managers: managers,
 		Members:  members,
 		Items:    items,
+		Grants:   grants,
+		Roles:    []RoleBinding{},
 	}).Byte()
 	if err != nil {
 		return updateRow, err
@@ -159,6 +169,9 @@ func (g *Group) UpdateItems(gName string, items []string) error {
 	if len(items) == 0 || items[0] == "" {
 		return common.ErrInvalidParam
 	}
+	if group.Grants, err = ParseItems(items); err != nil {
+		return err
+	}
 	group.Items = items
 
 	gByte, err := group.Byte()
@@ -0,0 +1,96 @@
+package authorize
+
+import (
+	"sort"
+
+	"github.com/lodastack/registry/common"
+
+	m "github.com/lodastack/store/model"
+)
+
+// GroupSync is the change of a group synced from the directory, e.g. LDAP.
+type GroupSync struct {
+	Group   string   `json:"group"`
+	Created []string `json:"created"`
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// SyncGroupMembers make the users the members of the group, and create the users not exist.
+// The managers and service accounts of the group are kept. Nothing is changed if dryRun.
+// The group is not synced if no user given but members would be removed,
+// an empty result is more likely a broken query than an empty group.
+func (p *perm) SyncGroupMembers(gName string, users []User, dryRun bool) (GroupSync, error) {
+	result := GroupSync{Group: gName, Created: []string{}, Added: []string{}, Removed: []string{}}
+	group, err := p.GetGroup(gName)
+	if err != nil {
+		return result, err
+	}
+
+	createRows := []m.Row{}
+	members := []string{}
+	synced := make(map[string]bool, len(users))
+	for _, u := range users {
+		if u.Username == "" || synced[u.Username] {
+			continue
+		}
+		synced[u.Username] = true
+		exist, err := p.CheckUserExist(u.Username)
+		if err != nil {
+			return result, err
+		}
+		if !exist {
+			result.Created = append(result.Created, u.Username)
+			nu := newUser(u.Username)
+			nu.Mobile, nu.Mail = u.Mobile, u.Mail
+			uByte, err := nu.Byte()
+			if err != nil {
+				return result, err
+			}
+			createRows = append(createRows, m.Row{Bucket: []byte(AuthBuck), Key: getUKey(u.Username), Value: uByte})
+		}
+		members = append(members, u.Username)
+	}
+
+	// keep the members which are managers or service accounts.
+	for _, username := range group.Members {
+		if synced[username] {
+			continue
+		}
+		if _, ok := common.ContainString(group.Managers, username); ok {
+			members = append(members, username)
+			continue
+		}
+		u, err := p.GetUser(username)
+		if err != nil && err != common.ErrUserNotFound {
+			return result, err
+		}
+		if err == nil && u.IsService() {
+			members = append(members, username)
+			continue
+		}
+		result.Removed = append(result.Removed, username)
+	}
+	if len(synced) == 0 && len(result.Removed) != 0 {
+		return result, common.ErrEmptyGroupSync
+	}
+	for _, username := range members {
+		if _, ok := common.ContainString(group.Members, username); !ok {
+			result.Added = append(result.Added, username)
+		}
+	}
+	sort.Strings(result.Created)
+	sort.Strings(result.Added)
+	sort.Strings(result.Removed)
+
+	if dryRun || (len(result.Added) == 0 && len(result.Removed) == 0) {
+		return result, nil
+	}
+	if len(createRows) != 0 {
+		if err := p.cluster.Batch(createRows); err != nil {
+			return result, err
+		}
+	}
+	return result, p.UpdateMember(gName, group.Managers, members)
+}
@@ -0,0 +1,73 @@
+package authorize
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lodastack/registry/common"
+)
+
+func TestSyncGroupMembers(t *testing.T) {
+	s := mustNewStore(t)
+	defer os.RemoveAll(s.Path())
+
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	p, err := NewPerm(s)
+	if err != nil {
+		t.Fatal("NewPerm fail:", err.Error())
+	}
+	// svc-fake is a user named like a service account, which is not kept.
+	for _, username := range []string{"manager1", "user1", "left1", "svc-fake"} {
+		if err = p.SetUser(username, "", "enable", ""); err != nil {
+			t.Fatal("SetUser fail:", err.Error())
+		}
+	}
+	if _, _, err := p.CreateService("agent", ServiceAgent, time.Hour); err != nil {
+		t.Fatal("CreateService fail:", err)
+	}
+	if err = p.CreateGroup("loda-ops", []string{"manager1"}, []string{"manager1", "user1", "left1", "svc-agent", "svc-fake"}, []string{}); err != nil {
+		t.Fatal("CreateGroup fail:", err.Error())
+	}
+
+	// the group is not emptied by an empty result.
+	if _, err := p.SyncGroupMembers("loda-ops", nil, false); err != common.ErrEmptyGroupSync {
+		t.Fatalf("sync no user should fail: %v", err)
+	}
+
+	users := []User{{Username: "user1"}, {Username: "user2", Mobile: "123", Mail: "user2@loda"}}
+	expect := GroupSync{Group: "loda-ops", Created: []string{"user2"}, Added: []string{"user2"}, Removed: []string{"left1", "svc-fake"}}
+	result, err := p.SyncGroupMembers("loda-ops", users, true)
+	if err != nil || !reflect.DeepEqual(result, expect) {
+		t.Fatalf("dry run not match with expect: %+v, %v", result, err)
+	}
+	if exist, _ := p.CheckUserExist("user2"); exist {
+		t.Fatal("dry run should not create user")
+	}
+
+	if result, err = p.SyncGroupMembers("loda-ops", users, false); err != nil || !reflect.DeepEqual(result, expect) {
+		t.Fatalf("sync not match with expect: %+v, %v", result, err)
+	}
+	g, err := p.GetGroup("loda-ops")
+	if err != nil || !reflect.DeepEqual(g.Members, []string{"user1", "user2", "manager1", "svc-agent"}) {
+		t.Fatalf("members of group not match with expect: %+v, %v", g, err)
+	}
+	u, err := p.GetUser("user2")
+	if err != nil || u.Mobile != "123" || u.Mail != "user2@loda" || len(u.Groups) != 2 {
+		t.Fatalf("created user not match with expect: %+v, %v", u, err)
+	}
+	if u, err = p.GetUser("left1"); err != nil || len(u.Groups) != 1 {
+		t.Fatalf("removed user not match with expect: %+v, %v", u, err)
+	}
+
+	// nothing changed if synced again.
+	result, err = p.SyncGroupMembers("loda-ops", users, false)
+	if err != nil || len(result.Created)+len(result.Added)+len(result.Removed) != 0 {
+		t.Fatalf("sync again not match with expect: %+v, %v", result, err)
+	}
+}
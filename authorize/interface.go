@@ -1,7 +1,9 @@
 package authorize
 
 import (
+	"crypto/x509"
 	"sync"
+	"time"
 
 	"github.com/lodastack/registry/tree/node"
 
@@ -56,6 +58,69 @@ type Perm interface {
 	// Check return the query has the permission or not by ns/resource type/username/method.
 	Check(username, ns, resourceType, method, uri string) (bool, error)
 
+	// GetRole return the role by name.
+	GetRole(name string) (Role, error)
+
+	// ListRole return all roles.
+	ListRole() ([]Role, error)
+
+	// SetRole create or update the role by items format as resource-method.
+	SetRole(name string, items []string) error
+
+	// RemoveRole remove the role.
+	RemoveRole(name string) error
+
+	// BindRoles set the role bindings of the group.
+	BindRoles(gName string, bindings []RoleBinding) error
+
+	// Explain return whether the query has the permission and the group/item decide it.
+	Explain(username, ns, resourceType, method, uri string) (Explanation, error)
+
+	// CreateToken create an API token of the user, return the token and its secret.
+	CreateToken(username, name string, scopes []string, ttl time.Duration) (Token, string, error)
+
+	// ListToken return the API tokens of the user.
+	ListToken(username string) ([]Token, error)
+
+	// RotateToken replace the secret of the API token, return the token and the new secret.
+	RotateToken(username, id string, ttl time.Duration) (Token, string, error)
+
+	// RevokeToken remove the API token of the user.
+	RevokeToken(username, id string) error
+
+	// AuthToken return the API token if the raw token is valid.
+	AuthToken(raw string) (Token, error)
+
+	// AuthCert return the user or service account the client certificate identify.
+	AuthCert(cert *x509.Certificate) (User, error)
+
+	// CreateSession create a signin session of the user, return the session key.
+	CreateSession(username, ip, userAgent string) (string, error)
+
+	// AuthSession return the session of the key if not expired and renew it.
+	AuthSession(key string) (Session, error)
+
+	// ListSession return the sessions of the user, all sessions if username is empty.
+	ListSession(username string) ([]Session, error)
+
+	// RevokeSession remove the session by ID.
+	RevokeSession(id string) error
+
+	// RevokeUserSession remove all sessions of the user.
+	RevokeUserSession(username string) (int, error)
+
+	// PurgeExpiredSession remove the expired sessions.
+	PurgeExpiredSession() (int, error)
+
+	// CreateService create a service account and issue a token of it.
+	CreateService(name, kind string, ttl time.Duration) (Token, string, error)
+
+	// ListService return all service accounts.
+	ListService() ([]User, error)
+
+	// RemoveService remove the service account and its tokens.
+	RemoveService(name string) error
+
 	// InitGroup init default/admin group and default user.
 	InitGroup(rootNode string) error
 
@@ -65,11 +130,32 @@ type Perm interface {
 	// UpdateMember update group member and the user groups.
 	UpdateMember(group string, managers, members []string) error
 
+	// SyncUserGroups make the user a member of the groups and remove it from other managed groups.
+	SyncUserGroups(username string, managed, groups []string) error
+
+	// SyncGroupMembers make the users the members of the group, and create the users not exist.
+	SyncGroupMembers(gName string, users []User, dryRun bool) (GroupSync, error)
+
 	// RemoveUser remove user from his all group.
 	RemoveUser(username string) error
 
+	// PasswordHash return the password hash of the local user, empty if not set.
+	PasswordHash(username string) (string, error)
+
+	// SetPasswordHash set the password hash of the local user.
+	SetPasswordHash(username, hash string) error
+
 	// RemoveGroup remove the group.
 	RemoveGroup(gName string) error
+
+	// RemoveGroupRows return the rows which remove the groups.
+	RemoveGroupRows(gNames []string) ([]m.Row, error)
+
+	// RestoreGroupRows return the rows which create the removed groups again.
+	RestoreGroupRows(groups []Group) ([]m.Row, error)
+
+	// RenameNs return the rows which rename the groups and permission items of the ns subtree.
+	RenameNs(oldNs, newNs string) ([]m.Row, error)
 }
 
 // Cluster is the interface op must implement.
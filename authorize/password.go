@@ -0,0 +1,21 @@
+package authorize
+
+func getPKey(username string) []byte { return []byte("p-" + username) }
+
+// PasswordHash return the password hash of the local user, empty if not set.
+// The hash is saved apart from the user so it is never returned with the user.
+func (p *perm) PasswordHash(username string) (string, error) {
+	hash, err := p.cluster.View([]byte(AuthBuck), getPKey(username))
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// SetPasswordHash set the password hash of the local user, create the user if not exist.
+func (p *perm) SetPasswordHash(username, hash string) error {
+	if err := p.createUserIfNotExist(username); err != nil {
+		return err
+	}
+	return p.cluster.Update([]byte(AuthBuck), getPKey(username), []byte(hash))
+}
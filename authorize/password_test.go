@@ -0,0 +1,48 @@
+package authorize
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPasswordHash(t *testing.T) {
+	s := mustNewStore(t)
+	defer os.RemoveAll(s.Path())
+
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	p, err := NewPerm(s)
+	if err != nil {
+		t.Fatal("NewPerm fail:", err.Error())
+	}
+
+	if hash, err := p.PasswordHash("user1"); err != nil || hash != "" {
+		t.Fatalf("password of unknown user should be empty: %q, %v", hash, err)
+	}
+	if err := p.SetPasswordHash("user1", "hash1"); err != nil {
+		t.Fatal("SetPasswordHash fail:", err)
+	}
+	if hash, err := p.PasswordHash("user1"); err != nil || hash != "hash1" {
+		t.Fatalf("PasswordHash not match with expect: %q, %v", hash, err)
+	}
+	// the user is created, and the hash is not returned with it.
+	u, err := p.GetUser("user1")
+	if err != nil {
+		t.Fatal("GetUser fail:", err)
+	}
+	if b, _ := u.Byte(); strings.Contains(string(b), "hash1") {
+		t.Fatalf("user should not has the password hash: %s", b)
+	}
+
+	if err := p.RemoveUser("user1"); err != nil {
+		t.Fatal("RemoveUser fail:", err)
+	}
+	if hash, err := p.PasswordHash("user1"); err != nil || hash != "" {
+		t.Fatalf("password should be removed with the user: %q, %v", hash, err)
+	}
+}
@@ -1,9 +1,7 @@
 package authorize
 
 import (
-	"errors"
 	"fmt"
-	"strings"
 	"sync"
 
 	"github.com/lodastack/registry/common"
@@ -38,52 +36,20 @@ type perm struct {
 
 // Check one query has the permission or not.
 func (p *perm) Check(username, ns, resource, method, uri string) (bool, error) {
-	u, err := p.GetUser(username)
-	if err != nil {
-		return false, errors.New("get user fail: " + err.Error())
-	}
-	if len(u.Groups) == 0 {
-		// TODO: log
-		return false, errors.New("have no group fail")
-	}
-
-	q := ns + "-" + resource + "-" + method
-	if uri == "/api/v1/resource" && method == "GET" && resource == "deploy" {
-		q = ns + "-" + resource + "-" + "PUT"
-	}
-
-	for _, gName := range u.Groups {
-		// only support groups which has "-op" suffix
-		// can update deploy resource.
-		if uri == "/api/v1/resource" && method == "PUT" && resource == "deploy" && !strings.HasSuffix(gName, "-op") {
-			continue
-		}
-
-		g, err := p.GetGroup(gName)
-		if err != nil {
-			// TODO: log
-			continue
-		}
-		for _, item := range g.Items {
-			if item == "" {
-				continue
-			}
-			// if has the perm of the ns or its parent, pass.
-			if strings.HasSuffix(q, item) {
-				return true, nil
-			}
-		}
-	}
-	return false, nil
+	exp, err := p.Explain(username, ns, resource, method, uri)
+	return exp.Allow, err
 }
 
 // DefaultGroupItems return the item of default group.
-// default user could get all resource,
+// default user could get all resource except deploy,
 // could get/post/put/delete the group which user is the group manager.
 func (p *perm) DefaultGroupItems(ns string) []string {
-	items := make([]string, len(model.Templates)+3)
-	for index, res := range model.Templates {
-		items[index] = fmt.Sprintf("%s-%s-%s", ns, res, "GET")
+	items := make([]string, 0, len(model.Templates))
+	for _, res := range model.Templates {
+		if res == model.Deploy {
+			continue
+		}
+		items = append(items, fmt.Sprintf("%s-%s-%s", ns, res, "GET"))
 	}
 	return items
 }
@@ -112,6 +78,12 @@ func (p *perm) InitGroup(rootNode string) error {
 		}
 	}
 
+	if err := p.migrateGrants(); err != nil {
+		return err
+	}
+	if err := p.migrateRoles(); err != nil {
+		return err
+	}
 	return p.checkDefaultGroup()
 }
 
@@ -260,6 +232,55 @@ func (p *perm) UpdateMember(gName string, managers, members []string) error {
 	return p.cluster.Batch(updateRows)
 }
 
+// SyncUserGroups make the user a member of the groups and remove it from other managed groups.
+// The groups not managed are not changed, and the managed group not found is ignored.
+func (p *perm) SyncUserGroups(username string, managed, groups []string) error {
+	p.Lock()
+	defer p.Unlock()
+
+	user, err := p.GetUser(username)
+	if err != nil {
+		return err
+	}
+	updateRows := []m.Row{}
+	for _, gName := range managed {
+		group, err := p.GetGroup(gName)
+		if err == common.ErrGroupNotFound {
+			continue
+		} else if err != nil {
+			return err
+		}
+		_, want := common.ContainString(groups, gName)
+		_, isMember := common.ContainString(group.Members, username)
+		if want == isMember {
+			continue
+		}
+		if want {
+			group.Members, _ = common.AddIfNotContain(group.Members, username)
+			user.Groups, _ = common.AddIfNotContain(user.Groups, gName)
+		} else {
+			group.Members, _ = common.RemoveIfContain(group.Members, username)
+			if _, isManager := common.ContainString(group.Managers, username); !isManager {
+				user.Groups, _ = common.RemoveIfContain(user.Groups, gName)
+			}
+		}
+		gByte, err := group.Byte()
+		if err != nil {
+			return err
+		}
+		updateRows = append(updateRows, m.Row{Bucket: []byte(AuthBuck), Key: getGKey(gName), Value: gByte})
+	}
+	if len(updateRows) == 0 {
+		return nil
+	}
+	uByte, err := user.Byte()
+	if err != nil {
+		return err
+	}
+	updateRows = append(updateRows, m.Row{Bucket: []byte(AuthBuck), Key: getUKey(username), Value: uByte})
+	return p.cluster.Batch(updateRows)
+}
+
 // RemoveUser remove group and update the groups of manger/member.
 func (p *perm) RemoveUser(username string) error {
 	groups, err := p.UserRemoveUser(username)
@@ -276,6 +297,21 @@ func (p *perm) RemoveUser(username string) error {
 		}
 		updateGroupRows = append(updateGroupRows, udpateRow)
 	}
+
+	// revoke the sessions, tokens and password of the user.
+	sessionRows, err := p.userSessionRows(username)
+	if err != nil {
+		return err
+	}
+	updateGroupRows = append(updateGroupRows, sessionRows...)
+	updateGroupRows = append(updateGroupRows, m.Row{Bucket: []byte(AuthBuck), Key: getPKey(username), Value: []byte{}})
+	tokens, err := p.ListToken(username)
+	if err != nil {
+		return err
+	}
+	for _, t := range tokens {
+		updateGroupRows = append(updateGroupRows, m.Row{Bucket: []byte(AuthBuck), Key: getTKey(t.ID), Value: []byte{}})
+	}
 	return p.cluster.Batch(updateGroupRows)
 }
 
@@ -301,6 +337,103 @@ func (p *perm) RemoveGroup(gName string) error {
 	return p.cluster.Batch(updateGroupRows)
 }
 
+// RemoveGroupRows return the rows which remove the groups and the groups of their users,
+// so that the groups could be removed with other data in one batch.
+func (p *perm) RemoveGroupRows(gNames []string) ([]m.Row, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	rows := []m.Row{}
+	users := map[string]*User{}
+	for _, gName := range gNames {
+		group, err := p.GetGroup(gName)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, m.Row{Bucket: []byte(AuthBuck), Key: getGKey(gName), Value: []byte{}})
+
+		for _, username := range append(group.Managers, group.Members...) {
+			if username == "" {
+				continue
+			}
+			u, ok := users[username]
+			if !ok {
+				user, err := p.GetUser(username)
+				if err == common.ErrUserNotFound {
+					continue
+				} else if err != nil {
+					return nil, err
+				}
+				u = &user
+				users[username] = u
+			}
+			u.Groups, _ = common.RemoveIfContain(u.Groups, gName)
+		}
+	}
+
+	for username, u := range users {
+		uByte, err := u.Byte()
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, m.Row{Bucket: []byte(AuthBuck), Key: getUKey(username), Value: uByte})
+	}
+	return rows, nil
+}
+
+// RestoreGroupRows return the rows which create the removed groups again as saved,
+// with the grants and role bindings, and add the groups back to their users.
+func (p *perm) RestoreGroupRows(groups []Group) ([]m.Row, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	rows := []m.Row{}
+	users := map[string]*User{}
+	for _, group := range groups {
+		if group.GName == "" {
+			return nil, common.ErrInvalidParam
+		}
+		if _, err := p.GetGroup(group.GName); err != common.ErrGroupNotFound {
+			if err == nil {
+				return nil, common.ErrGroupAlreadyExist
+			}
+			return nil, err
+		}
+		gByte, err := group.Byte()
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, m.Row{Bucket: []byte(AuthBuck), Key: getGKey(group.GName), Value: gByte})
+
+		for _, username := range append(group.Managers, group.Members...) {
+			if username == "" {
+				continue
+			}
+			u, ok := users[username]
+			if !ok {
+				user, err := p.GetUser(username)
+				if err == common.ErrUserNotFound {
+					continue
+				} else if err != nil {
+					return nil, err
+				}
+				u = &user
+				users[username] = u
+			}
+			u.Groups, _ = common.AddIfNotContain(u.Groups, group.GName)
+		}
+	}
+
+	for username, u := range users {
+		uByte, err := u.Byte()
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, m.Row{Bucket: []byte(AuthBuck), Key: getUKey(username), Value: uByte})
+	}
+	return rows, nil
+}
+
 // genAddUsers return the user list which exist in newUsers but not in oldUsers.
 func genAddUsers(oldUsers, newUsers []string) []string {
 	addUsers := make([]string, len(newUsers))
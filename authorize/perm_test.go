@@ -285,9 +285,14 @@ func TestNewPerm(t *testing.T) {
 	g, err := perm.GetGroup(lodaDefaultGName)
 	if err != nil {
 		t.Fatal("GetGroup fail:", err.Error())
-	} else if len(g.Items) != len(model.Templates)+4 {
+	} else if len(g.Items) != len(model.Templates) {
 		t.Fatalf("default Group items not match with expect, %+v:", g)
 	}
+	for _, item := range g.Items {
+		if item == "" {
+			t.Fatalf("default Group should not have empty item, %+v:", g)
+		}
+	}
 	g, err = perm.GetGroup(lodaAdminGName)
 	if err != nil {
 		t.Fatal("GetGroup fail:", err.Error())
@@ -365,3 +370,45 @@ func closePerm(s *store.Store) {
 	s.Close(true)
 	os.RemoveAll(s.Path())
 }
+
+func TestSyncUserGroups(t *testing.T) {
+	s := mustNewStore(t)
+	defer os.RemoveAll(s.Path())
+
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+
+	perm, err := NewPerm(s)
+	if err != nil {
+		t.Fatal("NewPerm fail:", err.Error())
+	}
+	if err = perm.SetUser("user1", "", "enable", ""); err != nil {
+		t.Fatal("SetUser fail:", err.Error())
+	}
+	for _, gName := range []string{"loda-ops", "loda-dev"} {
+		if err = perm.CreateGroup(gName, []string{}, []string{}, []string{}); err != nil {
+			t.Fatal("CreateGroup fail:", err.Error())
+		}
+	}
+
+	managed := []string{"loda-ops", "loda-dev", "loda-unknown"}
+	if err := perm.SyncUserGroups("user1", managed, []string{"loda-ops", "loda-dev"}); err != nil {
+		t.Fatal("SyncUserGroups fail:", err)
+	}
+	if err := perm.SyncUserGroups("user1", managed, []string{"loda-ops"}); err != nil {
+		t.Fatal("SyncUserGroups fail:", err)
+	}
+	u, err := perm.GetUser("user1")
+	if err != nil || len(u.Groups) != 2 || u.Groups[0] != lodaDefaultGName || u.Groups[1] != "loda-ops" {
+		t.Fatalf("groups of user not match with expect: %+v, %v", u, err)
+	}
+	if g, err := perm.GetGroup("loda-dev"); err != nil || len(g.Members) != 0 {
+		t.Fatalf("members of group not match with expect: %+v, %v", g, err)
+	}
+	if g, err := perm.GetGroup("loda-ops"); err != nil || len(g.Members) != 1 {
+		t.Fatalf("members of group not match with expect: %+v, %v", g, err)
+	}
+}
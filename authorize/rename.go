@@ -0,0 +1,146 @@
+package authorize
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/lodastack/registry/common"
+	"github.com/lodastack/registry/tree/node"
+	m "github.com/lodastack/store/model"
+)
+
+// inNs check whether the ns is the ns or under it.
+func inNs(ns, root string) bool {
+	return ns == root || strings.HasSuffix(ns, node.NodeDeli+root)
+}
+
+// RenameGName return the group name after the ns oldNs renamed to newNs.
+// Group not belong to oldNs or its children is returned as it is.
+func RenameGName(gName, oldNs, newNs string) string {
+	if strings.IndexByte(gName, groupNameSep) < 0 {
+		return gName
+	}
+	gNs, name := readGName(gName)
+	oldReverse := reverceNs(oldNs)
+	if gNs != oldReverse && !strings.HasPrefix(gNs, oldReverse+node.NodeDeli) {
+		return gName
+	}
+	return joinGroupName(reverceNs(newNs)+gNs[len(oldReverse):], name)
+}
+
+// renameItem return the permission item after the ns oldNs renamed to newNs.
+// The item is format as ns-resource-method, prefixed by "!" if deny.
+func renameItem(item, oldNs, newNs string) string {
+	if strings.HasPrefix(item, denyPrefix) {
+		return denyPrefix + renameItem(item[len(denyPrefix):], oldNs, newNs)
+	}
+	methodIndex := strings.LastIndexByte(item, '-')
+	if methodIndex <= 0 {
+		return item
+	}
+	resIndex := strings.LastIndexByte(item[:methodIndex], '-')
+	if resIndex <= 0 {
+		return item
+	}
+	ns := item[:resIndex]
+	if !inNs(ns, oldNs) {
+		return item
+	}
+	return ns[:len(ns)-len(oldNs)] + newNs + item[resIndex:]
+}
+
+// RenameNs return the rows which rename the groups and permission items of the
+// ns oldNs and its children to newNs, the groups of users are renamed too.
+// The old group key is cleared by an empty value.
+func (p *perm) RenameNs(oldNs, newNs string) ([]m.Row, error) {
+	if oldNs == "" || newNs == "" {
+		return nil, common.ErrInvalidParam
+	}
+	p.Lock()
+	defer p.Unlock()
+
+	groupMap, err := p.cluster.ViewPrefix([]byte(AuthBuck), getGKey(""))
+	if err != nil {
+		return nil, err
+	}
+	rows := []m.Row{}
+	renamed := map[string]string{}
+	for key, gByte := range groupMap {
+		if len(gByte) == 0 {
+			continue
+		}
+		group := Group{}
+		if err := json.Unmarshal(gByte, &group); err != nil {
+			return nil, err
+		}
+
+		changed := false
+		for i, item := range group.Items {
+			if newItem := renameItem(item, oldNs, newNs); newItem != item {
+				group.Items[i], changed = newItem, true
+			}
+		}
+		for i, grant := range group.Grants {
+			if grant.NS != "" && inNs(grant.NS, oldNs) {
+				group.Grants[i].NS, changed = grant.NS[:len(grant.NS)-len(oldNs)]+newNs, true
+			}
+		}
+		for i, b := range group.Roles {
+			if inNs(b.NS, oldNs) {
+				group.Roles[i].NS, changed = b.NS[:len(b.NS)-len(oldNs)]+newNs, true
+			}
+		}
+		newGName := RenameGName(group.GName, oldNs, newNs)
+		if newGName != group.GName {
+			if _, err := p.GetGroup(newGName); err != common.ErrGroupNotFound {
+				if err == nil {
+					return nil, common.ErrGroupAlreadyExist
+				}
+				return nil, err
+			}
+			renamed[group.GName] = newGName
+			rows = append(rows, m.Row{Bucket: []byte(AuthBuck), Key: []byte(key), Value: []byte{}})
+			group.GName, changed = newGName, true
+		}
+		if !changed {
+			continue
+		}
+		newGByte, err := group.Byte()
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, m.Row{Bucket: []byte(AuthBuck), Key: getGKey(group.GName), Value: newGByte})
+	}
+	if len(renamed) == 0 {
+		return rows, nil
+	}
+
+	userMap, err := p.cluster.ViewPrefix([]byte(AuthBuck), getUKey(""))
+	if err != nil {
+		return nil, err
+	}
+	for key, uByte := range userMap {
+		if len(uByte) == 0 {
+			continue
+		}
+		user := User{}
+		if err := json.Unmarshal(uByte, &user); err != nil {
+			return nil, err
+		}
+		changed := false
+		for i, gName := range user.Groups {
+			if newGName, ok := renamed[gName]; ok {
+				user.Groups[i], changed = newGName, true
+			}
+		}
+		if !changed {
+			continue
+		}
+		newUByte, err := user.Byte()
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, m.Row{Bucket: []byte(AuthBuck), Key: []byte(key), Value: newUByte})
+	}
+	return rows, nil
+}
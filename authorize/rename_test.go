@@ -0,0 +1,60 @@
+package authorize
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenameNs(t *testing.T) {
+	s := mustNewStore(t)
+	defer os.RemoveAll(s.Path())
+
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	perm, err := NewPerm(s)
+	if err != nil {
+		t.Fatal("NewPerm fail:", err.Error())
+	}
+	if err = perm.SetUser("user1", "", "enable", ""); err != nil {
+		t.Fatal("SetUser fail:", err.Error())
+	}
+	opGName := GetNsOpGName("web.product.loda")
+	if err = perm.CreateGroup(opGName, []string{"user1"}, []string{}, perm.AdminGroupItems("web.product.loda")); err != nil {
+		t.Fatal("CreateGroup fail:", err)
+	}
+	if err = perm.CreateGroup("other", []string{}, []string{}, []string{"api.web.product.loda-machine-GET", "loda-machine-GET"}); err != nil {
+		t.Fatal("CreateGroup fail:", err)
+	}
+
+	rows, err := perm.RenameNs("web.product.loda", "web.infra.loda")
+	if err != nil {
+		t.Fatal("RenameNs fail:", err)
+	}
+	if err = s.Batch(rows); err != nil {
+		t.Fatal("Batch fail:", err)
+	}
+
+	if _, err = perm.GetGroup(opGName); err == nil {
+		t.Fatal("old group still exist, not match with expect")
+	}
+	newOpGName := GetNsOpGName("web.infra.loda")
+	g, err := perm.GetGroup(newOpGName)
+	if err != nil || g.GName != newOpGName || !strings.HasPrefix(g.Items[0], "web.infra.loda-") {
+		t.Fatalf("renamed group not match with expect: %+v, %v", g, err)
+	}
+	if g, _ = perm.GetGroup("other"); g.Items[0] != "api.web.infra.loda-machine-GET" || g.Items[1] != "loda-machine-GET" {
+		t.Fatalf("items not match with expect: %+v", g.Items)
+	}
+	u, err := perm.GetUser("user1")
+	if err != nil || u.Groups[len(u.Groups)-1] != newOpGName {
+		t.Fatalf("user groups not match with expect: %+v, %v", u, err)
+	}
+	if ok, _ := perm.Check("user1", "web.infra.loda", "machine", "PUT", "/api/v1/resource"); !ok {
+		t.Fatal("check permission after rename fail")
+	}
+}
@@ -0,0 +1,270 @@
+package authorize
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/lodastack/log"
+	"github.com/lodastack/registry/common"
+	"github.com/lodastack/registry/model"
+	m "github.com/lodastack/store/model"
+)
+
+var (
+	// AdminRole has all permission of the ns.
+	AdminRole = "admin"
+	// ViewerRole could read all resource of the ns except deploy.
+	ViewerRole = "viewer"
+
+	roleBindingSep = "@"
+)
+
+// Role is a named set of grants, the ns of the grants is set by the binding.
+type Role struct {
+	Name    string  `json:"name"`
+	Grants  []Grant `json:"grants"`
+	BuiltIn bool    `json:"builtin,omitempty"`
+}
+
+// RoleBinding bind the role to a ns and its children.
+type RoleBinding struct {
+	Role string `json:"role"`
+	NS   string `json:"ns"`
+}
+
+func getRKey(name string) []byte { return []byte("r-" + name) }
+
+// builtInRoles return the roles defined by registry, could not be changed.
+func builtInRoles() map[string]Role {
+	admin := Role{Name: AdminRole, BuiltIn: true, Grants: []Grant{{Resource: AnyGrant, Method: AnyGrant}}}
+	viewer := Role{Name: ViewerRole, BuiltIn: true, Grants: []Grant{}}
+	for _, res := range model.Templates {
+		if res == model.Deploy {
+			continue
+		}
+		viewer.Grants = append(viewer.Grants, Grant{Resource: res, Method: "GET"})
+	}
+	return map[string]Role{AdminRole: admin, ViewerRole: viewer}
+}
+
+// ParseRoleBinding return the binding of the string format as role@ns.
+// The ns is defaultNs if not set.
+func ParseRoleBinding(s, defaultNs string) (RoleBinding, error) {
+	b := RoleBinding{Role: s, NS: defaultNs}
+	if i := strings.Index(s, roleBindingSep); i >= 0 {
+		b.Role, b.NS = s[:i], s[i+len(roleBindingSep):]
+	}
+	if b.Role == "" || b.NS == "" {
+		return b, common.ErrInvalidParam
+	}
+	return b, nil
+}
+
+// String return the binding at form of role@ns.
+func (b RoleBinding) String() string {
+	return b.Role + roleBindingSep + b.NS
+}
+
+// GetRole return the role by name.
+func (p *perm) GetRole(name string) (Role, error) {
+	if role, ok := builtInRoles()[name]; ok {
+		return role, nil
+	}
+	role := Role{}
+	if name == "" {
+		return role, common.ErrInvalidParam
+	}
+	rByte, err := p.cluster.View([]byte(AuthBuck), getRKey(name))
+	if err != nil {
+		return role, err
+	}
+	if len(rByte) == 0 {
+		return role, common.ErrRoleNotFound
+	}
+	err = json.Unmarshal(rByte, &role)
+	return role, err
+}
+
+// ListRole return all roles ordered by name.
+func (p *perm) ListRole() ([]Role, error) {
+	roleMap, err := p.cluster.ViewPrefix([]byte(AuthBuck), getRKey(""))
+	if err != nil {
+		return nil, err
+	}
+	roles := []Role{}
+	for _, role := range builtInRoles() {
+		roles = append(roles, role)
+	}
+	for _, rByte := range roleMap {
+		if len(rByte) == 0 {
+			continue
+		}
+		role := Role{}
+		if err := json.Unmarshal(rByte, &role); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+	return roles, nil
+}
+
+// SetRole create or update the role by items format as resource-method,
+// prefixed by "!" if deny, e.g. alarm-*.
+func (p *perm) SetRole(name string, items []string) error {
+	if name == "" || strings.ContainsAny(name, roleBindingSep+", ") {
+		return common.ErrInvalidParam
+	}
+	if _, ok := builtInRoles()[name]; ok {
+		return common.ErrInvalidParam
+	}
+	grants, err := ParseItems(items)
+	if err != nil {
+		return err
+	}
+	if len(grants) == 0 {
+		return common.ErrInvalidParam
+	}
+	for _, g := range grants {
+		if g.NS != "" {
+			return common.ErrInvalidParam
+		}
+	}
+	rByte, err := json.Marshal(Role{Name: name, Grants: grants})
+	if err != nil {
+		return err
+	}
+	return p.cluster.Update([]byte(AuthBuck), getRKey(name), rByte)
+}
+
+// RemoveRole remove the role, the bindings of the role is ignored after removed.
+func (p *perm) RemoveRole(name string) error {
+	if _, ok := builtInRoles()[name]; ok {
+		return common.ErrInvalidParam
+	}
+	if _, err := p.GetRole(name); err != nil {
+		return err
+	}
+	return p.cluster.RemoveKey([]byte(AuthBuck), getRKey(name))
+}
+
+// BindRoles set the role bindings of the group.
+func (p *perm) BindRoles(gName string, bindings []RoleBinding) error {
+	p.Lock()
+	defer p.Unlock()
+
+	group, err := p.GetGroup(gName)
+	if err != nil {
+		return err
+	}
+	for _, b := range bindings {
+		if b.NS == "" {
+			return common.ErrInvalidParam
+		}
+		if _, err := p.GetRole(b.Role); err != nil {
+			return err
+		}
+	}
+	group.Roles = bindings
+	gByte, err := group.Byte()
+	if err != nil {
+		return err
+	}
+	return p.cluster.Update([]byte(AuthBuck), getGKey(gName), gByte)
+}
+
+// boundGrant is a grant of the group, Role is set if the grant is from a role binding.
+type boundGrant struct {
+	Grant
+	Role string
+}
+
+// groupGrants return the grants of the group and the grants of its role bindings.
+// The binding of the role not found is ignored.
+func (p *perm) groupGrants(g Group, roles map[string]Role) []boundGrant {
+	grants := []boundGrant{}
+	for _, grant := range g.grants() {
+		grants = append(grants, boundGrant{Grant: grant})
+	}
+	for _, b := range g.Roles {
+		role, ok := roles[b.Role]
+		if !ok {
+			var err error
+			if role, err = p.GetRole(b.Role); err != nil {
+				continue
+			}
+			roles[b.Role] = role
+		}
+		for _, grant := range role.Grants {
+			grant.NS = b.NS
+			grants = append(grants, boundGrant{Grant: grant, Role: b.String()})
+		}
+	}
+	return grants
+}
+
+// isDeployGrant check the grant allow to read or update deploy.
+func isDeployGrant(g Grant) bool {
+	return !g.Deny && g.Resource == model.Deploy && (g.Method == "GET" || g.Method == "PUT")
+}
+
+// migrateRoles migrate the groups saved before role supported.
+// Deploy could be read and updated only by "-op" group before,
+// so the deploy GET/PUT grants of other groups are removed with their items to keep the permission,
+// the removed grants are logged to be granted again if needed.
+func (p *perm) migrateRoles() error {
+	p.Lock()
+	defer p.Unlock()
+
+	groupMap, err := p.cluster.ViewPrefix([]byte(AuthBuck), getGKey(""))
+	if err != nil {
+		return err
+	}
+	rows := []m.Row{}
+	for key, gByte := range groupMap {
+		if len(gByte) == 0 {
+			continue
+		}
+		group := Group{}
+		if err := json.Unmarshal(gByte, &group); err != nil {
+			return err
+		}
+		if group.Roles != nil {
+			continue
+		}
+		group.Roles = []RoleBinding{}
+		grants := group.grants()
+		if !strings.HasSuffix(group.GName, string(groupNameSep)+OP) {
+			kept := []Grant{}
+			for _, g := range grants {
+				if !isDeployGrant(g) {
+					kept = append(kept, g)
+					continue
+				}
+				log.Warningf("migrate roles: remove grant %s of group %s, which only op group had before", g.Item(), group.GName)
+			}
+			grants = kept
+
+			// the items are parsed to grants again on update, drop the same ones.
+			items := []string{}
+			for _, item := range group.Items {
+				if g, err := ParseItem(item); err == nil && isDeployGrant(g) {
+					continue
+				}
+				items = append(items, item)
+			}
+			group.Items = items
+		}
+		group.Grants = grants
+		newGByte, err := group.Byte()
+		if err != nil {
+			return err
+		}
+		rows = append(rows, m.Row{Bucket: []byte(AuthBuck), Key: []byte(key), Value: newGByte})
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return p.cluster.Batch(rows)
+}
@@ -0,0 +1,189 @@
+package authorize
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lodastack/registry/common"
+)
+
+func TestRole(t *testing.T) {
+	s := mustNewStore(t)
+	defer os.RemoveAll(s.Path())
+
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	perm, err := NewPerm(s)
+	if err != nil {
+		t.Fatal("NewPerm fail:", err.Error())
+	}
+
+	for _, items := range [][]string{{}, {"GET"}, {"web.loda-alarm-GET"}} {
+		if err := perm.SetRole("bad", items); err == nil {
+			t.Fatalf("set role with items %v should fail", items)
+		}
+	}
+	if err := perm.SetRole(AdminRole, []string{"alarm-GET"}); err == nil {
+		t.Fatal("built-in role should not be changed")
+	}
+	if err := perm.SetRole("auditor", []string{"*-GET", "!deploy-GET"}); err != nil {
+		t.Fatal("SetRole fail:", err)
+	}
+	if roles, err := perm.ListRole(); err != nil || len(roles) != 3 || roles[1].Name != "auditor" {
+		t.Fatalf("list role not match with expect: %+v, %v", roles, err)
+	}
+
+	if err = perm.SetUser("user1", "", "enable", ""); err != nil {
+		t.Fatal("SetUser fail:", err.Error())
+	}
+	if err = perm.CreateGroup("audit", []string{"user1"}, []string{}, []string{""}); err != nil {
+		t.Fatal("CreateGroup fail:", err)
+	}
+	if err := perm.BindRoles("audit", []RoleBinding{{Role: "unknown", NS: "loda"}}); err != common.ErrRoleNotFound {
+		t.Fatalf("bind unknown role should fail: %v", err)
+	}
+	if err := perm.BindRoles("audit", []RoleBinding{{Role: "auditor", NS: "web.loda"}}); err != nil {
+		t.Fatal("BindRoles fail:", err)
+	}
+	if ok, _ := perm.Check("user1", "api.web.loda", "ns", "GET", ""); !ok {
+		t.Fatal("check role grant fail")
+	}
+	if ok, _ := perm.Check("user1", "api.web.loda", "deploy", "GET", "/api/v1/resource"); ok {
+		t.Fatal("deny grant of role should not pass")
+	}
+
+	// the role binding is ignored after the role removed.
+	if err := perm.RemoveRole("auditor"); err != nil {
+		t.Fatal("RemoveRole fail:", err)
+	}
+	if exp, _ := perm.Explain("user1", "api.web.loda", "ns", "PUT", ""); exp.Allow {
+		t.Fatalf("check removed role should fail: %+v", exp)
+	}
+}
+
+func TestMigrateRoles(t *testing.T) {
+	s := mustNewStore(t)
+	defer os.RemoveAll(s.Path())
+
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	p, err := NewPerm(s)
+	if err != nil {
+		t.Fatal("NewPerm fail:", err.Error())
+	}
+
+	// groups saved by old version, deploy could be read and updated only by the op group.
+	opGName, devGName := GetNsOpGName("web.loda"), GetNsDevGName("web.loda")
+	for _, g := range []Group{
+		{GName: opGName, Managers: []string{}, Members: []string{}, Items: p.AdminGroupItems("web.loda")},
+		{GName: devGName, Managers: []string{}, Members: []string{}, Items: []string{"web.loda-deploy-GET", "web.loda-deploy-PUT", "web.loda-machine-GET"}},
+	} {
+		gByte, _ := json.Marshal(map[string]interface{}{"gname": g.GName, "managers": g.Managers, "members": g.Members, "items": g.Items})
+		if err := s.Update([]byte(AuthBuck), getGKey(g.GName), gByte); err != nil {
+			t.Fatal("save legacy group fail:", err.Error())
+		}
+	}
+	if err := p.(*perm).migrateGrants(); err != nil {
+		t.Fatal("migrate grants fail:", err.Error())
+	}
+	if err := p.(*perm).migrateRoles(); err != nil {
+		t.Fatal("migrate roles fail:", err.Error())
+	}
+
+	if err = p.SetUser("user1", "", "enable", ""); err != nil {
+		t.Fatal("SetUser fail:", err.Error())
+	}
+	if err := p.UpdateMember(devGName, []string{"user1"}, []string{}); err != nil {
+		t.Fatal("UpdateMember fail:", err.Error())
+	}
+	g, _ := p.GetGroup(devGName)
+	if len(g.Grants) != 1 || len(g.Items) != 1 || g.Roles == nil {
+		t.Fatalf("deploy grants and items of dev group should be removed: %+v", g)
+	}
+	// the items are parsed again on update, which should not grant deploy back.
+	if err := p.UpdateItems(devGName, g.Items); err != nil {
+		t.Fatal("UpdateItems fail:", err.Error())
+	}
+	for _, method := range []string{"GET", "PUT"} {
+		if ok, _ := p.Check("user1", "web.loda", "deploy", method, "/api/v1/resource"); ok {
+			t.Fatalf("dev group should not %s deploy", method)
+		}
+	}
+
+	if err := p.UpdateMember(opGName, []string{"user1"}, []string{}); err != nil {
+		t.Fatal("UpdateMember fail:", err.Error())
+	}
+	if ok, _ := p.Check("user1", "web.loda", "deploy", "PUT", "/api/v1/resource"); !ok {
+		t.Fatal("op group should update deploy")
+	}
+}
+
+func TestRestoreGroupRows(t *testing.T) {
+	s := mustNewStore(t)
+	defer os.RemoveAll(s.Path())
+
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	perm, err := NewPerm(s)
+	if err != nil {
+		t.Fatal("NewPerm fail:", err.Error())
+	}
+
+	if err = perm.SetUser("user1", "", "enable", ""); err != nil {
+		t.Fatal("SetUser fail:", err.Error())
+	}
+	gName := GetNsDevGName("web.loda")
+	if err = perm.CreateGroup(gName, []string{"user1"}, []string{}, []string{"web.loda-machine-GET"}); err != nil {
+		t.Fatal("CreateGroup fail:", err)
+	}
+	if err := perm.BindRoles(gName, []RoleBinding{{Role: AdminRole, NS: "web.loda"}}); err != nil {
+		t.Fatal("BindRoles fail:", err)
+	}
+	before, _ := perm.GetGroup(gName)
+
+	// remove the ns and restore it from trash.
+	groups, err := perm.ListNsGroup("web.loda")
+	if err != nil || len(groups) != 1 {
+		t.Fatalf("ListNsGroup not match with expect: %+v, %v", groups, err)
+	}
+	rows, err := perm.RemoveGroupRows([]string{gName})
+	if err != nil {
+		t.Fatal("RemoveGroupRows fail:", err)
+	}
+	if err := s.Batch(rows); err != nil {
+		t.Fatal("Batch fail:", err)
+	}
+	if rows, err = perm.RestoreGroupRows(groups); err != nil {
+		t.Fatal("RestoreGroupRows fail:", err)
+	}
+	if err := s.Batch(rows); err != nil {
+		t.Fatal("Batch fail:", err)
+	}
+
+	after, err := perm.GetGroup(gName)
+	if err != nil {
+		t.Fatal("GetGroup fail:", err)
+	}
+	beforeByte, _ := json.Marshal(before)
+	afterByte, _ := json.Marshal(after)
+	if string(beforeByte) != string(afterByte) {
+		t.Fatalf("restored group not match:\n%s\n%s", beforeByte, afterByte)
+	}
+	if ok, _ := perm.Check("user1", "web.loda", "alarm", "DELETE", ""); !ok {
+		t.Fatal("role binding of the restored group should pass")
+	}
+	if _, err := perm.RestoreGroupRows(groups); err != common.ErrGroupAlreadyExist {
+		t.Fatalf("restore the group exist should fail: %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package authorize
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lodastack/registry/common"
+	m "github.com/lodastack/store/model"
+)
+
+// Kind of the service account, each kind could only access its own API.
+const (
+	ServiceAgent  = "agent"
+	ServiceRouter = "router"
+	ServiceAlarm  = "alarm"
+	ServiceEvent  = "event"
+	ServicePeer   = "peer"
+
+	// ServicePrefix is the prefix of the username of service account.
+	ServicePrefix = "svc-"
+)
+
+// ServiceURIs is the API prefix every kind of service account could access.
+var ServiceURIs = map[string]string{
+	ServiceAgent:  "/api/v1/agent",
+	ServiceRouter: "/api/v1/router",
+	ServiceAlarm:  "/api/v1/alarm",
+	ServiceEvent:  "/api/v1/event",
+	ServicePeer:   "/api/v1/peer",
+}
+
+// ServiceKind return the kind of service the uri belongs to, empty if not a service API.
+func ServiceKind(uri string) string {
+	for kind, prefix := range ServiceURIs {
+		if uri == prefix || strings.HasPrefix(uri, prefix+"/") {
+			return kind
+		}
+	}
+	return ""
+}
+
+// IsService return the user is a service account or not.
+func (u User) IsService() bool {
+	return u.Service != ""
+}
+
+// ServiceAllow check the user could access the service API or not.
+// Service account could only access the API of its kind,
+// the admin could access all service API and other user could access none.
+func (u User) ServiceAllow(uri string) bool {
+	kind := ServiceKind(uri)
+	if kind == "" {
+		return false
+	}
+	if !u.IsService() {
+		_, ok := common.ContainString(u.Groups, lodaAdminGName)
+		return ok
+	}
+	return u.Service == kind
+}
+
+// CreateService create a service account of the kind and issue a token of it,
+// return the token and its secret. Issue a new token if the service account exist.
+func (p *perm) CreateService(name, kind string, ttl time.Duration) (Token, string, error) {
+	if _, ok := ServiceURIs[kind]; !ok || name == "" || strings.ContainsAny(name, ":, ") {
+		return Token{}, "", common.ErrInvalidParam
+	}
+	username := ServicePrefix + name
+	u, err := p.GetUser(username)
+	switch {
+	case err == common.ErrUserNotFound:
+		u = User{Username: username, Service: kind, Groups: []string{}}
+		uByte, err := u.Byte()
+		if err != nil {
+			return Token{}, "", err
+		}
+		if err := p.cluster.Update([]byte(AuthBuck), getUKey(username), uByte); err != nil {
+			return Token{}, "", err
+		}
+	case err != nil:
+		return Token{}, "", err
+	case u.Service != kind:
+		return Token{}, "", common.ErrInvalidParam
+	}
+	return p.CreateToken(username, kind, nil, ttl)
+}
+
+// ListService return all service accounts ordered by username.
+func (p *perm) ListService() ([]User, error) {
+	userMap, err := p.cluster.ViewPrefix([]byte(AuthBuck), getUKey(ServicePrefix))
+	if err != nil {
+		return nil, err
+	}
+	services := []User{}
+	for _, uByte := range userMap {
+		if len(uByte) == 0 {
+			continue
+		}
+		u := User{}
+		if err := json.Unmarshal(uByte, &u); err != nil {
+			return nil, err
+		}
+		if u.IsService() {
+			services = append(services, u)
+		}
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].Username < services[j].Username })
+	return services, nil
+}
+
+// RemoveService remove the service account and all its tokens.
+func (p *perm) RemoveService(name string) error {
+	p.Lock()
+	defer p.Unlock()
+	username := ServicePrefix + name
+	u, err := p.GetUser(username)
+	if err != nil {
+		return err
+	}
+	if !u.IsService() {
+		return common.ErrInvalidParam
+	}
+	tokens, err := p.ListToken(username)
+	if err != nil {
+		return err
+	}
+	rows := []m.Row{{Bucket: []byte(AuthBuck), Key: getUKey(username), Value: []byte{}}}
+	for _, t := range tokens {
+		rows = append(rows, m.Row{Bucket: []byte(AuthBuck), Key: getTKey(t.ID), Value: []byte{}})
+	}
+	return p.cluster.Batch(rows)
+}
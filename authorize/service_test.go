@@ -0,0 +1,83 @@
+package authorize
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lodastack/registry/common"
+)
+
+func TestServiceKind(t *testing.T) {
+	for uri, kind := range map[string]string{
+		"/api/v1/agent":          ServiceAgent,
+		"/api/v1/agent/report":   ServiceAgent,
+		"/api/v1/agents":         "",
+		"/api/v1/peer":           ServicePeer,
+		"/api/v1/event/resource": ServiceEvent,
+		"/api/v1/resource":       "",
+	} {
+		if got := ServiceKind(uri); got != kind {
+			t.Fatalf("kind of %s not match with expect: %q", uri, got)
+		}
+	}
+
+	agent := User{Username: ServicePrefix + "agent", Service: ServiceAgent}
+	admin := User{Username: "admin", Groups: []string{lodaAdminGName}}
+	user := User{Username: "user1", Groups: []string{lodaDefaultGName}}
+	if !agent.ServiceAllow("/api/v1/agent/report") || agent.ServiceAllow("/api/v1/peer") || agent.ServiceAllow("/api/v1/resource") {
+		t.Fatal("service account should only access its own API")
+	}
+	if !admin.ServiceAllow("/api/v1/peer") || user.ServiceAllow("/api/v1/peer") {
+		t.Fatal("only admin could access the service API")
+	}
+}
+
+func TestService(t *testing.T) {
+	s := mustNewStore(t)
+	defer os.RemoveAll(s.Path())
+
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	p, err := NewPerm(s)
+	if err != nil {
+		t.Fatal("NewPerm fail:", err.Error())
+	}
+
+	if _, _, err := p.CreateService("agent1", "unknown", time.Hour); err == nil {
+		t.Fatal("unknown kind should fail")
+	}
+	token, raw, err := p.CreateService("agent1", ServiceAgent, time.Hour)
+	if err != nil {
+		t.Fatal("CreateService fail:", err)
+	}
+	if _, _, err := p.CreateService("agent1", ServicePeer, time.Hour); err == nil {
+		t.Fatal("change the kind of service account should fail")
+	}
+	if _, _, err := p.CreateService("agent1", ServiceAgent, time.Hour); err != nil {
+		t.Fatal("issue another token fail:", err)
+	}
+	authed, err := p.AuthToken(raw)
+	if err != nil || authed.Username != ServicePrefix+"agent1" {
+		t.Fatalf("AuthToken not match with expect: %+v, %v", authed, err)
+	}
+	if u, err := p.GetUser(authed.Username); err != nil || !u.IsService() || !u.ServiceAllow("/api/v1/agent/ns") {
+		t.Fatalf("service account not match with expect: %+v, %v", u, err)
+	}
+	if services, err := p.ListService(); err != nil || len(services) != 1 || services[0].Service != ServiceAgent {
+		t.Fatalf("ListService not match with expect: %+v, %v", services, err)
+	}
+
+	if err := p.RemoveService("agent1"); err != nil {
+		t.Fatal("RemoveService fail:", err)
+	}
+	if _, err := p.AuthToken(raw); err != common.ErrTokenNotFound {
+		t.Fatalf("token %s should be removed with the service account: %v", token.ID, err)
+	}
+	if services, err := p.ListService(); err != nil || len(services) != 0 {
+		t.Fatalf("ListService not match with expect: %+v, %v", services, err)
+	}
+}
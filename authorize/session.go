@@ -0,0 +1,209 @@
+package authorize
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/lodastack/log"
+	"github.com/lodastack/registry/common"
+	"github.com/lodastack/registry/config"
+	m "github.com/lodastack/store/model"
+)
+
+const (
+	// DefaultSessionTTL is the default time a session could be idle before expired.
+	DefaultSessionTTL = 24 * time.Hour
+
+	// sessionTouchInterval is the min interval to renew a session.
+	sessionTouchInterval = time.Minute
+)
+
+// Session is a signin of the user, expired if idle longer than the session TTL.
+// The ID is the hash of the session key, the key itself is not saved.
+type Session struct {
+	ID         string    `json:"id"`
+	Username   string    `json:"username"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"useragent"`
+	CreatedAt  time.Time `json:"createdat"`
+	LastSeenAt time.Time `json:"lastseenat"`
+	ExpireAt   time.Time `json:"expireat"`
+}
+
+func getSKey(id string) []byte { return []byte("s-" + id) }
+
+// SessionID return the ID of the session key.
+func SessionID(key string) string { return hashSecret(key) }
+
+// sessionTTL return the session TTL of the config, DefaultSessionTTL if not set.
+func sessionTTL() time.Duration {
+	if config.C.AuthConf.SessionTTL > 0 {
+		return time.Duration(config.C.AuthConf.SessionTTL) * time.Hour
+	}
+	return DefaultSessionTTL
+}
+
+func (p *perm) saveSession(sess Session) error {
+	sByte, err := json.Marshal(sess)
+	if err != nil {
+		return err
+	}
+	return p.cluster.Update([]byte(AuthBuck), getSKey(sess.ID), sByte)
+}
+
+// CreateSession create a session of the user, return the session key.
+func (p *perm) CreateSession(username, ip, userAgent string) (string, error) {
+	if username == "" {
+		return "", common.ErrInvalidParam
+	}
+	key, err := genSecret()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	sess := Session{ID: SessionID(key), Username: username, IP: ip, UserAgent: userAgent,
+		CreatedAt: now, LastSeenAt: now, ExpireAt: now.Add(sessionTTL())}
+	return key, p.saveSession(sess)
+}
+
+// AuthSession return the session of the key if not expired, and renew it.
+// The session is renewed at most once every sessionTouchInterval,
+// renew fail is only logged because it could fail on the follower.
+func (p *perm) AuthSession(key string) (Session, error) {
+	sess := Session{}
+	if key == "" {
+		return sess, common.ErrInvalidParam
+	}
+	id := SessionID(key)
+	sByte, err := p.cluster.View([]byte(AuthBuck), getSKey(id))
+	if err != nil {
+		return sess, err
+	}
+	if len(sByte) == 0 {
+		return sess, common.ErrSessionNotFound
+	}
+	if err := json.Unmarshal(sByte, &sess); err != nil {
+		return sess, err
+	}
+	now := time.Now()
+	if now.After(sess.ExpireAt) {
+		return sess, common.ErrSessionExpired
+	}
+	if now.Sub(sess.LastSeenAt) > sessionTouchInterval {
+		sess.LastSeenAt, sess.ExpireAt = now, now.Add(sessionTTL())
+		if err := p.renewSession(sess.ID, now); err == common.ErrSessionNotFound {
+			return sess, err
+		} else if err != nil {
+			log.Errorf("renew session of %s fail: %s", sess.Username, err.Error())
+		}
+	}
+	return sess, nil
+}
+
+// renewSession extend the expiry of the session from now.
+// The session is read again under the lock, so that the session revoked meanwhile is not saved again.
+func (p *perm) renewSession(id string, now time.Time) error {
+	p.Lock()
+	defer p.Unlock()
+	sByte, err := p.cluster.View([]byte(AuthBuck), getSKey(id))
+	if err != nil {
+		return err
+	}
+	if len(sByte) == 0 {
+		return common.ErrSessionNotFound
+	}
+	sess := Session{}
+	if err := json.Unmarshal(sByte, &sess); err != nil {
+		return err
+	}
+	sess.LastSeenAt, sess.ExpireAt = now, now.Add(sessionTTL())
+	return p.saveSession(sess)
+}
+
+// ListSession return the sessions of the user ordered by create time, all sessions if username is empty.
+func (p *perm) ListSession(username string) ([]Session, error) {
+	sessMap, err := p.cluster.ViewPrefix([]byte(AuthBuck), getSKey(""))
+	if err != nil {
+		return nil, err
+	}
+	sessions := []Session{}
+	for _, sByte := range sessMap {
+		if len(sByte) == 0 {
+			continue
+		}
+		sess := Session{}
+		if err := json.Unmarshal(sByte, &sess); err != nil {
+			return nil, err
+		}
+		if username == "" || sess.Username == username {
+			sessions = append(sessions, sess)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].CreatedAt.Before(sessions[j].CreatedAt) })
+	return sessions, nil
+}
+
+// RevokeSession remove the session by ID.
+func (p *perm) RevokeSession(id string) error {
+	p.Lock()
+	defer p.Unlock()
+	if id == "" {
+		return common.ErrInvalidParam
+	}
+	sByte, err := p.cluster.View([]byte(AuthBuck), getSKey(id))
+	if err != nil {
+		return err
+	}
+	if len(sByte) == 0 {
+		return common.ErrSessionNotFound
+	}
+	return p.cluster.RemoveKey([]byte(AuthBuck), getSKey(id))
+}
+
+// userSessionRows return the rows to remove all sessions of the user.
+func (p *perm) userSessionRows(username string) ([]m.Row, error) {
+	sessions, err := p.ListSession(username)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]m.Row, 0, len(sessions))
+	for _, sess := range sessions {
+		rows = append(rows, m.Row{Bucket: []byte(AuthBuck), Key: getSKey(sess.ID), Value: []byte{}})
+	}
+	return rows, nil
+}
+
+// RevokeUserSession remove all sessions of the user, return the number of sessions removed.
+func (p *perm) RevokeUserSession(username string) (int, error) {
+	if username == "" {
+		return 0, common.ErrInvalidParam
+	}
+	p.Lock()
+	defer p.Unlock()
+	rows, err := p.userSessionRows(username)
+	if err != nil || len(rows) == 0 {
+		return 0, err
+	}
+	return len(rows), p.cluster.Batch(rows)
+}
+
+// PurgeExpiredSession remove the expired sessions, return the number of sessions removed.
+func (p *perm) PurgeExpiredSession() (int, error) {
+	p.Lock()
+	defer p.Unlock()
+	sessions, err := p.ListSession("")
+	if err != nil {
+		return 0, err
+	}
+	now, rows := time.Now(), []m.Row{}
+	for _, sess := range sessions {
+		if now.After(sess.ExpireAt) {
+			rows = append(rows, m.Row{Bucket: []byte(AuthBuck), Key: getSKey(sess.ID), Value: []byte{}})
+		}
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	return len(rows), p.cluster.Batch(rows)
+}
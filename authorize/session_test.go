@@ -0,0 +1,90 @@
+package authorize
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lodastack/registry/common"
+)
+
+func TestSession(t *testing.T) {
+	s := mustNewStore(t)
+	defer os.RemoveAll(s.Path())
+
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	p, err := NewPerm(s)
+	if err != nil {
+		t.Fatal("NewPerm fail:", err.Error())
+	}
+	if err = p.SetUser("user1", "", "enable", ""); err != nil {
+		t.Fatal("SetUser fail:", err.Error())
+	}
+
+	key1, err := p.CreateSession("user1", "10.0.0.1", "curl")
+	if err != nil {
+		t.Fatal("CreateSession fail:", err)
+	}
+	if _, err := p.CreateSession("user1", "10.0.0.2", "chrome"); err != nil {
+		t.Fatal("CreateSession fail:", err)
+	}
+	sess, err := p.AuthSession(key1)
+	if err != nil || sess.Username != "user1" || sess.IP != "10.0.0.1" || sess.ID != SessionID(key1) {
+		t.Fatalf("AuthSession not match with expect: %+v, %v", sess, err)
+	}
+	if _, err := p.AuthSession("unknown"); err != common.ErrSessionNotFound {
+		t.Fatalf("unknown session should fail: %v", err)
+	}
+	if sessions, err := p.ListSession("user1"); err != nil || len(sessions) != 2 || sessions[0].UserAgent != "curl" {
+		t.Fatalf("ListSession not match with expect: %+v, %v", sessions, err)
+	}
+
+	// the idle session is renewed when used, and expired after TTL.
+	saveSession := func(sess Session) {
+		sByte, _ := json.Marshal(sess)
+		if err := s.Update([]byte(AuthBuck), getSKey(sess.ID), sByte); err != nil {
+			t.Fatal("update session fail:", err)
+		}
+	}
+	sess.LastSeenAt, sess.ExpireAt = time.Now().Add(-time.Hour), time.Now().Add(time.Second)
+	saveSession(sess)
+	if renewed, err := p.AuthSession(key1); err != nil || time.Until(renewed.ExpireAt) < DefaultSessionTTL-time.Minute {
+		t.Fatalf("session should be renewed: %+v, %v", renewed, err)
+	}
+	// the renewal raced with the revocation never create the session again.
+	key2, err := p.CreateSession("user1", "10.0.0.4", "curl")
+	if err != nil {
+		t.Fatal("CreateSession fail:", err)
+	}
+	if err := p.RevokeSession(SessionID(key2)); err != nil {
+		t.Fatal("RevokeSession fail:", err)
+	}
+	if err := p.(*perm).renewSession(SessionID(key2), time.Now()); err != common.ErrSessionNotFound {
+		t.Fatalf("renew revoked session should fail: %v", err)
+	}
+	if _, err := p.AuthSession(key2); err != common.ErrSessionNotFound {
+		t.Fatalf("revoked session should not be renewed: %v", err)
+	}
+
+	sess.ExpireAt = time.Now().Add(-time.Second)
+	saveSession(sess)
+	if n, err := p.PurgeExpiredSession(); err != nil || n != 1 {
+		t.Fatalf("PurgeExpiredSession not match with expect: %d, %v", n, err)
+	}
+	if _, err := p.AuthSession(key1); err != common.ErrSessionNotFound {
+		t.Fatalf("expired session should be purged: %v", err)
+	}
+
+	// remove the user revoke all its sessions.
+	if err := p.RemoveUser("user1"); err != nil {
+		t.Fatal("RemoveUser fail:", err)
+	}
+	if sessions, err := p.ListSession(""); err != nil || len(sessions) != 0 {
+		t.Fatalf("sessions of removed user should be revoked: %+v, %v", sessions, err)
+	}
+}
@@ -0,0 +1,236 @@
+package authorize
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lodastack/log"
+	"github.com/lodastack/registry/common"
+)
+
+const (
+	// TokenPrefix is the prefix of the API token, the token is format as token:id:secret.
+	TokenPrefix = "token:"
+	// DefaultTokenTTL is the default time the API token could be used.
+	DefaultTokenTTL = 90 * 24 * time.Hour
+
+	// tokenTouchInterval is the min interval to save the last used time of a token.
+	tokenTouchInterval = time.Minute
+)
+
+// Token is an API token of a user, could only do what both the user and the scopes allow.
+// The token has all the permission of the user if no scope.
+// Only the hash of the secret is saved.
+type Token struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Username   string    `json:"username"`
+	Hash       string    `json:"hash,omitempty"`
+	Scopes     []Grant   `json:"scopes"`
+	CreatedAt  time.Time `json:"createdat"`
+	ExpireAt   time.Time `json:"expireat"`
+	LastUsedAt time.Time `json:"lastusedat"`
+}
+
+func getTKey(id string) []byte { return []byte("t-" + id) }
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func genSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Allow check the scopes of the token cover the query.
+func (t Token) Allow(ns, resource, method string) bool {
+	if len(t.Scopes) == 0 {
+		return true
+	}
+	return Evaluate(t.Scopes, ns, resource, method)
+}
+
+// Expired check the token is expired or not.
+func (t Token) Expired(now time.Time) bool {
+	return now.After(t.ExpireAt)
+}
+
+func (p *perm) getToken(id string) (Token, error) {
+	t := Token{}
+	if id == "" {
+		return t, common.ErrInvalidParam
+	}
+	tByte, err := p.cluster.View([]byte(AuthBuck), getTKey(id))
+	if err != nil {
+		return t, err
+	}
+	if len(tByte) == 0 {
+		return t, common.ErrTokenNotFound
+	}
+	err = json.Unmarshal(tByte, &t)
+	return t, err
+}
+
+func (p *perm) saveToken(t Token) error {
+	tByte, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	return p.cluster.Update([]byte(AuthBuck), getTKey(t.ID), tByte)
+}
+
+// userToken return the token of the user.
+func (p *perm) userToken(username, id string) (Token, error) {
+	t, err := p.getToken(id)
+	if err != nil {
+		return t, err
+	}
+	if t.Username != username {
+		return t, common.ErrTokenNotFound
+	}
+	return t, nil
+}
+
+// CreateToken create a token of the user, the scopes is format as ns-resource-method.
+// Return the token and the secret, the secret could not be read again.
+func (p *perm) CreateToken(username, name string, scopes []string, ttl time.Duration) (Token, string, error) {
+	t := Token{ID: common.GenUUID(), Name: name, Username: username}
+	if username == "" || name == "" || ttl <= 0 {
+		return t, "", common.ErrInvalidParam
+	}
+	if _, err := p.GetUser(username); err != nil {
+		return t, "", err
+	}
+	grants, err := ParseItems(scopes)
+	if err != nil {
+		return t, "", err
+	}
+	for _, g := range grants {
+		if g.Deny {
+			return t, "", common.ErrInvalidParam
+		}
+	}
+	secret, err := genSecret()
+	if err != nil {
+		return t, "", err
+	}
+	t.Scopes, t.Hash = grants, hashSecret(secret)
+	t.CreatedAt = time.Now()
+	t.ExpireAt = t.CreatedAt.Add(ttl)
+	if err := p.saveToken(t); err != nil {
+		return t, "", err
+	}
+	return t, TokenPrefix + t.ID + ":" + secret, nil
+}
+
+// ListToken return the tokens of the user ordered by create time.
+func (p *perm) ListToken(username string) ([]Token, error) {
+	tokenMap, err := p.cluster.ViewPrefix([]byte(AuthBuck), getTKey(""))
+	if err != nil {
+		return nil, err
+	}
+	tokens := []Token{}
+	for _, tByte := range tokenMap {
+		if len(tByte) == 0 {
+			continue
+		}
+		t := Token{}
+		if err := json.Unmarshal(tByte, &t); err != nil {
+			return nil, err
+		}
+		if t.Username == username {
+			tokens = append(tokens, t)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].CreatedAt.Before(tokens[j].CreatedAt) })
+	return tokens, nil
+}
+
+// RotateToken replace the secret of the token, the old secret could not be used any more.
+// The expiry is extended by ttl from now if ttl is set.
+func (p *perm) RotateToken(username, id string, ttl time.Duration) (Token, string, error) {
+	p.Lock()
+	defer p.Unlock()
+	t, err := p.userToken(username, id)
+	if err != nil {
+		return t, "", err
+	}
+	secret, err := genSecret()
+	if err != nil {
+		return t, "", err
+	}
+	t.Hash = hashSecret(secret)
+	if ttl > 0 {
+		t.ExpireAt = time.Now().Add(ttl)
+	}
+	if err := p.saveToken(t); err != nil {
+		return t, "", err
+	}
+	return t, TokenPrefix + t.ID + ":" + secret, nil
+}
+
+// RevokeToken remove the token.
+func (p *perm) RevokeToken(username, id string) error {
+	p.Lock()
+	defer p.Unlock()
+	if _, err := p.userToken(username, id); err != nil {
+		return err
+	}
+	return p.cluster.RemoveKey([]byte(AuthBuck), getTKey(id))
+}
+
+// AuthToken return the token of the raw token if the secret match and not expired,
+// the last used time is saved at most once every tokenTouchInterval,
+// save fail is only logged because it could fail on the follower.
+func (p *perm) AuthToken(raw string) (Token, error) {
+	idSecret := strings.SplitN(strings.TrimPrefix(raw, TokenPrefix), ":", 2)
+	if !strings.HasPrefix(raw, TokenPrefix) || len(idSecret) != 2 {
+		return Token{}, common.ErrInvalidParam
+	}
+	t, err := p.getToken(idSecret[0])
+	if err != nil {
+		return t, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hashSecret(idSecret[1])), []byte(t.Hash)) != 1 {
+		return t, common.ErrTokenNotFound
+	}
+	now := time.Now()
+	if t.Expired(now) {
+		return t, common.ErrTokenExpired
+	}
+	if now.Sub(t.LastUsedAt) > tokenTouchInterval {
+		t.LastUsedAt = now
+		if err := p.touchToken(t.ID, t.Hash, now); err != nil {
+			log.Errorf("save last used time of token %s fail: %s", t.ID, err.Error())
+		}
+	}
+	return t, nil
+}
+
+// touchToken save the last used time of the token.
+// The token is read again under the lock, and not saved if it is rotated or revoked meanwhile,
+// so that the old secret or the revoked token is never written back.
+func (p *perm) touchToken(id, hash string, now time.Time) error {
+	p.Lock()
+	defer p.Unlock()
+	t, err := p.getToken(id)
+	if err == common.ErrTokenNotFound {
+		return nil
+	}
+	if err != nil || t.Hash != hash {
+		return err
+	}
+	t.LastUsedAt = now
+	return p.saveToken(t)
+}
@@ -0,0 +1,96 @@
+package authorize
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lodastack/registry/common"
+)
+
+func TestToken(t *testing.T) {
+	s := mustNewStore(t)
+	defer os.RemoveAll(s.Path())
+
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	p, err := NewPerm(s)
+	if err != nil {
+		t.Fatal("NewPerm fail:", err.Error())
+	}
+	if err = p.SetUser("user1", "", "enable", ""); err != nil {
+		t.Fatal("SetUser fail:", err.Error())
+	}
+
+	if _, _, err := p.CreateToken("user1", "ci", []string{"!web.loda-alarm-GET"}, time.Hour); err == nil {
+		t.Fatal("deny scope should be invalid")
+	}
+	if _, _, err := p.CreateToken("nobody", "ci", nil, time.Hour); err == nil {
+		t.Fatal("token of unknown user should fail")
+	}
+	token, raw, err := p.CreateToken("user1", "ci", []string{"web.loda-alarm-GET", ""}, time.Hour)
+	if err != nil {
+		t.Fatal("CreateToken fail:", err)
+	}
+	if !strings.HasPrefix(raw, TokenPrefix+token.ID+":") || strings.Contains(token.Hash, strings.TrimPrefix(raw, TokenPrefix+token.ID+":")) {
+		t.Fatalf("token not match with expect: %s, %+v", raw, token)
+	}
+
+	authed, err := p.AuthToken(raw)
+	if err != nil || authed.Username != "user1" || authed.LastUsedAt.IsZero() {
+		t.Fatalf("AuthToken not match with expect: %+v, %v", authed, err)
+	}
+	if !authed.Allow("api.web.loda", "alarm", "get") || authed.Allow("web.loda", "alarm", "PUT") || authed.Allow("loda", "alarm", "GET") {
+		t.Fatal("token scope not match with expect")
+	}
+	if _, err := p.AuthToken(raw + "0"); err != common.ErrTokenNotFound {
+		t.Fatalf("wrong secret should fail: %v", err)
+	}
+
+	// rotate the token, the old secret could not be used.
+	if _, _, err := p.RotateToken("user2", token.ID, 0); err != common.ErrTokenNotFound {
+		t.Fatalf("rotate token of other user should fail: %v", err)
+	}
+	rotated, newRaw, err := p.RotateToken("user1", token.ID, 0)
+	if err != nil {
+		t.Fatal("RotateToken fail:", err)
+	}
+	// the touch of the old secret raced with the rotation never write the old secret back.
+	if err := p.(*perm).touchToken(token.ID, token.Hash, time.Now()); err != nil {
+		t.Fatal("touchToken fail:", err)
+	}
+	if _, err := p.AuthToken(raw); err == nil {
+		t.Fatal("old secret should fail after rotated")
+	}
+	if _, err := p.AuthToken(newRaw); err != nil {
+		t.Fatal("new secret fail:", err)
+	}
+
+	// expired token.
+	_, expiredRaw, err := p.CreateToken("user1", "tmp", nil, time.Millisecond)
+	if err != nil {
+		t.Fatal("CreateToken fail:", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if _, err := p.AuthToken(expiredRaw); err != common.ErrTokenExpired {
+		t.Fatalf("expired token should fail: %v", err)
+	}
+	if tokens, err := p.ListToken("user1"); err != nil || len(tokens) != 2 || tokens[0].Name != "ci" {
+		t.Fatalf("ListToken not match with expect: %+v, %v", tokens, err)
+	}
+
+	if err := p.RevokeToken("user1", token.ID); err != nil {
+		t.Fatal("RevokeToken fail:", err)
+	}
+	// the touch raced with the revocation never create the token again.
+	if err := p.(*perm).touchToken(token.ID, rotated.Hash, time.Now()); err != nil {
+		t.Fatal("touchToken fail:", err)
+	}
+	if _, err := p.AuthToken(newRaw); err != common.ErrTokenNotFound {
+		t.Fatalf("revoked token should fail: %v", err)
+	}
+}
@@ -13,9 +13,12 @@ import (
 type User struct {
 	Username    string   `json:"username"`
 	Mobile      string   `json:"mobile"`
+	Mail        string   `json:"mail,omitempty"`
 	Alert       string   `json:"alert"`
 	AccessToken string   `json:"accesstoken"`
 	Groups      []string `json:"groups"`
+	// Service is the kind of the service account, empty if a human user.
+	Service string `json:"service,omitempty"`
 
 	cluster Cluster `json:"-"`
 }
@@ -83,14 +86,9 @@ func (u *User) SetUser(username, mobile, alert, accesstoken string) error {
 	us, err := u.GetUser(username)
 	if err != nil {
 		// create a user.
-		us.Username = username
+		us = newUser(username)
 		us.Mobile = mobile
 		us.Alert = alert
-		if _, ok := common.ContainString(config.C.CommonConf.Admins, username); ok {
-			us.Groups = []string{lodaAdminGName}
-		} else {
-			us.Groups = []string{lodaDefaultGName}
-		}
 	} else {
 		// update the user.
 		if mobile != "" {
@@ -111,6 +109,17 @@ func (u *User) SetUser(username, mobile, alert, accesstoken string) error {
 	return u.cluster.Update([]byte(AuthBuck), getUKey(username), uByte)
 }
 
+// newUser return a new user in the default group, or the admin group if it is an admin.
+func newUser(username string) User {
+	us := User{Username: username, Alert: "enable"}
+	if _, ok := common.ContainString(config.C.CommonConf.Admins, username); ok {
+		us.Groups = []string{lodaAdminGName}
+	} else {
+		us.Groups = []string{lodaDefaultGName}
+	}
+	return us
+}
+
 // UserRemoveUser remove the user and from the groups the user has.
 func (u *User) UserRemoveUser(username string) ([]string, error) {
 	us, err := u.GetUser(username)
@@ -9,4 +9,5 @@ make
 # make my dir
 mkdir -p $BUILD_ROOT/conf $BUILD_ROOT/bin
 mv cmd/registry/registry $BUILD_ROOT/bin/.
+mv cmd/registry-apply/registry-apply $BUILD_ROOT/bin/.
 cp etc/registry.sample.conf $BUILD_ROOT/conf/.
@@ -0,0 +1,109 @@
+// Command registry-apply apply a desired state document to the registry.
+//
+//	registry-apply -addr 127.0.0.1:8004 -token $TOKEN -f product.json -prune -dry-run
+//
+// The document is the JSON form of model.ApplyDocument:
+//
+//	{"ns": "product.loda", "resources": {"web.product.loda": {"alarm": [...], "collect": [...]}}}
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/lodastack/registry/model"
+)
+
+var (
+	addr   string
+	token  string
+	file   string
+	prune  bool
+	dryRun bool
+)
+
+func init() {
+	flag.StringVar(&addr, "addr", "127.0.0.1:8004", "Set the registry API addr")
+	flag.StringVar(&token, "token", os.Getenv("REGISTRY_TOKEN"), "Set the AuthToken, default is env REGISTRY_TOKEN")
+	flag.StringVar(&file, "f", "", "Set the desired state document file")
+	flag.BoolVar(&prune, "prune", false, "Remove the resources not in the document")
+	flag.BoolVar(&dryRun, "dry-run", false, "Only print the changes")
+}
+
+type response struct {
+	Code int             `json:"httpstatus"`
+	Msg  string          `json:"msg"`
+	Data model.ApplyPlan `json:"data"`
+}
+
+func main() {
+	flag.Parse()
+	if file == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	doc, err := ioutil.ReadFile(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "read document fail: %s\n", err)
+		os.Exit(1)
+	}
+	// check the document locally before send it.
+	if err := json.Unmarshal(doc, &model.ApplyDocument{}); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid document: %s\n", err)
+		os.Exit(1)
+	}
+
+	plan, err := apply(doc)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "apply fail: %s\n", err)
+		os.Exit(1)
+	}
+	for _, c := range plan.Changes {
+		fmt.Printf("%-6s %s %s %s\n", c.Action, c.NS, c.Type, c.Pk)
+	}
+	if plan.DryRun {
+		fmt.Printf("%d changes (dry run)\n", len(plan.Changes))
+	} else {
+		fmt.Printf("%d changes applied\n", len(plan.Changes))
+	}
+}
+
+func apply(doc []byte) (model.ApplyPlan, error) {
+	q := url.Values{}
+	q.Set("prune", fmt.Sprint(prune))
+	q.Set("dryrun", fmt.Sprint(dryRun))
+	req, err := http.NewRequest(http.MethodPost, "http://"+addr+"/api/v1/apply?"+q.Encode(), bytes.NewReader(doc))
+	if err != nil {
+		return model.ApplyPlan{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("AuthToken", token)
+
+	client := &http.Client{Timeout: 60 * time.Second}
+	res, err := client.Do(req)
+	if err != nil {
+		return model.ApplyPlan{}, err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return model.ApplyPlan{}, err
+	}
+
+	var resp response
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return model.ApplyPlan{}, fmt.Errorf("status %d: %s", res.StatusCode, string(body))
+	}
+	if res.StatusCode != http.StatusOK {
+		return model.ApplyPlan{}, fmt.Errorf("status %d: %s", res.StatusCode, resp.Msg)
+	}
+	return resp.Data, nil
+}
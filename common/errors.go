@@ -16,10 +16,19 @@ var (
 	ErrNodeAlreadyExist    = errors.New("node already exist")
 	ErrNoLeafChild         = errors.New("have no leaf child node")
 	ErrNotAllowDel         = errors.New("not allow to be delete")
+	ErrMoveUnderItself     = errors.New("can not move node under itself")
+	ErrTrashNotFound       = errors.New("trash not found")
+	ErrTokenMismatch       = errors.New("confirm token mismatch, please preview again")
 
 	ErrEmptyResource error = errors.New("empty resources")
 
 	ErrGroupNotFound     = errors.New("group not found")
 	ErrGroupAlreadyExist = errors.New("group already exist")
+	ErrEmptyGroupSync    = errors.New("no member synced, refuse to remove all members of the group")
 	ErrUserNotFound      = errors.New("user not found")
+	ErrRoleNotFound      = errors.New("role not found")
+	ErrTokenNotFound     = errors.New("token not found")
+	ErrTokenExpired      = errors.New("token expired")
+	ErrSessionNotFound   = errors.New("session not found")
+	ErrSessionExpired    = errors.New("session expired")
 )
@@ -27,15 +27,20 @@ var (
 )
 
 type Config struct {
-	CommonConf CommonConfig `toml:"common"`
-	HTTPConf   HTTPConfig   `toml:"http"`
-	DataConf   DataConfig   `toml:"data"`
-	LDAPConf   LDAPConfig   `toml:"ldap"`
-	WeworkConf WeworkConfig `toml:"wework"`
-	DNSConf    DNSConfig    `toml:"dns"`
-	LogConf    LogConfig    `toml:"log"`
-	PluginConf PluginConfig `toml:"plugin"`
-	EventConf  EventConfig  `toml:"event"`
+	CommonConf  CommonConfig   `toml:"common"`
+	HTTPConf    HTTPConfig     `toml:"http"`
+	DataConf    DataConfig     `toml:"data"`
+	LDAPConf    LDAPConfig     `toml:"ldap"`
+	WeworkConf  WeworkConfig   `toml:"wework"`
+	DNSConf     DNSConfig      `toml:"dns"`
+	LogConf     LogConfig      `toml:"log"`
+	PluginConf  PluginConfig   `toml:"plugin"`
+	EventConf   EventConfig    `toml:"event"`
+	AuthConf    AuthConfig     `toml:"auth"`
+	OIDCConf    OIDCConfig     `toml:"oidc"`
+	LimitConf   LimitConfig    `toml:"ratelimit"`
+	AuditConf   AuditConfig    `toml:"audit"`
+	ApproveConf ApprovalConfig `toml:"approval"`
 }
 
 type PluginConfig struct {
@@ -56,6 +61,22 @@ type CommonConfig struct {
 	PersistReport   int      `toml:"persistreport"`
 	PID             string   `toml:"pid"`
 	ProductionUsers []string `toml:"productionusers"`
+	// TrashRetention is the hours a removed ns kept in trash before purged.
+	TrashRetention int `toml:"trashretention"`
+}
+
+type AuthConfig struct {
+	// EnforceService require the agent/router/alarm/event/peer API authenticated by service account.
+	EnforceService bool `toml:"enforceservice"`
+	// CertMap map the CN/SAN of client certificate to username, the key is a pattern e.g. *.loda.
+	// The CN/SAN is only the service account name if not mapped, the user must be mapped.
+	CertMap map[string]string `toml:"certmap"`
+	// SessionTTL is the hours a session could be idle before expired, renewed when used.
+	SessionTTL int `toml:"sessionttl"`
+	// Local enable the signin by the password saved in the registry.
+	Local bool `toml:"local"`
+	// StaticTokens is the username by token, the token is used as AuthToken.
+	StaticTokens map[string]string `toml:"statictokens"`
 }
 
 type HTTPConfig struct {
@@ -63,6 +84,11 @@ type HTTPConfig struct {
 	Https bool   `toml:"https"`
 	Cert  string `toml:"cert"`
 	Key   string `toml:"key"`
+	// ClientCA is the CA file to verify the client certificate, disabled if empty.
+	ClientCA string `toml:"clientca"`
+	// TrustedProxies is the IPs or CIDRs of the proxies, the client IP is read from
+	// X-Real-IP and X-Forwarded-For only if the request is from them.
+	TrustedProxies []string `toml:"trustedproxies"`
 }
 
 type DataConfig struct {
@@ -79,6 +105,24 @@ type LDAPConfig struct {
 	Binddn   string `toml:"binddn"`
 	Password string `toml:"password"`
 	Base     string `toml:"base"`
+
+	// SyncInterval is the minutes to sync the groups from LDAP, not sync if 0.
+	SyncInterval int `toml:"syncinterval"`
+	// MemberAttr is the member attribute of the LDAP group, default is member.
+	MemberAttr string `toml:"memberattr"`
+	// MobileAttr and MailAttr are the attributes saved to the created user, default is mobile and mail.
+	MobileAttr string `toml:"mobileattr"`
+	MailAttr   string `toml:"mailattr"`
+	// Groups is the registry groups synced from LDAP.
+	Groups []LDAPGroupConfig `toml:"group"`
+}
+
+// LDAPGroupConfig map a LDAP group to the registry group,
+// the members of the group are the members of DN, or the users match the Filter under the base.
+type LDAPGroupConfig struct {
+	Group  string `toml:"group"`
+	DN     string `toml:"dn"`
+	Filter string `toml:"filter"`
 }
 
 // WeworkConfig is wework config struct
@@ -89,6 +133,80 @@ type WeworkConfig struct {
 	Redirect   string `toml:"redirect"`
 }
 
+// ApprovalConfig is the approval workflow config struct.
+// The request match a rule is kept pending until approved, Expire is the hours it waits.
+type ApprovalConfig struct {
+	Enable bool           `toml:"enable"`
+	Expire int            `toml:"expire"`
+	Rules  []ApprovalRule `toml:"rule"`
+}
+
+// ApprovalRule match the requests need approval, the empty field match all.
+// URI is the API prefix, NS match the ns and its children, Methods is the HTTP methods.
+// ProductionOwner only match the resources whose owner is in the production users.
+// Approvers is the groups whose members could approve.
+type ApprovalRule struct {
+	URI             string   `toml:"uri"`
+	Type            string   `toml:"type"`
+	NS              string   `toml:"ns"`
+	Methods         []string `toml:"methods"`
+	ProductionOwner bool     `toml:"productionowner"`
+	Approvers       []string `toml:"approvers"`
+}
+
+// AuditConfig is the audit log config struct.
+// Retention is the days the entries kept, Skip is the URI prefixes not recorded.
+type AuditConfig struct {
+	Enable    bool     `toml:"enable"`
+	Retention int      `toml:"retention"`
+	Skip      []string `toml:"skip"`
+}
+
+// LimitConfig is the rate limit config struct.
+// The signin is locked for LockoutBase seconds after LockoutThreshold failures, doubled by every more failure up to LockoutMax.
+type LimitConfig struct {
+	Enable bool `toml:"enable"`
+	// Routes is the limits of the route groups, the longest matched prefix is used.
+	Routes []RouteLimitConfig `toml:"route"`
+
+	LockoutThreshold int `toml:"lockoutthreshold"`
+	LockoutBase      int `toml:"lockoutbase"`
+	LockoutMax       int `toml:"lockoutmax"`
+}
+
+// RouteLimitConfig is the token bucket of each IP and each user of the routes with the prefix,
+// the rate is requests per second and not limited if 0.
+type RouteLimitConfig struct {
+	Prefix    string  `toml:"prefix"`
+	IPRate    float64 `toml:"iprate"`
+	IPBurst   int     `toml:"ipburst"`
+	UserRate  float64 `toml:"userrate"`
+	UserBurst int     `toml:"userburst"`
+}
+
+// OIDCConfig is OpenID Connect signin config struct.
+// Redirect is the frontend URL format with the session key and username after signin.
+type OIDCConfig struct {
+	Enable    bool                 `toml:"enable"`
+	Redirect  string               `toml:"redirect"`
+	Providers []OIDCProviderConfig `toml:"provider"`
+}
+
+// OIDCProviderConfig is the config of an OpenID Connect provider.
+// GroupMap map the group claim to registry group, the membership of these groups follow the claim.
+type OIDCProviderConfig struct {
+	Name          string            `toml:"name"`
+	Issuer        string            `toml:"issuer"`
+	ClientID      string            `toml:"clientid"`
+	ClientSecret  string            `toml:"clientsecret"`
+	RedirectURL   string            `toml:"redirecturl"`
+	Scopes        []string          `toml:"scopes"`
+	UsernameClaim string            `toml:"usernameclaim"`
+	GroupsClaim   string            `toml:"groupsclaim"`
+	GroupMap      map[string]string `toml:"groupmap"`
+	AutoCreate    bool              `toml:"autocreate"`
+}
+
 // DNSConig is DNS config struct
 type DNSConfig struct {
 	Enable bool `toml:"enable"`
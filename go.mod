@@ -13,7 +13,7 @@ require (
 	github.com/miekg/dns v1.0.7
 	github.com/pquerna/ffjson v0.0.0-20171002144729-d49c2bc1aa13
 	github.com/satori/go.uuid v1.2.0
-	golang.org/x/crypto v0.0.0-20180820150726-614d502a4dac // indirect
+	golang.org/x/crypto v0.0.0-20180820150726-614d502a4dac
 	gopkg.in/asn1-ber.v1 v1.0.0-20170511165959-379148ca0225 // indirect
 	gopkg.in/ldap.v2 v2.5.1 // indirect
 	labix.org/v2/mgo v0.0.0-20140701140051-000000000287 // indirect
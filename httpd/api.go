@@ -2,6 +2,7 @@ package httpd
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
@@ -16,10 +17,15 @@ import (
 
 	"github.com/lodastack/log"
 	"github.com/lodastack/models"
+	"github.com/lodastack/registry/approval"
+	"github.com/lodastack/registry/audit"
+	"github.com/lodastack/registry/authenticate"
 	"github.com/lodastack/registry/authorize"
 	"github.com/lodastack/registry/common"
 	"github.com/lodastack/registry/config"
 	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/oidc"
+	"github.com/lodastack/registry/ratelimit"
 	"github.com/lodastack/registry/tree"
 	"github.com/lodastack/registry/tree/node"
 	"github.com/lodastack/registry/utils"
@@ -80,6 +86,12 @@ type Cluster interface {
 
 	// Statistics returns statistics for periodic monitoring.
 	Statistics(tags map[string]string) []sm.Statistic
+
+	// WaitForLeader blocks until a leader is detected, or the timeout expires.
+	WaitForLeader(timeout time.Duration) (string, error)
+
+	// Addr returns the address the cluster service is listening on.
+	Addr() string
 }
 
 // Service provides HTTP service.
@@ -89,6 +101,8 @@ type Service struct {
 	https bool
 	cert  string
 	key   string
+	// clientCA is the CA file to verify the client certificate.
+	clientCA string
 
 	router *httprouter.Router
 
@@ -96,6 +110,18 @@ type Service struct {
 	tree    tree.TreeMethod
 	perm    authorize.Perm
 
+	// authn is the chain of the enabled authentication providers.
+	authn      authenticate.Chain
+	oidcLogins *oidc.LoginStore
+
+	// limits is the rate limits of the routes, lockout lock the signin after repeated failures.
+	limits  ratelimit.Routes
+	lockout *ratelimit.Lockout
+
+	auditLog *audit.Log
+	// approvals keep the changes waiting for approval.
+	approvals *approval.Store
+
 	logger *log.Logger
 }
 
@@ -126,40 +152,79 @@ func New(c config.HTTPConfig, cluster Cluster) (*Service, error) {
 		return nil, err
 	}
 
+	// init audit
+	auditLog, err := audit.New(cluster)
+	if err != nil {
+		fmt.Printf("init audit fail: %s\n", err.Error())
+		return nil, err
+	}
+
+	// init approval
+	approvals, err := approval.New(cluster)
+	if err != nil {
+		fmt.Printf("init approval fail: %s\n", err.Error())
+		return nil, err
+	}
+
+	// init the pending OpenID Connect signins
+	var oidcLogins *oidc.LoginStore
+	if config.C.OIDCConf.Enable {
+		if oidcLogins, err = oidc.NewLoginStore(cluster); err != nil {
+			fmt.Printf("init oidc login fail: %s\n", err.Error())
+			return nil, err
+		}
+	}
+
 	return &Service{
-		addr:    c.Bind,
-		https:   c.Https,
-		cert:    c.Cert,
-		key:     c.Key,
-		cluster: cluster,
-		tree:    tree,
-		perm:    perm,
-		router:  httprouter.New(),
-		logger:  log.New("INFO", "http", model.LogBackend),
+		addr:       c.Bind,
+		https:      c.Https,
+		cert:       c.Cert,
+		key:        c.Key,
+		clientCA:   c.ClientCA,
+		cluster:    cluster,
+		tree:       tree,
+		perm:       perm,
+		auditLog:   auditLog,
+		approvals:  approvals,
+		oidcLogins: oidcLogins,
+		authn:      authenticate.New(config.C, perm),
+		limits:     ratelimit.NewRoutes(config.C.LimitConf.Routes),
+		lockout:    ratelimit.NewLockout(config.C.LimitConf),
+		router:     httprouter.New(),
+		logger:     log.New("INFO", "http", model.LogBackend),
 	}, nil
 }
 
 // Start the server
 func (s *Service) Start() error {
+	// the change request is kept pending by the approve of the auth chain, which has no approver without auth.
+	if config.C.ApproveConf.Enable && !authenticate.Enforced(config.C) {
+		return errors.New("approval need the authentication enabled")
+	}
 	s.initHandler()
+	go s.purgeTrash()
+	go s.purgeSession()
+	go s.syncLDAPLoop()
+	go s.purgeLimit()
+	go s.purgeAudit()
+	go s.expireApproval()
 
 	server := http.Server{}
-	if config.C.LDAPConf.Enable {
-		server.Handler = s.accessLog(cors(s.auth(s.router)))
+	if authenticate.Enforced(config.C) {
+		server.Handler = s.accessLog(cors(clearUID(s.limitIP(s.auth(s.limitUser(s.approve(s.audit(s.router))))))))
 	} else {
-		server.Handler = s.accessLog(cors(s.router))
+		server.Handler = s.accessLog(cors(clearUID(s.limitIP(s.audit(s.router)))))
 	}
 
 	// Open listener.
 	if s.https {
-		cert, err := tls.LoadX509KeyPair(s.cert, s.key)
+		certs, err := newCertReloader(s.cert, s.key, s.clientCA)
 		if err != nil {
 			return err
 		}
+		go s.reloadCert(certs)
 
-		listener, err := tls.Listen("tcp", s.addr, &tls.Config{
-			Certificates: []tls.Certificate{cert},
-		})
+		listener, err := tls.Listen("tcp", s.addr, certs.TLSConfig())
 		if err != nil {
 			return err
 		}
@@ -220,14 +285,20 @@ func (s *Service) initHandler() {
 	s.router.PUT("/api/v1/resource/list", s.handleUpdateResourceList)
 	s.router.PUT("/api/v1/resource/move", s.handleResourceMove)
 	s.router.PUT("/api/v1/resource/copy", s.handleResourceCopy)
+	s.router.GET("/api/v1/resource/propagate", s.handleTemplatePropagate)
+	s.router.PUT("/api/v1/resource/propagate", s.handleTemplatePropagate)
+	s.router.GET("/api/v1/resource/effective", s.handleResourceEffective)
 	s.router.DELETE("/api/v1/resource", s.handleResourceDel)
 	s.router.DELETE("/api/v1/resource/list", s.handleRemoveResourceList)
 	s.router.DELETE("/api/v1/resource/collect", s.handleCollectDel)
 
 	s.router.POST("/api/v1/ns", s.handlerNsNew)
 	s.router.PUT("/api/v1/ns", s.handlerNsUpdate)
+	s.router.PUT("/api/v1/ns/move", s.handlerNsMove)
 	s.router.GET("/api/v1/ns", s.handlerNsGet)
 	s.router.DELETE("/api/v1/ns", s.handlerNsDel)
+	s.router.GET("/api/v1/ns/recursive", s.handlerNsDelRecursive)
+	s.router.DELETE("/api/v1/ns/recursive", s.handlerNsDelRecursive)
 
 	s.router.GET("/api/v1/agents", s.handlerAgents)
 	s.router.GET("/api/v1/agent", s.handlerAgent)
@@ -254,6 +325,27 @@ func (s *Service) initHandler() {
 	s.initManageHandler()
 	s.initPermissionHandler()
 	s.initDashboardHandler()
+	s.initApplyHandler()
+	s.initTrashHandler()
+	s.initLabelHandler()
+	s.initStatsHandler()
+	s.initSearchHandler()
+	s.initRoleHandler()
+	s.initTokenHandler()
+	s.initServiceHandler()
+	s.initSessionHandler()
+	s.initOIDCHandler()
+	s.initLDAPSyncHandler()
+	s.initAuditHandler()
+	s.initApprovalHandler()
+}
+
+// clearUID remove the UID header sent by the client, the UID is only set by the auth.
+func clearUID(inner http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Del("UID")
+		inner.ServeHTTP(w, r)
+	})
 }
 
 func cors(inner http.Handler) http.Handler {
@@ -311,17 +403,38 @@ func (s *Service) auth(inner http.Handler) http.Handler {
 			return
 		}
 
+		var uid string
+		// client certificate check, only if no token given.
+		certAuthed := false
 		key := r.Header.Get("AuthToken")
 		if strings.TrimSpace(key) == "" {
-			ReturnUnauthorized(w, "Not Authorized. Please login.")
-			return
+			if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+				ReturnUnauthorized(w, "Not Authorized. Please login.")
+				return
+			}
+			u, err := s.perm.AuthCert(r.TLS.VerifiedChains[0][0])
+			if err != nil {
+				ReturnUnauthorized(w, "Not Authorized. User of the certificate not found.")
+				return
+			}
+			uid, certAuthed = u.Username, true
+		}
+
+		// api token check
+		var apiToken *authorize.Token
+		if !certAuthed && strings.HasPrefix(key, authorize.TokenPrefix) {
+			t, err := s.perm.AuthToken(key)
+			if err != nil {
+				ReturnUnauthorized(w, "Not Authorized. Invalid token: "+err.Error())
+				return
+			}
+			apiToken, uid = &t, t.Username
 		}
 
-		var uid string
 		// access token check
-		AccessTokenAuthed := false
+		AccessTokenAuthed := apiToken != nil || certAuthed
 		userToken := strings.Split(key, ":")
-		if len(userToken) == 2 {
+		if !AccessTokenAuthed && len(userToken) == 2 {
 			uid = userToken[0]
 			if uid != "" {
 				u, err := s.perm.GetUser(uid)
@@ -335,22 +448,58 @@ func (s *Service) auth(inner http.Handler) http.Handler {
 			}
 		}
 
+		// static token check
 		if !AccessTokenAuthed {
-			v := s.cluster.GetSession(key)
-			if v == nil {
-				ReturnUnauthorized(w, "Not Authorized. Please login.")
-				return
+			if id, err := s.authn.Authenticate(authenticate.Credential{Provider: authenticate.StaticName, Token: key}); err == nil {
+				uid, AccessTokenAuthed = id.Username, true
 			}
-			userID, ok := v.(string)
-			if !ok {
+		}
+
+		if !AccessTokenAuthed {
+			sess, err := s.perm.AuthSession(key)
+			if err != nil {
 				ReturnUnauthorized(w, "Not Authorized. Please login.")
 				return
 			}
-			uid = userID
+			uid = sess.Username
 		}
 
 		ns := r.Header.Get("NS")
 		res := r.Header.Get("Resource")
+		if apiToken != nil {
+			// api token could not manage tokens or sessions, and could only do what its scopes allow.
+			// The scoped token could not access the service API, which has no ns to scope.
+			// The ns in the query is checked too, the handlers act on it instead of the NS header.
+			queryNs := r.URL.Query().Get("ns")
+			if r.URL.Path == tokenURI || isSessionURI(r.URL.Path) || r.URL.Path == passwordURI ||
+				(len(apiToken.Scopes) != 0 && authorize.ServiceKind(r.URL.Path) != "") || !apiToken.Allow(ns, res, r.Method) ||
+				(queryNs != "" && !apiToken.Allow(queryNs, res, r.Method)) {
+				ReturnForbidden(w, "Not Authorized. Out of the token scope.")
+				return
+			}
+			// the handlers check the scopes against the ns and resource they act on.
+			r = r.WithContext(context.WithValue(r.Context(), tokenKey{}, *apiToken))
+		}
+
+		// service account could only access the service API of its kind,
+		// the service API could only be accessed by service account and admin.
+		if apiToken != nil || certAuthed || authorize.ServiceKind(r.URL.Path) != "" {
+			u, err := s.perm.GetUser(uid)
+			if err != nil {
+				ReturnUnauthorized(w, "Not Authorized. User not found.")
+				return
+			}
+			if u.IsService() || authorize.ServiceKind(r.URL.Path) != "" {
+				if !u.ServiceAllow(r.URL.Path) {
+					ReturnForbidden(w, "Not Authorized. Please check your permission.")
+					return
+				}
+				r.Header.Set(`UID`, uid)
+				inner.ServeHTTP(w, r)
+				return
+			}
+		}
+
 		var ms []models.Metric
 		m := models.Metric{
 			Name:      "registry.oplog",
@@ -369,6 +518,13 @@ func (s *Service) auth(inner http.Handler) http.Handler {
 		r.Body.Close()
 		r.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBytes))
 		s.logger.Warningf("[%s] access %s path %s NS:%s Res:%s Body:%s", uid, r.Method, r.URL.Path, ns, res, string(bodyBytes))
+		// the permission check API explain the permission itself,
+		// the token, session, password, audit and apply API check the permission by themselves.
+		if selfCheckURI(r.URL.Path) {
+			r.Header.Set(`UID`, uid)
+			inner.ServeHTTP(w, r)
+			return
+		}
 		if ok, err := s.perm.Check(uid, ns, res, r.Method, r.URL.Path); err != nil {
 			s.logger.Errorf("check permission fail, error: %s", err.Error())
 			ReturnServerError(w, err)
@@ -384,10 +540,51 @@ func (s *Service) auth(inner http.Handler) http.Handler {
 	})
 }
 
+// tokenKey is the context key of the api token the request authenticated by.
+type tokenKey struct{}
+
+// tokenAllow check the scopes of the api token the request authenticated by allow the method
+// on the resource of the ns, true if the request is not authenticated by api token.
+func tokenAllow(r *http.Request, ns, resource, method string) bool {
+	t, ok := r.Context().Value(tokenKey{}).(authorize.Token)
+	return !ok || t.Allow(ns, resource, method)
+}
+
+// tokenScoped check the request is authenticated by an api token with scopes.
+func tokenScoped(r *http.Request) bool {
+	t, ok := r.Context().Value(tokenKey{}).(authorize.Token)
+	return ok && len(t.Scopes) != 0
+}
+
 // pass agent or router backend requests, this API shuold be almost desinged in GET method.
+// permCheckURI is the API explain the permission, only authenticate the user.
+const permCheckURI = "/api/v1/perm/check"
+
+// tokenURI is the API manage the api tokens of the user, only authenticate the user.
+const tokenURI = "/api/v1/perm/token"
+
+// sessionURI is the API manage the sessions, only authenticate the user.
+const sessionURI = "/api/v1/perm/session"
+
+// passwordURI is the API set the local password, only authenticate the user.
+const passwordURI = "/api/v1/user/password"
+
+// selfCheckURI check the API check the permission itself, only authenticated by the auth.
+func selfCheckURI(uri string) bool {
+	return uri == permCheckURI || uri == applyURI || uri == tokenURI || isSessionURI(uri) ||
+		uri == passwordURI || uri == auditURI || isApprovalURI(uri)
+}
+
+func isSessionURI(uri string) bool {
+	return uri == sessionURI || strings.HasPrefix(uri, sessionURI+"/")
+}
+
+// The service API is authenticated by service account if EnforceService is set.
 func uriFilter(r *http.Request) bool {
-	var UNAUTH_URI = []string{"/api/v1/user/signin", "/api/v1/user/signout", "/api/v1/user/wework/signin", "/api/v1/agent", "/api/v1/router",
-		"/api/v1/alarm", "/api/v1/event", "/api/v1/peer"}
+	var UNAUTH_URI = []string{"/api/v1/user/signin", "/api/v1/user/signout", "/api/v1/user/wework/signin", oidcURI}
+	if !config.C.AuthConf.EnforceService {
+		UNAUTH_URI = append(UNAUTH_URI, "/api/v1/agent", "/api/v1/router", "/api/v1/alarm", "/api/v1/event", "/api/v1/peer")
+	}
 	for _, uri := range UNAUTH_URI {
 		if strings.HasPrefix(r.RequestURI, uri) {
 			return false
@@ -515,6 +712,41 @@ func (s *Service) handleResourceMove(w http.ResponseWriter, r *http.Request, ps
 	ReturnOK(w, "success")
 }
 
+// handleTemplatePropagate propagate the template of the NonLeaf ns to its descendants.
+// GET only preview the changes, PUT propagate the template.
+func (s *Service) handleTemplatePropagate(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ns := r.FormValue("ns")
+	resType := r.FormValue("type")
+	if ns == "" || resType == "" {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	changes, err := s.tree.PropagateTemplate(ns, resType, r.Method == http.MethodGet)
+	if err != nil {
+		s.logger.Errorf("propagate template %s of ns %s fail: %s", resType, ns, err.Error())
+		ReturnBadRequest(w, err)
+		return
+	}
+	ReturnJson(w, 200, changes)
+}
+
+// handleResourceEffective return the resource of ns annotated with the ancestor template it inherit from.
+func (s *Service) handleResourceEffective(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ns := r.FormValue("ns")
+	resType := r.FormValue("type")
+	if ns == "" || resType == "" {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	effective, err := s.tree.EffectiveResource(ns, resType)
+	if err != nil {
+		s.logger.Errorf("get effective %s of ns %s fail: %s", resType, ns, err.Error())
+		ReturnBadRequest(w, err)
+		return
+	}
+	ReturnJson(w, 200, effective)
+}
+
 func (s *Service) handlerResourceSet(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	var err error
 	buf := new(bytes.Buffer)
@@ -893,6 +1125,14 @@ func (s *Service) handlerNsGet(w http.ResponseWriter, r *http.Request, _ httprou
 	var nodes *node.Node
 	var err error
 	ns := r.FormValue("ns")
+	depth := -1
+	if r.FormValue("depth") != "" {
+		if depth, err = strconv.Atoi(r.FormValue("depth")); err != nil || depth < 0 {
+			ReturnBadRequest(w, ErrInvalidParam)
+			return
+		}
+	}
+	withCount := r.FormValue("include") == "counts"
 
 	if ns == "" {
 		nodes, err = s.tree.AllNodes()
@@ -900,8 +1140,9 @@ func (s *Service) handlerNsGet(w http.ResponseWriter, r *http.Request, _ httprou
 			ReturnServerError(w, err)
 			return
 		}
-	} else {
-		nodes, err = s.tree.GetNodeByNS(ns)
+	} else if nodes, err = s.tree.GetNodeByNS(ns); err == nil && r.Header.Get(`UID`) != "" {
+		// the node is shared with the tree, copy it before filter by permission.
+		nodes = (&node.Node{}).Copy(nodes)
 	}
 	if err != nil && err != common.ErrNodeNotFound {
 		ReturnServerError(w, err)
@@ -920,14 +1161,15 @@ func (s *Service) handlerNsGet(w http.ResponseWriter, r *http.Request, _ httprou
 
 		// init a nodes.
 		nodeHasPermission := &node.Node{
-			node.NodeProperty{
+			NodeProperty: node.NodeProperty{
 				ID:         (*nodes).ID,
 				Name:       (*nodes).Name,
 				Comment:    (*nodes).Comment,
 				Type:       (*nodes).Type,
 				MachineReg: (*nodes).MachineReg,
+				Labels:     node.CopyLabels((*nodes).Labels),
 			},
-			[]*node.Node{}}
+			Children: []*node.Node{}}
 
 		// check the group and set ns to nodeHasPermission.
 		var gNames sort.StringSlice = u.Groups
@@ -962,14 +1204,15 @@ func (s *Service) handlerNsGet(w http.ResponseWriter, r *http.Request, _ httprou
 							break
 						}
 						newNode := &node.Node{
-							node.NodeProperty{
+							NodeProperty: node.NodeProperty{
 								ID:         nodeOnTree.ID,
 								Name:       nodeOnTree.Name,
 								Comment:    nodeOnTree.Comment,
 								Type:       nodeOnTree.Type,
 								MachineReg: nodeOnTree.MachineReg,
+								Labels:     node.CopyLabels(nodeOnTree.Labels),
 							},
-							[]*node.Node{}}
+							Children: []*node.Node{}}
 						nodePointer.Children = append(nodePointer.Children, newNode)
 						nodePointer = newNode
 					} else {
@@ -998,7 +1241,30 @@ func (s *Service) handlerNsGet(w http.ResponseWriter, r *http.Request, _ httprou
 		return
 	}
 
-	ReturnJson(w, 200, nodes)
+	if depth < 0 && !withCount && r.FormValue("format") != "table" {
+		ReturnJson(w, 200, nodes)
+		return
+	}
+	var counts map[string]node.Count
+	if withCount {
+		countNs := ns
+		if countNs == "" {
+			countNs = node.RootNode
+		}
+		if counts, err = s.tree.NodeCounts(countNs, nodes); err != nil {
+			ReturnServerError(w, err)
+			return
+		}
+	}
+	view := node.NewView(nodes, depth, counts)
+	if r.FormValue("format") == "table" {
+		if ns == "" {
+			ns = node.RootNode
+		}
+		ReturnJson(w, 200, view.Table(ns))
+		return
+	}
+	ReturnJson(w, 200, view)
 }
 
 func (s *Service) handlerNsNew(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
@@ -1078,6 +1344,16 @@ func (s *Service) handlerNsUpdate(w http.ResponseWriter, r *http.Request, _ http
 		return
 	}
 
+	// rename the node with its groups and alarms.
+	if name != "" && name != node.Split(ns)[0] {
+		newNs, err := s.moveNs(ns, "", name)
+		if err != nil {
+			ReturnServerError(w, err)
+			return
+		}
+		ns, name = newNs, ""
+	}
+
 	if err := s.tree.UpdateNode(ns, name, comment, machinereg); err != nil {
 		ReturnServerError(w, err)
 		return
@@ -1085,24 +1361,161 @@ func (s *Service) handlerNsUpdate(w http.ResponseWriter, r *http.Request, _ http
 	ReturnOK(w, "success")
 }
 
-func (s *Service) handlerNsDel(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+// moveNs move the ns under newParentNs with newName,
+// rename the groups and permission items of the ns with the tree in one batch.
+func (s *Service) moveNs(ns, newParentNs, newName string) (string, error) {
+	if newParentNs == "" {
+		nsSplit := node.Split(ns)
+		newParentNs = node.Join(nsSplit[1:])
+	}
+	if newName == "" {
+		newName = node.Split(ns)[0]
+	}
+	newNs := node.Join([]string{newName, newParentNs})
+	if len(newNs) > 64-len("collect.") {
+		return "", errors.New("The ns name is to long, please check and re-operate.")
+	}
+	rows, err := s.perm.RenameNs(ns, newNs)
+	if err != nil {
+		s.logger.Errorf("rename group of ns %s to %s fail: %s", ns, newNs, err.Error())
+		return "", err
+	}
+	return s.tree.MoveNode(ns, newParentNs, newName, rows...)
+}
+
+// handlerNsMove move the ns subtree under the parent ns, rename the ns if name is not empty.
+func (s *Service) handlerNsMove(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	ns := r.FormValue("ns")
+	parent := r.FormValue("parent")
+	name := r.FormValue("name")
+	if ns == "" || (parent == "" && name == "") {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	for _, nsLetter := range name {
+		if nsLetter == '-' || (nsLetter >= 'a' && nsLetter <= 'z') || (nsLetter >= '0' && nsLetter <= '9') {
+			continue
+		}
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	// the user should have the permission of the new parent ns too,
+	// and the api token should cover both the ns moved and the new parent.
+	res := r.Header.Get("Resource")
+	if !tokenAllow(r, ns, res, r.Method) || (parent != "" && !tokenAllow(r, parent, res, r.Method)) {
+		ReturnForbidden(w, "Not Authorized. Out of the token scope.")
+		return
+	}
+	if uid := r.Header.Get(`UID`); uid != "" && parent != "" {
+		if ok, _ := s.perm.Check(uid, parent, res, r.Method, r.URL.Path); !ok {
+			ReturnForbidden(w, fmt.Sprintf("Not Authorized. No permission of ns %s.", parent))
+			return
+		}
+	}
 
-	if err := s.tree.RemoveNode(ns); err != nil {
+	newNs, err := s.moveNs(ns, parent, name)
+	if err != nil {
 		ReturnServerError(w, err)
 		return
 	}
+	ReturnJson(w, 200, newNs)
+}
+
+func (s *Service) handlerNsDel(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ns := r.FormValue("ns")
+
+	// move the ns and its groups to trash, could be restored before purged.
 	gList, err := s.perm.ListNsGroup(ns)
 	if err != nil {
 		ReturnServerError(w, err)
 		return
 	}
-	for _, g := range gList {
-		if err := s.perm.RemoveGroup(g.GName); err != nil {
-			log.Errorf("remove ns group fail: %s", err)
+	gNames := make([]string, len(gList))
+	for i, g := range gList {
+		gNames[i] = g.GName
+	}
+	rows, err := s.perm.RemoveGroupRows(gNames)
+	if err != nil {
+		ReturnServerError(w, err)
+		return
+	}
+	gByte, err := json.Marshal(gList)
+	if err != nil {
+		ReturnServerError(w, err)
+		return
+	}
+	trash, err := s.tree.TrashNode(ns, r.Header.Get(`UID`), trashRetention(), gByte, rows...)
+	if err != nil {
+		ReturnServerError(w, err)
+		return
+	}
+	ReturnJson(w, 200, trash.ID)
+}
+
+// removePlan return the plan of removing the ns and its children with the token, and the groups of the nodes.
+func (s *Service) removePlan(ns string, moveToPool bool) (model.RemovePlan, []authorize.Group, error) {
+	groups := []authorize.Group{}
+	plan, err := s.tree.RemovePreview(ns, moveToPool)
+	if err != nil {
+		return plan, groups, err
+	}
+	for _, nodeNs := range plan.NsList() {
+		gList, err := s.perm.ListNsGroup(nodeNs)
+		if err != nil {
+			return plan, groups, err
+		}
+		for _, g := range gList {
+			plan.Groups = append(plan.Groups, g.GName)
 		}
+		groups = append(groups, gList...)
 	}
-	ReturnOK(w, "success")
+	sort.Strings(plan.Groups)
+	plan.Token = plan.GenToken()
+	return plan, groups, nil
+}
+
+// handlerNsDelRecursive move the ns with all its children, machines, alarms, dashboards and groups to trash.
+// GET return the preview and the confirm token, DELETE remove the ns with the token and return the trash ID.
+// The machines are moved to the pool node if movetopool is true.
+func (s *Service) handlerNsDelRecursive(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ns := r.FormValue("ns")
+	moveToPool := r.FormValue("movetopool") == "true"
+	if ns == "" {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	plan, groups, err := s.removePlan(ns, moveToPool)
+	if err != nil {
+		s.logger.Errorf("preview remove ns %s fail: %s", ns, err.Error())
+		ReturnBadRequest(w, err)
+		return
+	}
+	if r.Method == http.MethodGet {
+		ReturnJson(w, 200, plan)
+		return
+	}
+
+	if token := r.FormValue("token"); token == "" || token != plan.Token {
+		ReturnBadRequest(w, common.ErrTokenMismatch)
+		return
+	}
+	rows, err := s.perm.RemoveGroupRows(plan.Groups)
+	if err != nil {
+		s.logger.Errorf("remove group of ns %s fail: %s", ns, err.Error())
+		ReturnServerError(w, err)
+		return
+	}
+	gByte, err := json.Marshal(groups)
+	if err != nil {
+		ReturnServerError(w, err)
+		return
+	}
+	trash, err := s.tree.RemoveNodeRecursive(plan, r.Header.Get(`UID`), trashRetention(), gByte, rows...)
+	if err != nil {
+		ReturnServerError(w, err)
+		return
+	}
+	ReturnJson(w, 200, trash.ID)
 }
 
 func (s *Service) handlerAgents(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
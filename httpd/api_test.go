@@ -0,0 +1,201 @@
+package httpd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lodastack/registry/approval"
+	"github.com/lodastack/registry/config"
+	"github.com/lodastack/registry/tree/node"
+	"github.com/lodastack/registry/tree/test_sample"
+
+	"github.com/lodastack/store/store"
+)
+
+// testCluster is the single node store used by the service in test.
+type testCluster struct {
+	*store.Store
+}
+
+func (c testCluster) Peers() (map[string]map[string]string, error) {
+	return map[string]map[string]string{}, nil
+}
+
+// mustNewService return the service on a single node store, the user admin is the root admin.
+// The static token of the users are "<username>-token".
+func mustNewService(t *testing.T, users ...string) (*Service, func()) {
+	s := test_sample.MustNewStore(t)
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	s.WaitForLeader(10 * time.Second)
+
+	c := config.C
+	config.C.CommonConf.Admins = []string{"admin"}
+	config.C.AuthConf.StaticTokens = map[string]string{"admin-token": "admin"}
+	for _, u := range users {
+		config.C.AuthConf.StaticTokens[u+"-token"] = u
+	}
+	svc, err := New(config.HTTPConfig{}, testCluster{s})
+	if err != nil {
+		t.Fatalf("new service fail: %s", err.Error())
+	}
+	svc.logger.LogToStderr()
+	for _, u := range users {
+		if err := svc.perm.SetUser(u, "", "enable", ""); err != nil {
+			t.Fatalf("create user %s fail: %s", u, err.Error())
+		}
+	}
+	svc.initHandler()
+	return svc, func() {
+		config.C = c
+		s.Close(true)
+		os.RemoveAll(s.Path())
+	}
+}
+
+// do serve the request through the auth middleware as the token.
+func do(h http.Handler, method, uri, token, body string, header map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, uri, strings.NewReader(body))
+	r.Header.Set("AuthToken", token)
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestAuthServiceTokenScope(t *testing.T) {
+	svc, cleanup := mustNewService(t)
+	defer cleanup()
+	config.C.AuthConf.EnforceService = true
+	h := svc.auth(svc.router)
+
+	_, unscoped, err := svc.perm.CreateToken("admin", "all", nil, time.Hour)
+	if err != nil {
+		t.Fatalf("create token fail: %s", err.Error())
+	}
+	_, scoped, err := svc.perm.CreateToken("admin", "machine", []string{"loda-machine-GET"}, time.Hour)
+	if err != nil {
+		t.Fatalf("create token fail: %s", err.Error())
+	}
+	header := map[string]string{"NS": "loda", "Resource": "machine"}
+
+	if w := do(h, "GET", "/api/v1/peer", unscoped, "", nil); w.Code != http.StatusOK {
+		t.Fatalf("unscoped token of admin should access the service API: %d %s", w.Code, w.Body.String())
+	}
+	if w := do(h, "GET", "/api/v1/peer", scoped, "", header); w.Code != http.StatusForbidden {
+		t.Fatalf("scoped token should not access the service API: %d %s", w.Code, w.Body.String())
+	}
+	if w := do(h, "GET", "/api/v1/resource?ns=loda&type=machine", scoped, "", header); w.Code != http.StatusOK {
+		t.Fatalf("scoped token should access the resource in scope: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestAuthTokenScopeFormNS(t *testing.T) {
+	svc, cleanup := mustNewService(t)
+	defer cleanup()
+	config.C.ApproveConf = config.ApprovalConfig{Enable: true, Rules: []config.ApprovalRule{
+		{URI: "/api/v1/resource", Methods: []string{"PUT"}, NS: "b.loda"},
+	}}
+	h := svc.auth(svc.approve(svc.router))
+	for _, name := range []string{"a", "b"} {
+		if _, err := svc.tree.NewNode(name, "", node.RootNode, node.Leaf); err != nil {
+			t.Fatalf("create ns %s fail: %s", name, err.Error())
+		}
+	}
+	_, scoped, err := svc.perm.CreateToken("admin", "a", []string{"a.loda-*-*"}, time.Hour)
+	if err != nil {
+		t.Fatalf("create token fail: %s", err.Error())
+	}
+	// the NS header is in the scope, but the ns the handler act on is not.
+	header := map[string]string{"NS": "a.loda", "Resource": "machine"}
+
+	doc := `{"ns":"loda","resources":{"%s":{"machine":[{"hostname":"h1","ip":"10.0.0.1"}]}}}`
+	if w := do(h, "POST", applyURI, scoped, strings.Replace(doc, "%s", "b.loda", 1), header); w.Code != http.StatusForbidden {
+		t.Fatalf("scoped token should not apply to the ns out of scope: %d %s", w.Code, w.Body.String())
+	}
+	if w := do(h, "POST", applyURI, scoped, strings.Replace(doc, "%s", "a.loda", 1), header); w.Code != http.StatusOK {
+		t.Fatalf("scoped token should apply to the ns in scope: %d %s", w.Code, w.Body.String())
+	}
+
+	formHeader := map[string]string{"NS": "a.loda", "Resource": "ns", "Content-Type": "application/x-www-form-urlencoded"}
+	if w := do(h, "PUT", "/api/v1/ns/move", scoped, "ns=b.loda&name=c", formHeader); w.Code != http.StatusForbidden {
+		t.Fatalf("scoped token should not move the ns out of scope: %d %s", w.Code, w.Body.String())
+	}
+	if w := do(h, "PUT", "/api/v1/ns/move?ns=a.loda&parent=b.loda", scoped, "", formHeader); w.Code != http.StatusForbidden {
+		t.Fatalf("scoped token should not move the ns to the parent out of scope: %d %s", w.Code, w.Body.String())
+	}
+	if w := do(h, "GET", "/api/v1/resource?ns=b.loda&type=machine", scoped, "", header); w.Code != http.StatusForbidden {
+		t.Fatalf("scoped token should not read the ns in query out of scope: %d %s", w.Code, w.Body.String())
+	}
+
+	auditHeader := map[string]string{"NS": "a.loda", "Resource": "group"}
+	if w := do(h, "GET", auditURI, scoped, "", auditHeader); w.Code != http.StatusForbidden {
+		t.Fatalf("scoped token should not query the audit of all ns: %d %s", w.Code, w.Body.String())
+	}
+	if w := do(h, "GET", auditURI+"?ns=a.loda", scoped, "", auditHeader); w.Code != http.StatusOK {
+		t.Fatalf("scoped token should query the audit of the ns in scope: %d %s", w.Code, w.Body.String())
+	}
+
+	// the change request of the ns out of scope could not be seen or reviewed by the scoped token.
+	w := do(h, "PUT", "/api/v1/resource?ns=b.loda&type=machine&resourceid=x", "admin-token", "{}",
+		map[string]string{"NS": "b.loda", "Resource": "machine"})
+	resp := struct {
+		Data approval.Request `json:"data"`
+	}{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusAccepted {
+		t.Fatalf("request should be pending: %d %s", w.Code, w.Body.String())
+	}
+	if w := do(h, "PUT", "/api/v1/resource?type=machine&resourceid=x", scoped, "ns=b.loda",
+		map[string]string{"NS": "a.loda", "Resource": "machine", "Content-Type": "application/x-www-form-urlencoded"}); w.Code != http.StatusForbidden {
+		t.Fatalf("scoped token should not request the change out of scope: %d %s", w.Code, w.Body.String())
+	}
+	if w := do(h, "GET", approvalURI+"?id="+resp.Data.ID, scoped, "", header); w.Code != http.StatusForbidden {
+		t.Fatalf("scoped token should not read the change request out of scope: %d %s", w.Code, w.Body.String())
+	}
+	if w := do(h, "GET", approvalURI+"/list", scoped, "", header); w.Code != http.StatusOK || strings.Contains(w.Body.String(), resp.Data.ID) {
+		t.Fatalf("scoped token should not list the change request out of scope: %d %s", w.Code, w.Body.String())
+	}
+	if w := do(h, "PUT", approvalURI+"?action=approve&id="+resp.Data.ID, scoped, "", header); w.Code != http.StatusForbidden {
+		t.Fatalf("scoped token should not approve the change request out of scope: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestNsGetLabels(t *testing.T) {
+	svc, cleanup := mustNewService(t, "user1")
+	defer cleanup()
+	h := svc.auth(svc.router)
+
+	if _, err := svc.tree.NewNode("a", "", node.RootNode, node.NonLeaf); err != nil {
+		t.Fatalf("create ns fail: %s", err.Error())
+	}
+	if _, err := svc.tree.NewNode("x", "", "a.loda", node.Leaf); err != nil {
+		t.Fatalf("create ns fail: %s", err.Error())
+	}
+	if err := svc.tree.SetNodeLabels("a.loda", map[string]string{"env": "prod"}); err != nil {
+		t.Fatalf("set labels fail: %s", err.Error())
+	}
+	if err := svc.perm.CreateGroup("loda.a.x-g1", []string{"user1"}, []string{"user1"},
+		[]string{"x.a.loda-ns-GET"}); err != nil {
+		t.Fatalf("create group fail: %s", err.Error())
+	}
+
+	// the ns on the path to the permitted ns keeps its labels.
+	w := do(h, "GET", "/api/v1/ns", "user1-token", "", map[string]string{"NS": "x.a.loda", "Resource": "ns"})
+	resp := struct {
+		Data node.Node `json:"data"`
+	}{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || len(resp.Data.Children) != 1 {
+		t.Fatalf("user should get the tree of the ns it has permission: %d %s", w.Code, w.Body.String())
+	}
+	if a := resp.Data.Children[0]; a.Name != "a" || a.Labels["env"] != "prod" {
+		t.Fatalf("labels of the ns should be kept: %+v", a)
+	}
+}
@@ -0,0 +1,84 @@
+package httpd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/lodastack/registry/authenticate"
+	"github.com/lodastack/registry/config"
+	"github.com/lodastack/registry/model"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// applyURI is the API apply the desired state document, which check the permission of every ns itself.
+const applyURI = "/api/v1/apply"
+
+func (s *Service) initApplyHandler() {
+	s.router.POST(applyURI, s.handlerApply)
+}
+
+// handlerApply apply the desired state document to a ns subtree.
+// Query parameter prune=true remove the resources not in the document,
+// dryrun=true only return the changes.
+func (s *Service) handlerApply(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	buf := new(bytes.Buffer)
+	if _, err := buf.ReadFrom(r.Body); err != nil {
+		ReturnBadRequest(w, err)
+		return
+	}
+	doc := model.ApplyDocument{}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		ReturnBadRequest(w, err)
+		return
+	}
+	if doc.NS == "" || len(doc.Resources) == 0 {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	prune := r.FormValue("prune") == "true"
+	dryRun := r.FormValue("dryrun") == "true"
+
+	// the document may touch many ns and resource types, check every one of them.
+	uid := r.Header.Get(`UID`)
+	if uid == "" && authenticate.Enforced(config.C) {
+		ReturnUnauthorized(w, "Not Authorized. Please login.")
+		return
+	}
+	methods := []string{http.MethodPut, http.MethodPost}
+	if prune {
+		methods = append(methods, http.MethodDelete)
+	}
+	for ns, resMap := range doc.Resources {
+		for resType := range resMap {
+			for _, method := range methods {
+				if !tokenAllow(r, ns, resType, method) {
+					ReturnForbidden(w, fmt.Sprintf("Not Authorized. %s of %s in ns %s is out of the token scope.", method, resType, ns))
+					return
+				}
+			}
+		}
+	}
+	if uid != "" {
+		for ns, resMap := range doc.Resources {
+			for resType := range resMap {
+				for _, method := range methods {
+					if ok, _ := s.perm.Check(uid, ns, resType, method, r.URL.Path); !ok {
+						ReturnForbidden(w, fmt.Sprintf("Not Authorized. No %s permission of %s in ns %s.", method, resType, ns))
+						return
+					}
+				}
+			}
+		}
+	}
+
+	plan, err := s.tree.Apply(doc, prune, dryRun)
+	if err != nil {
+		s.logger.Errorf("apply ns %s fail: %s", doc.NS, err.Error())
+		ReturnBadRequest(w, err)
+		return
+	}
+	ReturnJson(w, 200, plan)
+}
@@ -0,0 +1,25 @@
+package httpd
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestApplyThroughAuth(t *testing.T) {
+	svc, cleanup := mustNewService(t, "guest")
+	defer cleanup()
+	h := svc.auth(svc.router)
+
+	// the document is sent without NS/Resource header as registry-apply does.
+	doc := `{"ns":"pool.loda","resources":{"pool.loda":{"machine":[{"hostname":"h1","ip":"10.0.0.1"}]}}}`
+	header := map[string]string{"Content-Type": "application/json"}
+	if w := do(h, "POST", applyURI+"?dryrun=true", "guest-token", doc, header); w.Code != http.StatusForbidden {
+		t.Fatalf("user without permission of the ns should not apply: %d %s", w.Code, w.Body.String())
+	}
+	if w := do(h, "POST", applyURI, "admin-token", doc, header); w.Code != http.StatusOK {
+		t.Fatalf("admin should apply the document: %d %s", w.Code, w.Body.String())
+	}
+	if rl, err := svc.tree.GetResourceList("pool.loda", "machine"); err != nil || len(*rl) != 1 || (*rl)[0]["hostname"] != "h1" {
+		t.Fatalf("document not applied: %+v %v", rl, err)
+	}
+}
@@ -0,0 +1,435 @@
+package httpd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/lodastack/registry/approval"
+	"github.com/lodastack/registry/authorize"
+	"github.com/lodastack/registry/common"
+	"github.com/lodastack/registry/config"
+	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/tree/node"
+)
+
+const (
+	// approvalURI is the API manage the change requests, which check the permission itself.
+	approvalURI = "/api/v1/approval"
+
+	// defaultApprovalExpire is the hours a change request waits for approval if not configured.
+	defaultApprovalExpire  = 72
+	approvalExpireInterval = 10 * time.Minute
+
+	// maxApprovalResult is the max length of the response saved as the result.
+	maxApprovalResult = 1024
+)
+
+// approvedKey is the context key of the approved request replayed, which is not gated again.
+type approvedKey struct{}
+
+func approvalExpire() time.Duration {
+	hours := config.C.ApproveConf.Expire
+	if hours <= 0 {
+		hours = defaultApprovalExpire
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+func isApprovalURI(uri string) bool {
+	return uri == approvalURI || strings.HasPrefix(uri, approvalURI+"/")
+}
+
+func (s *Service) initApprovalHandler() {
+	s.router.GET(approvalURI+"/list", s.HandlerApprovalList)
+	s.router.GET(approvalURI, s.HandlerApprovalGet)
+	s.router.PUT(approvalURI, s.HandlerApprovalReview)
+	s.router.DELETE(approvalURI, s.HandlerApprovalCancel)
+}
+
+// matchURI check the uri is the prefix or under it.
+func matchURI(uri, prefix string) bool {
+	return prefix == "" || uri == prefix || strings.HasPrefix(uri, strings.TrimSuffix(prefix, "/")+"/")
+}
+
+// matchNS check the ns is the ns of the rule or its children.
+func matchNS(ns, ruleNS string) bool {
+	return ruleNS == "" || ns == ruleNS || strings.HasSuffix(ns, node.NodeDeli+ruleNS)
+}
+
+// productionOwned check whether the owner of any resource changed is a production user,
+// the owner is read from the body or the resources already exist.
+func (s *Service) productionOwned(targets []auditTarget, body []byte) bool {
+	for _, param := range bodyParams(body) {
+		if isProductionUsers(param.UpdateMap["owner"]) || isProductionUsers(param.R["owner"]) {
+			return true
+		}
+	}
+	for _, t := range targets {
+		if t.NS == "" || t.Type == "" || t.ResourceID == "" {
+			continue
+		}
+		resources, err := s.tree.GetResource(t.NS, t.Type, strings.Split(t.ResourceID, ",")...)
+		if err != nil {
+			continue
+		}
+		for _, res := range resources {
+			if owner, _ := res.ReadProperty("owner"); isProductionUsers(owner) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// approvalTargets return what the request change, the routes not carry the ns in the params are resolved by the route:
+// the apply document, the ns of the trash, and both the source and destination of the move and copy.
+func (s *Service) approvalTargets(r *http.Request, body []byte) []auditTarget {
+	targets := auditTargets(r, body)
+	switch r.URL.Path {
+	case applyURI:
+		doc := model.ApplyDocument{}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			break
+		}
+		for ns, resMap := range doc.Resources {
+			for resType := range resMap {
+				targets = append(targets, auditTarget{NS: ns, Type: resType})
+			}
+		}
+	case "/api/v1/ns/trash":
+		if trash, err := s.tree.GetTrash(r.FormValue("id")); err == nil {
+			targets = append(targets, auditTarget{NS: trash.NS})
+		}
+	case "/api/v1/ns/move":
+		if parent := r.FormValue("parent"); parent != "" {
+			targets = append(targets, auditTarget{NS: parent})
+		}
+	case "/api/v1/resource/move", "/api/v1/resource/copy":
+		resType := r.FormValue("type")
+		targets = append(targets,
+			auditTarget{NS: r.FormValue("from"), Type: resType, ResourceID: r.FormValue("resourceid")},
+			auditTarget{NS: r.FormValue("to"), Type: resType})
+	}
+	return targets
+}
+
+// approvalRule return the rule the request match, nil if the request need no approval.
+func (s *Service) approvalRule(r *http.Request, targets []auditTarget, body []byte) *config.ApprovalRule {
+	for i, rule := range config.C.ApproveConf.Rules {
+		if !matchURI(r.URL.Path, rule.URI) {
+			continue
+		}
+		if len(rule.Methods) != 0 {
+			if _, ok := common.ContainString(rule.Methods, r.Method); !ok {
+				continue
+			}
+		}
+		matched := []auditTarget{}
+		for _, t := range targets {
+			if (rule.Type == "" || t.Type == rule.Type) && matchNS(t.NS, rule.NS) {
+				matched = append(matched, t)
+			}
+		}
+		if len(matched) == 0 && (rule.Type != "" || rule.NS != "") {
+			continue
+		}
+		if rule.ProductionOwner && !s.productionOwned(matched, body) {
+			continue
+		}
+		return &config.C.ApproveConf.Rules[i]
+	}
+	return nil
+}
+
+// approve keep the request match the approval rules pending, instead of applying it.
+func (s *Service) approve(inner http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !config.C.ApproveConf.Enable || r.Method == http.MethodGet || r.Method == http.MethodHead ||
+			r.Method == http.MethodOptions || isApprovalURI(r.URL.Path) || r.Context().Value(approvedKey{}) != nil {
+			inner.ServeHTTP(w, r)
+			return
+		}
+		body := readBody(r)
+		targets := s.approvalTargets(r, body)
+		rule := s.approvalRule(r, targets, body)
+		if rule == nil {
+			inner.ServeHTTP(w, r)
+			return
+		}
+		// the handler is not reached until approved, check the api token against what the request change.
+		if !tokenAllowTargets(r, targets, r.Header.Get("Resource"), r.Method) {
+			ReturnForbidden(w, "Not Authorized. Out of the token scope.")
+			return
+		}
+
+		req := approval.Request{
+			Requester: r.Header.Get("UID"),
+			Action:    auditAction(r.Method, r.URL.Path),
+			Approvers: rule.Approvers,
+			Method:    r.Method,
+			URI:       r.URL.Path,
+			Query:     r.URL.RawQuery,
+			Header:    map[string]string{},
+			Body:      string(body),
+		}
+		if len(targets) != 0 {
+			req.NS, req.Type = targets[0].NS, targets[0].Type
+		}
+		for _, k := range []string{"Content-Type", "NS", "Resource"} {
+			if v := r.Header.Get(k); v != "" {
+				req.Header[k] = v
+			}
+		}
+		created, err := s.approvals.Create(req, approvalExpire())
+		if err != nil {
+			s.logger.Errorf("create change request of %s %s fail: %s", r.Method, r.URL.Path, err.Error())
+			ReturnServerError(w, err)
+			return
+		}
+		ReturnJson(w, http.StatusAccepted, created)
+	})
+}
+
+// approvalRecorder record the response of the change request applied.
+type approvalRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *approvalRecorder) Header() http.Header { return r.header }
+
+func (r *approvalRecorder) Write(b []byte) (int, error) { return r.body.Write(b) }
+
+func (r *approvalRecorder) WriteHeader(code int) { r.status = code }
+
+// allowApproved check the requester still has the permission of the request, as the auth check it.
+// The permission may be changed while the request is pending.
+func (s *Service) allowApproved(req approval.Request) (bool, error) {
+	u, err := s.perm.GetUser(req.Requester)
+	if err != nil {
+		return false, nil
+	}
+	if u.IsService() || authorize.ServiceKind(req.URI) != "" {
+		return u.ServiceAllow(req.URI), nil
+	}
+	if selfCheckURI(req.URI) {
+		return true, nil
+	}
+	return s.perm.Check(req.Requester, req.Header["NS"], req.Header["Resource"], req.Method, req.URI)
+}
+
+// replayRequest return the request of the change request, made by the requester.
+func replayRequest(req approval.Request) (*http.Request, error) {
+	uri := req.URI
+	if req.Query != "" {
+		uri += "?" + req.Query
+	}
+	r, err := http.NewRequest(req.Method, uri, strings.NewReader(req.Body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range req.Header {
+		r.Header.Set(k, v)
+	}
+	r.Header.Set("UID", req.Requester)
+	return r, nil
+}
+
+// tokenAllowTargets check the api token of the request cover the method on every target,
+// the resource in the Resource header is used if the target has no type.
+func tokenAllowTargets(r *http.Request, targets []auditTarget, resource, method string) bool {
+	if !tokenScoped(r) {
+		return true
+	}
+	if len(targets) == 0 {
+		return false
+	}
+	for _, t := range targets {
+		resType := t.Type
+		if resType == "" {
+			resType = resource
+		}
+		if !tokenAllow(r, t.NS, resType, method) {
+			return false
+		}
+	}
+	return true
+}
+
+// tokenAllowApproval check the api token of the request cover the method on every target of the change request.
+func (s *Service) tokenAllowApproval(r *http.Request, req approval.Request, method string) bool {
+	if !tokenScoped(r) {
+		return true
+	}
+	replay, err := replayRequest(req)
+	if err != nil {
+		return false
+	}
+	return tokenAllowTargets(r, s.approvalTargets(replay, []byte(req.Body)), req.Header["Resource"], method)
+}
+
+// applyApproved replay the approved request as the requester, and record the result.
+// The request is not applied if the requester has no permission of it any more.
+func (s *Service) applyApproved(req approval.Request) (approval.Request, error) {
+	if ok, err := s.allowApproved(req); err != nil {
+		return s.approvals.Finish(req.ID, false, err.Error())
+	} else if !ok {
+		return s.approvals.Finish(req.ID, false, fmt.Sprintf("%d %s", http.StatusForbidden, "Not Authorized. The requester has no permission of the change."))
+	}
+	r, err := replayRequest(req)
+	if err != nil {
+		return s.approvals.Finish(req.ID, false, err.Error())
+	}
+	r = r.WithContext(context.WithValue(r.Context(), approvedKey{}, req.ID))
+
+	rec := &approvalRecorder{header: http.Header{}, status: http.StatusOK}
+	s.audit(s.router).ServeHTTP(rec, r)
+	result := rec.body.String()
+	if len(result) > maxApprovalResult {
+		result = result[:maxApprovalResult]
+	}
+	return s.approvals.Finish(req.ID, rec.status < http.StatusMultipleChoices, fmt.Sprintf("%d %s", rec.status, result))
+}
+
+// canApprove check the user is a member of the approver groups of the request,
+// the root admin approve the request without approver groups.
+func (s *Service) canApprove(uid string, req approval.Request) bool {
+	if len(req.Approvers) == 0 {
+		return s.isRootAdmin(uid)
+	}
+	u, err := s.perm.GetUser(uid)
+	if err != nil {
+		return false
+	}
+	for _, g := range u.Groups {
+		if _, ok := common.ContainString(req.Approvers, g); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// canViewApproval check the user is the requester or could approve the request.
+func (s *Service) canViewApproval(uid string, req approval.Request) bool {
+	return req.Requester == uid || s.canApprove(uid, req) || s.isRootAdmin(uid)
+}
+
+// HandlerApprovalList return the change requests the user could see, filtered by the status.
+func (s *Service) HandlerApprovalList(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	uid := r.Header.Get("UID")
+	reqs, err := s.approvals.List(r.FormValue("status"))
+	if err != nil {
+		ReturnServerError(w, err)
+		return
+	}
+	visible := []approval.Request{}
+	for _, req := range reqs {
+		if s.canViewApproval(uid, req) && s.tokenAllowApproval(r, req, http.MethodGet) {
+			visible = append(visible, req)
+		}
+	}
+	ReturnJson(w, 200, visible)
+}
+
+// HandlerApprovalGet return the change request and its history.
+func (s *Service) HandlerApprovalGet(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	req, err := s.approvals.Get(r.FormValue("id"))
+	if err != nil {
+		ReturnNotFound(w, err.Error())
+		return
+	}
+	if !s.canViewApproval(r.Header.Get("UID"), req) || !s.tokenAllowApproval(r, req, http.MethodGet) {
+		ReturnForbidden(w, "Not Authorized. Please check your permission.")
+		return
+	}
+	ReturnJson(w, 200, req)
+}
+
+// HandlerApprovalReview approve or reject the change request, the approved change is applied at once.
+func (s *Service) HandlerApprovalReview(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	uid := r.Header.Get("UID")
+	id, action, comment := r.FormValue("id"), r.FormValue("action"), r.FormValue("comment")
+	if id == "" || (action != "approve" && action != "reject") {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	req, err := s.approvals.Get(id)
+	if err != nil {
+		ReturnNotFound(w, err.Error())
+		return
+	}
+	if !s.canApprove(uid, req) {
+		ReturnForbidden(w, "Not Authorized. Only the approvers could review the change request.")
+		return
+	}
+	if !s.tokenAllowApproval(r, req, req.Method) {
+		ReturnForbidden(w, "Not Authorized. Out of the token scope.")
+		return
+	}
+
+	if action == "reject" {
+		if req, err = s.approvals.Reject(id, uid, comment); err != nil {
+			ReturnBadRequest(w, err)
+			return
+		}
+		ReturnJson(w, 200, req)
+		return
+	}
+	if req, err = s.approvals.Approve(id, uid, comment); err != nil {
+		ReturnBadRequest(w, err)
+		return
+	}
+	if req, err = s.applyApproved(req); err != nil {
+		s.logger.Errorf("apply change request %s fail: %s", id, err.Error())
+		ReturnServerError(w, err)
+		return
+	}
+	ReturnJson(w, 200, req)
+}
+
+// HandlerApprovalCancel cancel the change request by the requester.
+func (s *Service) HandlerApprovalCancel(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	uid := r.Header.Get("UID")
+	req, err := s.approvals.Get(r.FormValue("id"))
+	if err != nil {
+		ReturnNotFound(w, err.Error())
+		return
+	}
+	if req.Requester != uid {
+		ReturnForbidden(w, "Not Authorized. Only the requester could cancel the change request.")
+		return
+	}
+	if !s.tokenAllowApproval(r, req, req.Method) {
+		ReturnForbidden(w, "Not Authorized. Out of the token scope.")
+		return
+	}
+	if req, err = s.approvals.Cancel(req.ID, uid); err != nil {
+		ReturnBadRequest(w, err)
+		return
+	}
+	ReturnJson(w, 200, req)
+}
+
+// expireApproval expire the stale change requests periodically, only run by the leader.
+func (s *Service) expireApproval() {
+	ticker := time.NewTicker(approvalExpireInterval)
+	for range ticker.C {
+		if !s.isLeader() {
+			continue
+		}
+		expired, err := s.approvals.ExpireStale()
+		if err != nil {
+			s.logger.Errorf("expire stale change request fail: %s", err.Error())
+		}
+		if expired != 0 {
+			s.logger.Infof("expire %d stale change request", expired)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package httpd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lodastack/registry/approval"
+	"github.com/lodastack/registry/config"
+	"github.com/lodastack/registry/tree/node"
+)
+
+func TestApprovalRule(t *testing.T) {
+	rules := config.C.ApproveConf.Rules
+	defer func() { config.C.ApproveConf.Rules = rules }()
+	config.C.ApproveConf.Rules = []config.ApprovalRule{
+		{URI: "/api/v1/ns", Methods: []string{"DELETE"}, NS: "product0.loda"},
+		{URI: "/api/v1/resource", Type: "alarm", Approvers: []string{"loda-ops"}},
+	}
+	s := &Service{}
+
+	for _, c := range []struct {
+		method, uri, body string
+		expect            int
+	}{
+		{"DELETE", "/api/v1/ns?ns=server0.product0.loda", "", 0},
+		{"DELETE", "/api/v1/ns?ns=product0.loda", "", 0},
+		{"DELETE", "/api/v1/ns?ns=product1.loda", "", -1},
+		{"PUT", "/api/v1/ns?ns=product0.loda", "", -1},
+		{"DELETE", "/api/v1/nstrash?ns=product0.loda", "", -1},
+		{"PUT", "/api/v1/resource/list", `[{"ns":"product1.loda","type":"collect"},{"ns":"product1.loda","type":"alarm"}]`, 1},
+		{"POST", "/api/v1/resource?ns=product1.loda&type=collect", "", -1},
+	} {
+		r := httptest.NewRequest(c.method, c.uri, strings.NewReader(c.body))
+		body := readBody(r)
+		rule := s.approvalRule(r, auditTargets(r, body), body)
+		if (c.expect < 0 && rule != nil) || (c.expect >= 0 && rule != &config.C.ApproveConf.Rules[c.expect]) {
+			t.Fatalf("rule of %s %s not match expect %d: %+v", c.method, c.uri, c.expect, rule)
+		}
+	}
+}
+
+func TestApprovalHandler(t *testing.T) {
+	svc, cleanup := mustNewService(t, "user1")
+	defer cleanup()
+	config.C.ApproveConf = config.ApprovalConfig{Enable: true, Rules: []config.ApprovalRule{
+		{URI: "/api/v1/apply", NS: "product0.loda"},
+		{URI: "/api/v1/ns", Methods: []string{"PUT"}, NS: "product0.loda"},
+		{URI: "/api/v1/resource", Methods: []string{"PUT"}, NS: "product0.loda"},
+	}}
+	h := svc.auth(svc.approve(svc.audit(svc.router)))
+
+	for _, n := range []struct {
+		name, parent string
+		tp           int
+	}{
+		{"product0", "loda", node.NonLeaf},
+		{"server0", "product0.loda", node.Leaf},
+		{"server1", "product0.loda", node.Leaf},
+		{"product1", "loda", node.Leaf},
+	} {
+		if _, err := svc.tree.NewNode(n.name, "", n.parent, n.tp); err != nil {
+			t.Fatalf("create ns %s fail: %s", n.name, err.Error())
+		}
+	}
+	trash, err := svc.tree.TrashNode("server1.product0.loda", "admin", time.Hour, nil)
+	if err != nil {
+		t.Fatalf("trash ns fail: %s", err.Error())
+	}
+
+	// the target of the routes without ns param is resolved by the route.
+	for _, c := range []struct {
+		method, uri, body string
+		pending           bool
+	}{
+		{"POST", "/api/v1/apply", `{"ns":"loda","resources":{"server0.product0.loda":{"machine":[{"hostname":"h1"}]}}}`, true},
+		{"PUT", "/api/v1/ns/trash?id=" + trash.ID, "", true},
+		{"PUT", "/api/v1/ns/move?ns=product1.loda&parent=product0.loda", "", true},
+		{"PUT", "/api/v1/resource/move?from=product1.loda&to=server0.product0.loda&type=machine&resourceid=x", "", true},
+		{"PUT", "/api/v1/resource/copy?from=server0.product0.loda&to=product1.loda&type=machine&resourceid=x", "", true},
+		{"PUT", "/api/v1/resource/copy?from=product1.loda&to=product1.loda&type=machine&resourceid=x", "", false},
+	} {
+		w := do(h, c.method, c.uri, "admin-token", c.body, map[string]string{"NS": "loda", "Resource": "ns"})
+		if pending := w.Code == http.StatusAccepted; pending != c.pending {
+			t.Fatalf("%s %s should be pending %v: %d %s", c.method, c.uri, c.pending, w.Code, w.Body.String())
+		}
+	}
+
+	// the permission of the requester is checked again when the request is applied.
+	if err := svc.perm.CreateGroup("loda.product1-g1", []string{"user1"}, []string{"user1"},
+		[]string{"product1.loda-machine-PUT"}); err != nil {
+		t.Fatalf("create group fail: %s", err.Error())
+	}
+	header := map[string]string{"NS": "product1.loda", "Resource": "machine"}
+	w := do(h, "PUT", "/api/v1/resource/move?from=product1.loda&to=server0.product0.loda&type=machine&resourceid=x", "user1-token", "", header)
+	resp := struct {
+		Data approval.Request `json:"data"`
+	}{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusAccepted {
+		t.Fatalf("request should be pending: %d %s", w.Code, w.Body.String())
+	}
+	if err := svc.perm.RemoveGroup("loda.product1-g1"); err != nil {
+		t.Fatalf("remove group fail: %s", err.Error())
+	}
+	w = do(h, "PUT", "/api/v1/approval?action=approve&id="+resp.Data.ID, "admin-token", "", nil)
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Data.Status != approval.Failed ||
+		!strings.HasPrefix(resp.Data.Result, "403") {
+		t.Fatalf("request should not be applied without permission: %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,359 @@
+package httpd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/lodastack/registry/audit"
+	"github.com/lodastack/registry/config"
+	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/tree/node"
+)
+
+const (
+	// auditURI is the API query the audit log, which check the permission itself.
+	auditURI = "/api/v1/audit"
+
+	// defaultAuditRetention is the days the audit entries kept if not configured.
+	defaultAuditRetention = 90
+	auditPurgeInterval    = time.Hour
+
+	// maxAuditParam is the max length of a param value recorded.
+	maxAuditParam = 256
+)
+
+// defaultAuditSkip is the URIs not recorded if not configured, the agent report too frequently.
+var defaultAuditSkip = []string{"/api/v1/agent/report"}
+
+// auditSecretParams is the params never recorded.
+var auditSecretParams = map[string]bool{
+	"password": true, "secret": true, "token": true, "accesstoken": true, "code": true, "state": true,
+}
+
+func auditRetention() time.Duration {
+	days := config.C.AuditConf.Retention
+	if days <= 0 {
+		days = defaultAuditRetention
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+func auditSkip(uri string) bool {
+	skip := config.C.AuditConf.Skip
+	if len(skip) == 0 {
+		skip = defaultAuditSkip
+	}
+	for _, prefix := range skip {
+		if strings.HasPrefix(uri, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Service) initAuditHandler() {
+	s.router.GET(auditURI, s.HandlerAuditQuery)
+}
+
+// statusRecorder record the status of the response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// auditTarget is what the request change, read from the params.
+type auditTarget struct {
+	NS         string
+	Type       string
+	ResourceID string
+	Group      string
+	User       string
+}
+
+// readBody return the body and parse the form from a copy of it, the handler could read the body again.
+func readBody(r *http.Request) []byte {
+	body, _ := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewBuffer(body))
+	r.ParseForm()
+	r.Body = ioutil.NopCloser(bytes.NewBuffer(body))
+	return body
+}
+
+// auditAction return the action of the request, e.g. resource.create for POST /api/v1/resource.
+func auditAction(method, path string) string {
+	verb := strings.ToLower(method)
+	switch method {
+	case http.MethodPost:
+		verb = "create"
+	case http.MethodPut:
+		verb = "update"
+	case http.MethodDelete:
+		verb = "delete"
+	}
+	p := strings.Trim(strings.TrimPrefix(path, "/api/v1"), "/")
+	return strings.Replace(p, "/", ".", -1) + "." + verb
+}
+
+// bodyParams read the JSON body, which is a bodyParam or a list of it.
+func bodyParams(body []byte) []bodyParam {
+	params := []bodyParam{}
+	if len(body) != 0 && body[0] == '[' {
+		json.Unmarshal(body, &params)
+	} else if len(body) != 0 && body[0] == '{' {
+		var param bodyParam
+		if json.Unmarshal(body, &param) == nil {
+			params = append(params, param)
+		}
+	}
+	return params
+}
+
+// auditTargets read the targets from the params and the JSON body.
+func auditTargets(r *http.Request, body []byte) []auditTarget {
+	targets := []auditTarget{}
+	ns := r.FormValue("ns")
+	if r.Method == http.MethodPost && r.URL.Path == "/api/v1/ns" && r.FormValue("name") != "" {
+		// the new ns is the child of the ns.
+		ns = r.FormValue("name") + node.NodeDeli + ns
+	}
+	if t := (auditTarget{NS: ns, Type: r.FormValue("type"), ResourceID: r.FormValue("resourceid"),
+		Group: r.FormValue("gname"), User: r.FormValue("username")}); t != (auditTarget{}) {
+		targets = append(targets, t)
+	}
+
+	for _, param := range bodyParams(body) {
+		if param.Ns != "" {
+			targets = append(targets, auditTarget{NS: param.Ns, Type: param.ResType, ResourceID: param.ResId})
+		}
+	}
+	return targets
+}
+
+// auditSnapshot return the values of the target by key, which is the ID of the resources.
+func (s *Service) auditSnapshot(t auditTarget) map[string]json.RawMessage {
+	snapshot := map[string]json.RawMessage{}
+	switch {
+	case t.NS != "" && t.Type != "":
+		rl, err := s.tree.GetResourceList(t.NS, t.Type)
+		if err != nil || rl == nil {
+			return snapshot
+		}
+		for _, res := range *rl {
+			if b, err := json.Marshal(res); err == nil {
+				snapshot[res[model.IdKey]] = b
+			}
+		}
+	case t.NS != "":
+		n, err := s.tree.GetNodeByNS(t.NS)
+		if err != nil {
+			return snapshot
+		}
+		if b, err := json.Marshal(n.NodeProperty); err == nil {
+			snapshot[""] = b
+		}
+	case t.Group != "":
+		g, err := s.perm.GetGroup(t.Group)
+		if err != nil {
+			return snapshot
+		}
+		if b, err := json.Marshal(g); err == nil {
+			snapshot[""] = b
+		}
+	case t.User != "":
+		u, err := s.perm.GetUser(strings.ToLower(t.User))
+		if err != nil {
+			return snapshot
+		}
+		u.AccessToken = ""
+		if b, err := json.Marshal(u); err == nil {
+			snapshot[""] = b
+		}
+	}
+	return snapshot
+}
+
+// auditParams return the params of the request except the secrets.
+func auditParams(r *http.Request) map[string]string {
+	params := map[string]string{}
+	for k, v := range r.Form {
+		if auditSecretParams[strings.ToLower(k)] || len(v) == 0 {
+			continue
+		}
+		value := v[0]
+		if len(value) > maxAuditParam {
+			value = value[:maxAuditParam]
+		}
+		params[k] = value
+	}
+	return params
+}
+
+// auditEntries return the entries of the target, one entry for each changed value.
+func auditEntries(base audit.Entry, t auditTarget, before, after map[string]json.RawMessage) []audit.Entry {
+	base.NS, base.Type, base.ResourceID = t.NS, t.Type, t.ResourceID
+	if base.NS == "" && t.Group != "" {
+		base.ResourceID = t.Group
+	} else if base.NS == "" && t.User != "" {
+		base.ResourceID = t.User
+	}
+	entries := []audit.Entry{}
+	for key, b := range before {
+		if a, ok := after[key]; ok && bytes.Equal(a, b) {
+			continue
+		}
+		e := base
+		e.Before, e.After = b, after[key]
+		if key != "" {
+			e.ResourceID = key
+		}
+		entries = append(entries, e)
+	}
+	for key, a := range after {
+		if _, ok := before[key]; ok {
+			continue
+		}
+		e := base
+		e.After = a
+		if key != "" {
+			e.ResourceID = key
+		}
+		entries = append(entries, e)
+	}
+	if len(entries) == 0 {
+		entries = append(entries, base)
+	}
+	return entries
+}
+
+// audit record the changes of the mutation requests, with the value of the target before and after.
+func (s *Service) audit(inner http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !config.C.AuditConf.Enable || r.Method == http.MethodGet ||
+			r.Method == http.MethodHead || r.Method == http.MethodOptions || auditSkip(r.URL.Path) {
+			inner.ServeHTTP(w, r)
+			return
+		}
+
+		body := readBody(r)
+		targets := auditTargets(r, body)
+		befores := make([]map[string]json.RawMessage, len(targets))
+		for i, t := range targets {
+			befores[i] = s.auditSnapshot(t)
+		}
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		inner.ServeHTTP(rec, r)
+
+		base := audit.Entry{
+			Time:   time.Now(),
+			Actor:  r.Header.Get("UID"),
+			IP:     clientIP(r),
+			Method: r.Method,
+			URI:    r.URL.Path,
+			Action: auditAction(r.Method, r.URL.Path),
+			Status: rec.status,
+			Params: auditParams(r),
+		}
+		entries := []audit.Entry{}
+		for i, t := range targets {
+			entries = append(entries, auditEntries(base, t, befores[i], s.auditSnapshot(t))...)
+		}
+		if len(targets) == 0 {
+			entries = append(entries, base)
+		}
+		go func() {
+			if err := s.auditLog.Record(entries...); err != nil {
+				s.logger.Errorf("record audit of %s %s fail: %s", r.Method, r.URL.Path, err.Error())
+			}
+		}()
+	})
+}
+
+// parseAuditTime parse the time in RFC3339 or unix seconds.
+func parseAuditTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if sec, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+// HandlerAuditQuery return the audit entries, the latest first.
+// Query all the entries need to be root admin, otherwise the ns is required and need the group permission of it.
+func (s *Service) HandlerAuditQuery(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	uid := r.Header.Get("UID")
+	q := audit.Query{
+		Actor:  strings.ToLower(r.FormValue("actor")),
+		NS:     r.FormValue("ns"),
+		Action: r.FormValue("action"),
+	}
+	var err error
+	if q.Since, err = parseAuditTime(r.FormValue("since")); err != nil {
+		ReturnBadRequest(w, err)
+		return
+	}
+	if q.Until, err = parseAuditTime(r.FormValue("until")); err != nil {
+		ReturnBadRequest(w, err)
+		return
+	}
+	if limit := r.FormValue("limit"); limit != "" {
+		if q.Limit, err = strconv.Atoi(limit); err != nil {
+			ReturnBadRequest(w, err)
+			return
+		}
+	}
+
+	// the api token should cover the ns queried, even of the root admin.
+	if tokenScoped(r) && (q.NS == "" || !tokenAllow(r, q.NS, model.Group, "PUT")) {
+		ReturnForbidden(w, "Not Authorized. Out of the token scope.")
+		return
+	}
+	if !s.isRootAdmin(uid) {
+		if q.NS == "" {
+			ReturnForbidden(w, "Not Authorized. Please check your permission.")
+			return
+		}
+		if ok, err := s.perm.Check(uid, q.NS, model.Group, "PUT", ""); err != nil || !ok {
+			ReturnForbidden(w, "Not Authorized. Please check your permission.")
+			return
+		}
+	}
+
+	entries, err := s.auditLog.Query(q)
+	if err != nil {
+		ReturnServerError(w, err)
+		return
+	}
+	ReturnJson(w, 200, entries)
+}
+
+// purgeAudit purge the expired audit entries periodically, only run by the leader.
+func (s *Service) purgeAudit() {
+	ticker := time.NewTicker(auditPurgeInterval)
+	for range ticker.C {
+		if !s.isLeader() {
+			continue
+		}
+		purged, err := s.auditLog.Purge(time.Now().Add(-auditRetention()))
+		if err != nil {
+			s.logger.Errorf("purge expired audit fail: %s", err.Error())
+		}
+		if purged != 0 {
+			s.logger.Infof("purge %d expired audit", purged)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package httpd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lodastack/registry/audit"
+	"github.com/lodastack/registry/config"
+)
+
+func TestAuditAction(t *testing.T) {
+	for _, c := range []struct {
+		method, path, expect string
+	}{
+		{"POST", "/api/v1/resource", "resource.create"},
+		{"PUT", "/api/v1/perm/group/member", "perm.group.member.update"},
+		{"DELETE", "/api/v1/ns", "ns.delete"},
+	} {
+		if action := auditAction(c.method, c.path); action != c.expect {
+			t.Fatalf("action of %s %s not match expect: %s", c.method, c.path, action)
+		}
+	}
+}
+
+func TestAuditTargets(t *testing.T) {
+	r := httptest.NewRequest("POST", "/api/v1/ns?ns=loda&name=product0&type=1", nil)
+	if targets := auditTargets(r, nil); len(targets) != 1 || targets[0].NS != "product0.loda" {
+		t.Fatalf("targets of new ns not match expect: %+v", targets)
+	}
+
+	body := `[{"ns":"product0.loda","type":"collect","resourceid":"id1"},{"ns":"product1.loda","type":"alarm"}]`
+	r = httptest.NewRequest("PUT", "/api/v1/resource/list", strings.NewReader(body))
+	targets := auditTargets(r, []byte(body))
+	if len(targets) != 2 || targets[0] != (auditTarget{NS: "product0.loda", Type: "collect", ResourceID: "id1"}) ||
+		targets[1].NS != "product1.loda" {
+		t.Fatalf("targets of body not match expect: %+v", targets)
+	}
+
+	r = httptest.NewRequest("PUT", "/api/v1/perm/group/member?gname=loda-ops&password=xxx", nil)
+	r.ParseForm()
+	if targets := auditTargets(r, nil); len(targets) != 1 || targets[0].Group != "loda-ops" {
+		t.Fatalf("targets of group not match expect: %+v", targets)
+	}
+	if params := auditParams(r); params["gname"] != "loda-ops" || params["password"] != "" {
+		t.Fatalf("params not match expect: %+v", params)
+	}
+}
+
+func TestAuditEntries(t *testing.T) {
+	base := audit.Entry{Actor: "user1", Action: "resource.update"}
+	target := auditTarget{NS: "product0.loda", Type: "collect"}
+	before := map[string]json.RawMessage{
+		"id1": json.RawMessage(`{"name":"cpu"}`),
+		"id2": json.RawMessage(`{"name":"mem"}`),
+		"id3": json.RawMessage(`{"name":"disk"}`),
+	}
+	after := map[string]json.RawMessage{
+		"id1": json.RawMessage(`{"name":"cpu"}`),
+		"id2": json.RawMessage(`{"name":"memory"}`),
+		"id4": json.RawMessage(`{"name":"net"}`),
+	}
+	changed := map[string]audit.Entry{}
+	for _, e := range auditEntries(base, target, before, after) {
+		if e.Actor != "user1" || e.NS != "product0.loda" || e.Type != "collect" {
+			t.Fatalf("entry not match expect: %+v", e)
+		}
+		changed[e.ResourceID] = e
+	}
+	if len(changed) != 3 ||
+		string(changed["id2"].Before) != `{"name":"mem"}` || string(changed["id2"].After) != `{"name":"memory"}` ||
+		changed["id3"].After != nil || changed["id4"].Before != nil {
+		t.Fatalf("entries not match expect: %+v", changed)
+	}
+
+	entries := auditEntries(base, auditTarget{Group: "loda-ops"}, nil, nil)
+	if len(entries) != 1 || entries[0].ResourceID != "loda-ops" || entries[0].Before != nil {
+		t.Fatalf("entry of unchanged target not match expect: %+v", entries)
+	}
+}
+
+func TestAuditActorNotFromClient(t *testing.T) {
+	svc, cleanup := mustNewService(t)
+	defer cleanup()
+	config.C.AuditConf.Enable = true
+	h := clearUID(svc.auth(svc.audit(svc.router)))
+
+	// the agent API is not authenticated, the UID sent by the client is not the actor.
+	if w := do(h, "POST", "/api/v1/agent/ns", "", "{}", map[string]string{"UID": "admin"}); w.Code != http.StatusBadRequest {
+		t.Fatalf("register without hostname should fail: %d %s", w.Code, w.Body.String())
+	}
+	var entries []audit.Entry
+	for i := 0; i < 50 && len(entries) == 0; i++ {
+		time.Sleep(100 * time.Millisecond)
+		entries, _ = svc.auditLog.Query(audit.Query{Action: "agent.ns.create"})
+	}
+	if len(entries) != 1 || entries[0].Actor != "" {
+		t.Fatalf("actor should not be read from the client: %+v", entries)
+	}
+}
@@ -20,6 +20,7 @@ func (s *Service) initManageHandler() {
 
 func (s *Service) handlerStats(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	ms := s.cluster.Statistics(nil)
+	ms = append(ms, s.limitStatistics()...)
 	ReturnJson(w, 200, ms)
 }
 
@@ -0,0 +1,91 @@
+package httpd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+type labelParam struct {
+	Ns     string            `json:"ns"`
+	Labels map[string]string `json:"labels"`
+}
+
+func (s *Service) initLabelHandler() {
+	s.router.GET("/api/v1/ns/labels", s.handlerLabelGet)
+	s.router.PUT("/api/v1/ns/labels", s.handlerLabelSet)
+	s.router.DELETE("/api/v1/ns/labels", s.handlerLabelDel)
+	s.router.GET("/api/v1/ns/query", s.handlerLabelQuery)
+}
+
+// handlerLabelGet return the labels of the ns and the effective labels inherited from its ancestors.
+func (s *Service) handlerLabelGet(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ns := r.FormValue("ns")
+	if ns == "" {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	labels, effective, err := s.tree.GetNodeLabels(ns)
+	if err != nil {
+		ReturnBadRequest(w, err)
+		return
+	}
+	ReturnJson(w, 200, map[string]map[string]string{"labels": labels, "effective": effective})
+}
+
+// handlerLabelSet add or update the labels of the ns.
+func (s *Service) handlerLabelSet(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	buf := new(bytes.Buffer)
+	if _, err := buf.ReadFrom(r.Body); err != nil {
+		ReturnBadRequest(w, err)
+		return
+	}
+	param := labelParam{}
+	if err := json.Unmarshal(buf.Bytes(), &param); err != nil {
+		ReturnBadRequest(w, err)
+		return
+	}
+	if param.Ns == "" || len(param.Labels) == 0 {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	if err := s.tree.SetNodeLabels(param.Ns, param.Labels); err != nil {
+		ReturnBadRequest(w, err)
+		return
+	}
+	ReturnOK(w, "success")
+}
+
+// handlerLabelDel remove the labels of the ns, keys is comma separated.
+func (s *Service) handlerLabelDel(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ns := r.FormValue("ns")
+	keys := r.FormValue("keys")
+	if ns == "" || keys == "" {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	if err := s.tree.RemoveNodeLabels(ns, strings.Split(keys, ",")); err != nil {
+		ReturnBadRequest(w, err)
+		return
+	}
+	ReturnOK(w, "success")
+}
+
+// handlerLabelQuery return the ns list match the label selector, e.g: selector=env=prod,tier=db.
+// Only leaf ns is returned if leaf is true.
+func (s *Service) handlerLabelQuery(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	selector := r.FormValue("selector")
+	if selector == "" {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	nsList, err := s.tree.QueryNodeByLabels(selector, r.FormValue("leaf") == "true")
+	if err != nil {
+		ReturnBadRequest(w, err)
+		return
+	}
+	ReturnJson(w, 200, nsList)
+}
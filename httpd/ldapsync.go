@@ -0,0 +1,89 @@
+package httpd
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/lodastack/registry/authenticate"
+	"github.com/lodastack/registry/authorize"
+	"github.com/lodastack/registry/config"
+)
+
+func (s *Service) initLDAPSyncHandler() {
+	s.router.POST("/api/v1/perm/ldap/sync", s.HandlerLDAPSync)
+}
+
+// syncLDAP sync the members of the configured groups from LDAP.
+// The group fail to query from LDAP is not changed, and the error is set to its result.
+func (s *Service) syncLDAP(dryRun bool) ([]authorize.GroupSync, error) {
+	a, ok := s.authn.Get(authenticate.LDAPName)
+	if !ok {
+		return nil, errors.New("ldap not enabled")
+	}
+	l := a.(*authenticate.LDAP)
+	results := make([]authorize.GroupSync, 0, len(config.C.LDAPConf.Groups))
+	for _, g := range config.C.LDAPConf.Groups {
+		members, err := l.GroupMembers(g)
+		if err != nil {
+			s.logger.Errorf("query members of ldap group %s fail: %s", g.Group, err.Error())
+			results = append(results, authorize.GroupSync{Group: g.Group, Error: err.Error()})
+			continue
+		}
+		users := make([]authorize.User, 0, len(members))
+		for _, m := range members {
+			users = append(users, authorize.User{Username: m.Username, Mobile: m.Mobile, Mail: m.Mail})
+		}
+		result, err := s.perm.SyncGroupMembers(g.Group, users, dryRun)
+		if err != nil {
+			s.logger.Errorf("sync group %s from ldap fail: %s", g.Group, err.Error())
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// syncLDAPLoop sync the groups from LDAP periodically, only run by the leader.
+func (s *Service) syncLDAPLoop() {
+	if !config.C.LDAPConf.Enable || config.C.LDAPConf.SyncInterval <= 0 || len(config.C.LDAPConf.Groups) == 0 {
+		return
+	}
+	ticker := time.NewTicker(time.Duration(config.C.LDAPConf.SyncInterval) * time.Minute)
+	for range ticker.C {
+		if !s.isLeader() {
+			continue
+		}
+		results, err := s.syncLDAP(false)
+		if err != nil {
+			s.logger.Errorf("sync ldap fail: %s", err.Error())
+			continue
+		}
+		for _, r := range results {
+			if len(r.Created) != 0 || len(r.Added) != 0 || len(r.Removed) != 0 {
+				s.logger.Infof("sync group %s from ldap, created %v, added %v, removed %v", r.Group, r.Created, r.Added, r.Removed)
+			}
+		}
+	}
+}
+
+// HandlerLDAPSync sync the groups from LDAP now, which need to be root admin.
+// Return the changes without apply them if dryrun is true.
+func (s *Service) HandlerLDAPSync(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if !s.isRootAdmin(r.Header.Get("UID")) {
+		ReturnForbidden(w, "Not Authorized. Please check your permission.")
+		return
+	}
+	dryRun := r.FormValue("dryrun") == "true"
+	if !dryRun && !s.isLeader() {
+		ReturnBadRequest(w, errors.New("ldap sync should be run on the leader"))
+		return
+	}
+	results, err := s.syncLDAP(dryRun)
+	if err != nil {
+		ReturnBadRequest(w, err)
+		return
+	}
+	ReturnJson(w, 200, results)
+}
@@ -0,0 +1,134 @@
+package httpd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/lodastack/registry/authenticate"
+	"github.com/lodastack/registry/config"
+	"github.com/lodastack/registry/oidc"
+)
+
+// oidcURI is the prefix of the OpenID Connect signin API, not authenticated.
+const oidcURI = "/api/v1/user/oidc"
+
+func (s *Service) initOIDCHandler() {
+	if !config.C.OIDCConf.Enable {
+		return
+	}
+	s.router.GET(oidcURI+"/providers", s.HandlerOIDCProviders)
+	s.router.GET(oidcURI+"/signin", s.HandlerOIDCSignin)
+	s.router.GET(oidcURI+"/callback", s.HandlerOIDCCallback)
+}
+
+// oidcProvider return the OpenID Connect provider in the authentication chain by name.
+func (s *Service) oidcProvider(name string) (*oidc.Provider, bool) {
+	a, ok := s.authn.Get(authenticate.OIDCPrefix + name)
+	if !ok {
+		return nil, false
+	}
+	o, ok := a.(*authenticate.OIDC)
+	if !ok {
+		return nil, false
+	}
+	return o.Provider(), true
+}
+
+// HandlerOIDCProviders return the names of the providers.
+func (s *Service) HandlerOIDCProviders(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	names := []string{}
+	for _, a := range s.authn {
+		if o, ok := a.(*authenticate.OIDC); ok {
+			names = append(names, o.Provider().Name())
+		}
+	}
+	sort.Strings(names)
+	ReturnJson(w, 200, names)
+}
+
+// HandlerOIDCSignin redirect to the provider to signin.
+func (s *Service) HandlerOIDCSignin(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	provider, ok := s.oidcProvider(r.FormValue("provider"))
+	if !ok {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	state, login, err := s.oidcLogins.Begin(provider.Name())
+	if err != nil {
+		ReturnServerError(w, err)
+		return
+	}
+	authURL, err := provider.AuthCodeURL(state, login.Nonce, login.Verifier)
+	if err != nil {
+		s.logger.Errorf("discover oidc provider %s fail: %s", provider.Name(), err.Error())
+		ReturnServerError(w, err)
+		return
+	}
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// HandlerOIDCCallback finish the signin: exchange the code for the ID token,
+// sync the groups of the user by the claims and create the session.
+func (s *Service) HandlerOIDCCallback(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if errMsg := r.FormValue("error"); errMsg != "" {
+		ReturnUnauthorized(w, "signin fail: "+errMsg)
+		return
+	}
+	login, ok := s.oidcLogins.Finish(r.FormValue("state"))
+	if !ok || r.FormValue("code") == "" {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	provider, ok := s.oidcProvider(login.Provider)
+	if !ok {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	id, err := s.authn.Authenticate(authenticate.Credential{
+		Provider: authenticate.OIDCPrefix + login.Provider,
+		Code:     r.FormValue("code"),
+		Verifier: login.Verifier,
+		Nonce:    login.Nonce,
+	})
+	if err != nil {
+		s.logger.Errorf("signin by oidc provider %s fail: %s", provider.Name(), err.Error())
+		ReturnUnauthorized(w, err.Error())
+		return
+	}
+	username := id.Username
+
+	exist, err := s.perm.CheckUserExist(username)
+	if err != nil {
+		ReturnServerError(w, err)
+		return
+	}
+	if !exist {
+		if !provider.AutoCreate() {
+			ReturnServerError(w, errors.New("You have no permission, contact the administrators"))
+			return
+		}
+		if err := s.perm.SetUser(username, "", "enable", ""); err != nil {
+			ReturnServerError(w, err)
+			return
+		}
+	}
+	if err := s.perm.SyncUserGroups(username, id.Managed, id.Groups); err != nil {
+		s.logger.Errorf("sync groups of %s fail: %s", username, err.Error())
+		ReturnServerError(w, err)
+		return
+	}
+
+	key, err := s.perm.CreateSession(username, clientIP(r), r.UserAgent())
+	if err != nil {
+		ReturnServerError(w, errors.New("set session failed"))
+		return
+	}
+	if config.C.OIDCConf.Redirect == "" {
+		ReturnJson(w, 200, UserToken{User: username, Token: key})
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf(config.C.OIDCConf.Redirect, key, username), http.StatusFound)
+}
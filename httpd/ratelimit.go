@@ -0,0 +1,121 @@
+package httpd
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/lodastack/registry/config"
+	"github.com/lodastack/registry/ratelimit"
+	sm "github.com/lodastack/store/model"
+)
+
+// limitPurgeInterval is the interval to purge the idle limiter state.
+const limitPurgeInterval = time.Minute
+
+// limitIP limit the requests of each client IP by the route limits.
+func (s *Service) limitIP(inner http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !config.C.LimitConf.Enable {
+			inner.ServeHTTP(w, r)
+			return
+		}
+		if route := s.limits.Match(r.URL.Path); route != nil && route.IP != nil {
+			if ok, wait := route.IP.Allow(clientIP(r)); !ok {
+				ReturnTooManyRequests(w, wait, "Too many requests. Please retry later.")
+				return
+			}
+		}
+		inner.ServeHTTP(w, r)
+	})
+}
+
+// limitUser limit the requests of each user by the route limits.
+// It is wrapped by the auth, so the UID header is set by the auth but not the client.
+func (s *Service) limitUser(inner http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uid := r.Header.Get("UID")
+		if !config.C.LimitConf.Enable || uid == "" || !uriFilter(r) {
+			inner.ServeHTTP(w, r)
+			return
+		}
+		if route := s.limits.Match(r.URL.Path); route != nil && route.User != nil {
+			if ok, wait := route.User.Allow(uid); !ok {
+				ReturnTooManyRequests(w, wait, "Too many requests. Please retry later.")
+				return
+			}
+		}
+		inner.ServeHTTP(w, r)
+	})
+}
+
+// signinLockKey return the lockout key of the user signin from the IP.
+// The user is locked only from the IP, so that others could not lock out the user.
+func signinLockKey(username, ip string) string {
+	return "user:" + username + "@" + ip
+}
+
+// signinLocked check whether the signin of the user from the client IP or the client IP is locked.
+func (s *Service) signinLocked(username, ip string) (bool, time.Duration) {
+	if !config.C.LimitConf.Enable {
+		return false, 0
+	}
+	if locked, left := s.lockout.Locked(signinLockKey(username, ip)); locked {
+		return true, left
+	}
+	return s.lockout.Locked("ip:" + ip)
+}
+
+// signinFail record the signin failure of the user from the client IP and the client IP.
+func (s *Service) signinFail(username, ip string) {
+	if !config.C.LimitConf.Enable {
+		return
+	}
+	if d := s.lockout.Fail(signinLockKey(username, ip)); d > 0 {
+		s.logger.Warningf("signin of user %s from %s locked for %s", username, ip, d)
+	}
+	if d := s.lockout.Fail("ip:" + ip); d > 0 {
+		s.logger.Warningf("signin from %s locked for %s", ip, d)
+	}
+}
+
+// limitStatistics return the state of the rate limits and the signin lockout of this node.
+func (s *Service) limitStatistics() []sm.Statistic {
+	ms := []sm.Statistic{}
+	limiterStat := func(prefix, by string, st ratelimit.LimiterStats) sm.Statistic {
+		return sm.Statistic{
+			Name: "ratelimit",
+			Tags: map[string]string{"prefix": prefix, "by": by},
+			Values: map[string]interface{}{
+				"rate": st.Rate, "burst": st.Burst, "keys": st.Keys, "throttled": st.Throttled,
+			},
+		}
+	}
+	if config.C.LimitConf.Enable {
+		for _, route := range s.limits.Stats() {
+			if route.IP != nil {
+				ms = append(ms, limiterStat(route.Prefix, "ip", *route.IP))
+			}
+			if route.User != nil {
+				ms = append(ms, limiterStat(route.Prefix, "user", *route.User))
+			}
+		}
+	}
+	lockout := s.lockout.Stats()
+	ms = append(ms, sm.Statistic{
+		Name: "signin_lockout",
+		Tags: map[string]string{},
+		Values: map[string]interface{}{
+			"threshold": lockout.Threshold, "failing": lockout.Failing, "locked": lockout.Locked,
+		},
+	})
+	return ms
+}
+
+// purgeLimit purge the idle limiter state periodically.
+func (s *Service) purgeLimit() {
+	ticker := time.NewTicker(limitPurgeInterval)
+	for range ticker.C {
+		s.limits.Purge()
+		s.lockout.Purge()
+	}
+}
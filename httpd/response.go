@@ -3,7 +3,10 @@ package httpd
 import (
 	"encoding/json"
 	"errors"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	m "github.com/lodastack/models"
 )
@@ -50,6 +53,12 @@ func ReturnNotFound(w http.ResponseWriter, msg string) {
 	(&Response{Code: http.StatusNotFound, Msg: msg}).Write(w)
 }
 
+// Return 429 http status, the client should retry after the duration.
+func ReturnTooManyRequests(w http.ResponseWriter, retryAfter time.Duration, msg string) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(math.Max(1, retryAfter.Seconds())))))
+	(&Response{Code: http.StatusTooManyRequests, Msg: msg}).Write(w)
+}
+
 // Return 500 http status.
 func ReturnServerError(w http.ResponseWriter, err error) {
 	(&Response{Code: http.StatusInternalServerError, Msg: err.Error()}).Write(w)
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 func TestWriteJson(t *testing.T) {
@@ -70,3 +71,20 @@ func TestWriteStatus(t *testing.T) {
 		t.Fatalf("ReturnServerError return not match with expect,code: %d, resp: %+v\n", w.Code, resp)
 	}
 }
+
+func TestWriteTooManyRequests(t *testing.T) {
+	for _, c := range []struct {
+		wait   time.Duration
+		expect string
+	}{
+		{0, "1"},
+		{1500 * time.Millisecond, "2"},
+		{30 * time.Second, "30"},
+	} {
+		w := httptest.NewRecorder()
+		ReturnTooManyRequests(w, c.wait, "too many requests")
+		if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != c.expect {
+			t.Fatalf("response of %s not with expect, code: %d, Retry-After: %s", c.wait, w.Code, w.Header().Get("Retry-After"))
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package httpd
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/lodastack/registry/authorize"
+	"github.com/lodastack/registry/model"
+)
+
+func (s *Service) initRoleHandler() {
+	s.router.GET("/api/v1/perm/role", s.HandlerRoleGet)
+	s.router.GET("/api/v1/perm/role/list", s.HandlerRoleList)
+	s.router.PUT("/api/v1/perm/role", s.HandlerRoleSet)
+	s.router.DELETE("/api/v1/perm/role", s.HandlerRoleDel)
+	s.router.PUT("/api/v1/perm/group/role", s.HandlerGroupRoleBind)
+}
+
+// HandlerRoleGet handle query role request.
+func (s *Service) HandlerRoleGet(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	name := strings.ToLower(r.FormValue("name"))
+	if name == "" {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	role, err := s.perm.GetRole(name)
+	if err != nil {
+		ReturnNotFound(w, err.Error())
+		return
+	}
+	ReturnJson(w, 200, role)
+}
+
+// HandlerRoleList handle query all roles request.
+func (s *Service) HandlerRoleList(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	roles, err := s.perm.ListRole()
+	if err != nil {
+		ReturnServerError(w, err)
+		return
+	}
+	ReturnJson(w, 200, roles)
+}
+
+// HandlerRoleSet create or update the role by items format as resource-method, e.g. alarm-*,machine-GET.
+// The role is global, so only the root admin could change it.
+func (s *Service) HandlerRoleSet(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if !s.isRootAdmin(r.Header.Get("UID")) {
+		ReturnForbidden(w, "Not Authorized. Only the root admin could change the role.")
+		return
+	}
+	name := strings.ToLower(r.FormValue("name"))
+	itemStr := r.FormValue("items")
+	if name == "" || itemStr == "" {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	if err := s.perm.SetRole(name, strings.Split(itemStr, ",")); err != nil {
+		s.logger.Errorf("set role %s fail: %s", name, err.Error())
+		ReturnBadRequest(w, err)
+		return
+	}
+	ReturnOK(w, "success")
+}
+
+// HandlerRoleDel handle remove role request, only the root admin could remove the role.
+func (s *Service) HandlerRoleDel(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if !s.isRootAdmin(r.Header.Get("UID")) {
+		ReturnForbidden(w, "Not Authorized. Only the root admin could change the role.")
+		return
+	}
+	name := strings.ToLower(r.FormValue("name"))
+	if name == "" {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	if err := s.perm.RemoveRole(name); err != nil {
+		s.logger.Errorf("remove role %s fail: %s", name, err.Error())
+		ReturnBadRequest(w, err)
+		return
+	}
+	ReturnOK(w, "success")
+}
+
+// HandlerGroupRoleBind set the role bindings of the group.
+// roles is format as role@ns and separated by ",", the ns is the ns of the group if not set.
+// The user need the group PUT permission of the ns of every binding.
+func (s *Service) HandlerGroupRoleBind(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	uid := r.Header.Get("UID")
+	gName := strings.ToLower(r.FormValue("gname"))
+	if gName == "" {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	defaultNs := ""
+	if strings.Contains(gName, "-") {
+		defaultNs, _ = s.perm.ReadGName(gName)
+	}
+
+	bindings := []authorize.RoleBinding{}
+	for _, str := range strings.Split(r.FormValue("roles"), ",") {
+		if str == "" {
+			continue
+		}
+		b, err := authorize.ParseRoleBinding(str, defaultNs)
+		if err != nil {
+			ReturnBadRequest(w, err)
+			return
+		}
+		if ok, err := s.perm.Check(uid, b.NS, model.Group, "PUT", ""); err != nil || !ok {
+			ReturnForbidden(w, "Not Authorized. No group permission of ns "+b.NS+".")
+			return
+		}
+		bindings = append(bindings, b)
+	}
+	if err := s.perm.BindRoles(gName, bindings); err != nil {
+		s.logger.Errorf("bind roles to group %s fail: %s", gName, err.Error())
+		ReturnBadRequest(w, err)
+		return
+	}
+	ReturnOK(w, "success")
+}
@@ -0,0 +1,36 @@
+package httpd
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRolePermission(t *testing.T) {
+	svc, cleanup := mustNewService(t, "manager")
+	defer cleanup()
+	h := svc.auth(svc.router)
+
+	// manager could only manage the group of pool.loda.
+	if err := svc.perm.CreateGroup("loda.pool-g1", []string{"manager"}, []string{"manager"},
+		[]string{"pool.loda-group-PUT"}); err != nil {
+		t.Fatalf("create group fail: %s", err.Error())
+	}
+	header := map[string]string{"NS": "pool.loda", "Resource": "group"}
+
+	if w := do(h, "PUT", "/api/v1/perm/role?name=ops&items=alarm-*", "manager-token", "", header); w.Code != http.StatusForbidden {
+		t.Fatalf("non root admin should not set the role: %d %s", w.Code, w.Body.String())
+	}
+	if w := do(h, "PUT", "/api/v1/perm/role?name=ops&items=alarm-*", "admin-token", "", header); w.Code != http.StatusOK {
+		t.Fatalf("root admin should set the role: %d %s", w.Code, w.Body.String())
+	}
+	if w := do(h, "DELETE", "/api/v1/perm/role?name=ops", "manager-token", "", header); w.Code != http.StatusForbidden {
+		t.Fatalf("non root admin should not remove the role: %d %s", w.Code, w.Body.String())
+	}
+
+	if w := do(h, "PUT", "/api/v1/perm/group/role?gname=loda.pool-g1&roles=admin@loda", "manager-token", "", header); w.Code != http.StatusForbidden {
+		t.Fatalf("manager should not bind the role to the ns not managed: %d %s", w.Code, w.Body.String())
+	}
+	if w := do(h, "PUT", "/api/v1/perm/group/role?gname=loda.pool-g1&roles=admin", "manager-token", "", header); w.Code != http.StatusOK {
+		t.Fatalf("manager should bind the role to the ns of the group: %d %s", w.Code, w.Body.String())
+	}
+}
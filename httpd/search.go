@@ -0,0 +1,62 @@
+package httpd
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/tree/node"
+)
+
+// maxSearchLimit is the max number of hits one global search could return.
+const maxSearchLimit = 1000
+
+func (s *Service) initSearchHandler() {
+	s.router.GET("/api/v1/search", s.handlerGlobalSearch)
+}
+
+// handlerGlobalSearch search the query in all resource types under the ns, default is the root.
+// Query param k limit the search to one property, types limit the resource types, e.g. machine,alarm.
+func (s *Service) handlerGlobalSearch(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ns := r.FormValue("ns")
+	if ns == "" {
+		ns = node.RootNode
+	}
+	search := model.GlobalSearch{Query: r.FormValue("q"), Key: r.FormValue("k")}
+	if search.Query == "" {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	if limit := r.FormValue("limit"); limit != "" {
+		var err error
+		if search.Limit, err = strconv.Atoi(limit); err != nil || search.Limit <= 0 {
+			ReturnBadRequest(w, ErrInvalidParam)
+			return
+		}
+	}
+	if search.Limit > maxSearchLimit {
+		search.Limit = maxSearchLimit
+	}
+	var types []string
+	if r.FormValue("types") != "" {
+		types = strings.Split(r.FormValue("types"), ",")
+	}
+	// check deploy result as handlerSearch does, before the hits truncated by the limit.
+	search.Allow = func(ns, resType string) bool {
+		if resType != model.Deploy {
+			return true
+		}
+		ok, _ := s.perm.Check(r.Header.Get(`UID`), ns, resType, r.Method, "/api/v1/resource")
+		return ok
+	}
+
+	result, err := s.tree.GlobalSearch(ns, search, types...)
+	if err != nil {
+		s.logger.Errorf("handlerGlobalSearch search %s under %s fail: %s", search.Query, ns, err.Error())
+		ReturnBadRequest(w, err)
+		return
+	}
+	ReturnJson(w, 200, result)
+}
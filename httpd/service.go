@@ -0,0 +1,90 @@
+package httpd
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/lodastack/registry/authorize"
+	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/tree/node"
+)
+
+func (s *Service) initServiceHandler() {
+	s.router.GET("/api/v1/perm/service/list", s.HandlerServiceList)
+	s.router.POST("/api/v1/perm/service", s.HandlerServiceCreate)
+	s.router.DELETE("/api/v1/perm/service", s.HandlerServiceRemove)
+}
+
+// isRootAdmin check the user has the group PUT permission of the root ns.
+func (s *Service) isRootAdmin(uid string) bool {
+	ok, err := s.perm.Check(uid, node.RootNode, model.Group, "PUT", "")
+	return err == nil && ok
+}
+
+// serviceAdmin check the user could manage the service accounts, which need to be root admin.
+func (s *Service) serviceAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if !s.isRootAdmin(r.Header.Get("UID")) {
+		ReturnForbidden(w, "Not Authorized. Please check your permission.")
+		return false
+	}
+	return true
+}
+
+// HandlerServiceList return all service accounts.
+func (s *Service) HandlerServiceList(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if !s.serviceAdmin(w, r) {
+		return
+	}
+	services, err := s.perm.ListService()
+	if err != nil {
+		ReturnServerError(w, err)
+		return
+	}
+	ReturnJson(w, 200, services)
+}
+
+// HandlerServiceCreate create a service account of the kind and issue a token of it,
+// issue a new token if the service account exist.
+func (s *Service) HandlerServiceCreate(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if !s.serviceAdmin(w, r) {
+		return
+	}
+	name, kind := r.FormValue("name"), r.FormValue("kind")
+	ttl, err := readTTL(r, authorize.DefaultTokenTTL)
+	if name == "" || kind == "" || err != nil {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	t, secret, err := s.perm.CreateService(name, kind, ttl)
+	if err != nil {
+		s.logger.Errorf("create service account %s fail: %s", name, err.Error())
+		ReturnBadRequest(w, err)
+		return
+	}
+	t.Hash = ""
+	ReturnJson(w, 200, apiTokenResp{Token: t, Secret: secret})
+}
+
+// HandlerServiceRemove remove the service account, or revoke one token of it if id is set.
+func (s *Service) HandlerServiceRemove(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if !s.serviceAdmin(w, r) {
+		return
+	}
+	name, id := r.FormValue("name"), r.FormValue("id")
+	if name == "" {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	var err error
+	if id != "" {
+		err = s.perm.RevokeToken(authorize.ServicePrefix+name, id)
+	} else {
+		err = s.perm.RemoveService(name)
+	}
+	if err != nil {
+		s.logger.Errorf("remove service account %s fail: %s", name, err.Error())
+		returnTokenErr(w, err)
+		return
+	}
+	ReturnOK(w, "success")
+}
@@ -0,0 +1,156 @@
+package httpd
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/lodastack/registry/common"
+	"github.com/lodastack/registry/config"
+)
+
+// sessionPurgeInterval is the interval to purge the expired sessions.
+const sessionPurgeInterval = 10 * time.Minute
+
+func (s *Service) initSessionHandler() {
+	s.router.GET(sessionURI+"/list", s.HandlerSessionList)
+	s.router.DELETE(sessionURI, s.HandlerSessionRevoke)
+}
+
+// clientIP return the IP of the client.
+// The proxy header is read only if the request is from the trusted proxies,
+// the first untrusted hop from right of X-Forwarded-For is the client.
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if !trustedProxy(ip) {
+		return ip
+	}
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+		return realIP
+	}
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		ip = hop
+		if !trustedProxy(hop) {
+			break
+		}
+	}
+	return ip
+}
+
+// trustedProxy check the IP is one of the trusted proxies, which is IP or CIDR.
+func trustedProxy(ip string) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, proxy := range config.C.HTTPConf.TrustedProxies {
+		if _, cidr, err := net.ParseCIDR(proxy); err == nil {
+			if cidr.Contains(addr) {
+				return true
+			}
+		} else if proxyIP := net.ParseIP(proxy); proxyIP != nil && proxyIP.Equal(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// HandlerSessionList return the sessions of the user, default is the user of the request.
+// List the sessions of other user or all users need to be root admin.
+func (s *Service) HandlerSessionList(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	uid := r.Header.Get("UID")
+	username := strings.ToLower(r.FormValue("username"))
+	if username == "" && r.FormValue("all") != "true" {
+		username = uid
+	}
+	if username != uid && !s.isRootAdmin(uid) {
+		ReturnForbidden(w, "Not Authorized. Please check your permission.")
+		return
+	}
+	sessions, err := s.perm.ListSession(username)
+	if err != nil {
+		ReturnServerError(w, err)
+		return
+	}
+	ReturnJson(w, 200, sessions)
+}
+
+// HandlerSessionRevoke revoke the session by id, or all sessions of the user by username.
+// Revoke the sessions of other user need to be root admin.
+func (s *Service) HandlerSessionRevoke(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	uid := r.Header.Get("UID")
+	id, username := r.FormValue("id"), strings.ToLower(r.FormValue("username"))
+	if (id == "") == (username == "") {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+
+	admin := s.isRootAdmin(uid)
+	if username != "" {
+		if username != uid && !admin {
+			ReturnForbidden(w, "Not Authorized. Please check your permission.")
+			return
+		}
+		n, err := s.perm.RevokeUserSession(username)
+		if err != nil {
+			s.logger.Errorf("revoke sessions of %s fail: %s", username, err.Error())
+			ReturnServerError(w, err)
+			return
+		}
+		ReturnJson(w, 200, n)
+		return
+	}
+
+	if !admin {
+		sessions, err := s.perm.ListSession(uid)
+		if err != nil {
+			ReturnServerError(w, err)
+			return
+		}
+		owned := false
+		for _, sess := range sessions {
+			owned = owned || sess.ID == id
+		}
+		if !owned {
+			ReturnNotFound(w, common.ErrSessionNotFound.Error())
+			return
+		}
+	}
+	if err := s.perm.RevokeSession(id); err != nil {
+		if err == common.ErrSessionNotFound {
+			ReturnNotFound(w, err.Error())
+			return
+		}
+		s.logger.Errorf("revoke session %s fail: %s", id, err.Error())
+		ReturnServerError(w, err)
+		return
+	}
+	ReturnOK(w, "success")
+}
+
+// purgeSession purge the expired sessions periodically, only run by the leader.
+func (s *Service) purgeSession() {
+	ticker := time.NewTicker(sessionPurgeInterval)
+	for range ticker.C {
+		if !s.isLeader() {
+			continue
+		}
+		purged, err := s.perm.PurgeExpiredSession()
+		if err != nil {
+			s.logger.Errorf("purge expired session fail: %s", err.Error())
+		}
+		if purged != 0 {
+			s.logger.Infof("purge %d expired session", purged)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package httpd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lodastack/registry/config"
+	"github.com/lodastack/registry/ratelimit"
+)
+
+func TestClientIP(t *testing.T) {
+	c := config.C
+	defer func() { config.C = c }()
+	config.C.HTTPConf.TrustedProxies = []string{"10.0.0.1", "192.168.0.0/16"}
+
+	cases := []struct {
+		remote    string
+		realIP    string
+		forwarded string
+		expect    string
+	}{
+		// the header from the client not trusted is ignored.
+		{"1.1.1.1:1234", "2.2.2.2", "3.3.3.3", "1.1.1.1"},
+		{"10.0.0.1:1234", "2.2.2.2", "3.3.3.3", "2.2.2.2"},
+		{"10.0.0.1:1234", "", "3.3.3.3, 192.168.1.1", "3.3.3.3"},
+		// the hop added by the client before the trusted proxies is ignored.
+		{"192.168.1.2:1234", "", "4.4.4.4, 3.3.3.3, 192.168.1.1", "3.3.3.3"},
+		{"192.168.1.2:1234", "", "", "192.168.1.2"},
+	}
+	for _, tc := range cases {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tc.remote
+		if tc.realIP != "" {
+			r.Header.Set("X-Real-IP", tc.realIP)
+		}
+		if tc.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", tc.forwarded)
+		}
+		if ip := clientIP(r); ip != tc.expect {
+			t.Fatalf("clientIP of %+v not match with expect: %s", tc, ip)
+		}
+	}
+}
+
+func TestSigninLockout(t *testing.T) {
+	svc, cleanup := mustNewService(t, "user1")
+	defer cleanup()
+	h := svc.auth(svc.router)
+	svc.lockout = ratelimit.NewLockout(config.LimitConfig{LockoutThreshold: 2})
+	config.C.HTTPConf.TrustedProxies = []string{"192.0.2.1"}
+
+	signin := func(ip string) int {
+		header := map[string]string{"X-Real-IP": ip}
+		return do(h, "POST", "/api/v1/user/signin?username=user1&password=wrong", "", "", header).Code
+	}
+	// the signin is not locked if the limit is not enabled.
+	for i := 0; i < 3; i++ {
+		if code := signin("1.1.1.1"); code != http.StatusUnauthorized {
+			t.Fatalf("signin should not be locked if limit not enabled: %d", code)
+		}
+	}
+
+	config.C.LimitConf.Enable = true
+	for i := 0; i < 2; i++ {
+		if code := signin("2.2.2.2"); code != http.StatusUnauthorized {
+			t.Fatalf("signin should fail: %d", code)
+		}
+	}
+	if code := signin("2.2.2.2"); code != http.StatusTooManyRequests {
+		t.Fatalf("signin should be locked after the failures: %d", code)
+	}
+	// the user is not locked from other IP.
+	if code := signin("3.3.3.3"); code != http.StatusUnauthorized {
+		t.Fatalf("signin from other IP should not be locked: %d", code)
+	}
+}
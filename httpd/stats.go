@@ -0,0 +1,27 @@
+package httpd
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func (s *Service) initStatsHandler() {
+	s.router.GET("/api/v1/ns/stats", s.handlerNsStats)
+}
+
+// handlerNsStats return the roll-up counts of resources, machine status
+// and alarm enable/level of the ns and all its children.
+func (s *Service) handlerNsStats(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	ns := r.FormValue("ns")
+	if ns == "" {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	stats, err := s.tree.NsStats(ns)
+	if err != nil {
+		ReturnBadRequest(w, err)
+		return
+	}
+	ReturnJson(w, 200, stats)
+}
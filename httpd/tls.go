@@ -0,0 +1,131 @@
+package httpd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// certReloadInterval is the interval to check the certificate files changed or not.
+const certReloadInterval = 30 * time.Second
+
+var errInvalidCA = errors.New("no certificate found in the client CA file")
+
+// certReloader hold the server certificate and the client CA pool,
+// and reload them when the files change. The old ones are kept if reload fail.
+type certReloader struct {
+	cert, key, clientCA string
+
+	mu          sync.RWMutex
+	modTime     map[string]time.Time
+	certificate *tls.Certificate
+	clientCAs   *x509.CertPool
+}
+
+func newCertReloader(cert, key, clientCA string) (*certReloader, error) {
+	c := &certReloader{cert: cert, key: key, clientCA: clientCA}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *certReloader) files() []string {
+	files := []string{c.cert, c.key}
+	if c.clientCA != "" {
+		files = append(files, c.clientCA)
+	}
+	return files
+}
+
+// load read the certificate files.
+func (c *certReloader) load() error {
+	modTime := make(map[string]time.Time)
+	for _, f := range c.files() {
+		info, err := os.Stat(f)
+		if err != nil {
+			return err
+		}
+		modTime[f] = info.ModTime()
+	}
+
+	certificate, err := tls.LoadX509KeyPair(c.cert, c.key)
+	if err != nil {
+		return err
+	}
+	var clientCAs *x509.CertPool
+	if c.clientCA != "" {
+		caPEM, err := ioutil.ReadFile(c.clientCA)
+		if err != nil {
+			return err
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(caPEM) {
+			return errInvalidCA
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.modTime, c.certificate, c.clientCAs = modTime, &certificate, clientCAs
+	return nil
+}
+
+// changed return whether any certificate file is changed after loaded.
+func (c *certReloader) changed() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, f := range c.files() {
+		info, err := os.Stat(f)
+		if err != nil {
+			// the file may be in the middle of replacing, check it next time.
+			continue
+		}
+		if !info.ModTime().Equal(c.modTime[f]) {
+			return true
+		}
+	}
+	return false
+}
+
+// reload load the certificate files if changed.
+func (c *certReloader) reload() (bool, error) {
+	if !c.changed() {
+		return false, nil
+	}
+	return true, c.load()
+}
+
+// TLSConfig return the tls config which always use the latest certificate and client CA pool.
+// The client certificate is verified if given and the client CA is set.
+func (c *certReloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			c.mu.RLock()
+			defer c.mu.RUnlock()
+			conf := &tls.Config{Certificates: []tls.Certificate{*c.certificate}}
+			if c.clientCAs != nil {
+				conf.ClientCAs = c.clientCAs
+				conf.ClientAuth = tls.VerifyClientCertIfGiven
+			}
+			return conf, nil
+		},
+	}
+}
+
+// reloadCert reload the certificate files when they change.
+func (s *Service) reloadCert(c *certReloader) {
+	ticker := time.NewTicker(certReloadInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if changed, err := c.reload(); err != nil {
+			s.logger.Errorf("reload certificate fail, keep the old one: %s", err.Error())
+		} else if changed {
+			s.logger.Infof("certificate reloaded")
+		}
+	}
+}
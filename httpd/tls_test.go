@@ -0,0 +1,116 @@
+package httpd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCert write a self-signed certificate and its key of the CN to the dir.
+func writeCert(t *testing.T, dir, cn string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal("generate key fail:", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal("create certificate fail:", err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal("marshal key fail:", err)
+	}
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal("write certificate fail:", err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal("write key fail:", err)
+	}
+	return certFile, keyFile
+}
+
+func serverCN(t *testing.T, conf *tls.Config) string {
+	conf, err := conf.GetConfigForClient(nil)
+	if err != nil {
+		t.Fatal("GetConfigForClient fail:", err)
+	}
+	cert, err := x509.ParseCertificate(conf.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatal("parse certificate fail:", err)
+	}
+	return cert.Subject.CommonName
+}
+
+func TestCertReloader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "registry-tls-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile := writeCert(t, dir, "registry-1")
+	c, err := newCertReloader(certFile, keyFile, certFile)
+	if err != nil {
+		t.Fatal("newCertReloader fail:", err)
+	}
+	conf := c.TLSConfig()
+	if serverCN(t, conf) != "registry-1" {
+		t.Fatal("certificate not match with expect")
+	}
+	if clientConf, _ := conf.GetConfigForClient(nil); clientConf.ClientAuth != tls.VerifyClientCertIfGiven || clientConf.ClientCAs == nil {
+		t.Fatal("client certificate verification should be enabled")
+	}
+	if changed, err := c.reload(); changed || err != nil {
+		t.Fatalf("reload unchanged files not match with expect: %v, %v", changed, err)
+	}
+
+	// replace the certificate, the new one is used after reloaded.
+	writeCert(t, dir, "registry-2")
+	later := time.Now().Add(time.Minute)
+	for _, f := range []string{certFile, keyFile} {
+		if err := os.Chtimes(f, later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if changed, err := c.reload(); !changed || err != nil {
+		t.Fatalf("reload changed files not match with expect: %v, %v", changed, err)
+	}
+	if serverCN(t, conf) != "registry-2" {
+		t.Fatal("certificate should be reloaded")
+	}
+
+	// keep the old certificate if the new one is invalid.
+	if err := ioutil.WriteFile(keyFile, []byte("invalid"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(keyFile, later.Add(time.Minute), later.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.reload(); err == nil {
+		t.Fatal("reload invalid key should fail")
+	}
+	if serverCN(t, conf) != "registry-2" {
+		t.Fatal("old certificate should be kept")
+	}
+}
@@ -0,0 +1,113 @@
+package httpd
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/lodastack/registry/authorize"
+	"github.com/lodastack/registry/common"
+)
+
+func (s *Service) initTokenHandler() {
+	s.router.GET(tokenURI, s.HandlerTokenList)
+	s.router.POST(tokenURI, s.HandlerTokenCreate)
+	s.router.PUT(tokenURI, s.HandlerTokenRotate)
+	s.router.DELETE(tokenURI, s.HandlerTokenRevoke)
+}
+
+// apiTokenResp is the token and its secret, the secret is only returned once.
+type apiTokenResp struct {
+	authorize.Token
+	Secret string `json:"secret"`
+}
+
+// readTTL return the ttl param as duration, e.g. 720h, defaultTTL if not set.
+func readTTL(r *http.Request, defaultTTL time.Duration) (time.Duration, error) {
+	ttlStr := r.FormValue("ttl")
+	if ttlStr == "" {
+		return defaultTTL, nil
+	}
+	ttl, err := time.ParseDuration(ttlStr)
+	if err != nil || ttl <= 0 {
+		return 0, ErrInvalidParam
+	}
+	return ttl, nil
+}
+
+func returnTokenErr(w http.ResponseWriter, err error) {
+	if err == common.ErrTokenNotFound {
+		ReturnNotFound(w, err.Error())
+		return
+	}
+	ReturnBadRequest(w, err)
+}
+
+// HandlerTokenList return the api tokens of the user, without the hash of the secret.
+func (s *Service) HandlerTokenList(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	tokens, err := s.perm.ListToken(r.Header.Get("UID"))
+	if err != nil {
+		ReturnServerError(w, err)
+		return
+	}
+	for i := range tokens {
+		tokens[i].Hash = ""
+	}
+	ReturnJson(w, 200, tokens)
+}
+
+// HandlerTokenCreate create an api token of the user.
+// scopes is format as ns-resource-method and separated by ",", the token has all permission of the user if not set.
+func (s *Service) HandlerTokenCreate(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	name := r.FormValue("name")
+	ttl, err := readTTL(r, authorize.DefaultTokenTTL)
+	if name == "" || err != nil {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	uid := r.Header.Get("UID")
+	t, secret, err := s.perm.CreateToken(uid, name, strings.Split(r.FormValue("scopes"), ","), ttl)
+	if err != nil {
+		s.logger.Errorf("create token %s of %s fail: %s", name, uid, err.Error())
+		ReturnBadRequest(w, err)
+		return
+	}
+	t.Hash = ""
+	ReturnJson(w, 200, apiTokenResp{Token: t, Secret: secret})
+}
+
+// HandlerTokenRotate replace the secret of the api token, the expiry is extended if ttl is set.
+func (s *Service) HandlerTokenRotate(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	id := r.FormValue("id")
+	ttl, err := readTTL(r, 0)
+	if id == "" || err != nil {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	uid := r.Header.Get("UID")
+	t, secret, err := s.perm.RotateToken(uid, id, ttl)
+	if err != nil {
+		s.logger.Errorf("rotate token %s of %s fail: %s", id, uid, err.Error())
+		returnTokenErr(w, err)
+		return
+	}
+	t.Hash = ""
+	ReturnJson(w, 200, apiTokenResp{Token: t, Secret: secret})
+}
+
+// HandlerTokenRevoke remove the api token.
+func (s *Service) HandlerTokenRevoke(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	id := r.FormValue("id")
+	if id == "" {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	uid := r.Header.Get("UID")
+	if err := s.perm.RevokeToken(uid, id); err != nil {
+		s.logger.Errorf("revoke token %s of %s fail: %s", id, uid, err.Error())
+		returnTokenErr(w, err)
+		return
+	}
+	ReturnOK(w, "success")
+}
@@ -0,0 +1,162 @@
+package httpd
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lodastack/registry/authorize"
+	"github.com/lodastack/registry/config"
+	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/tree/node"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	// defaultTrashRetention is the hours a removed ns kept in trash if not configured.
+	defaultTrashRetention = 72
+
+	trashPurgeInterval = 10 * time.Minute
+	waitLeaderTimeout  = 10 * time.Second
+)
+
+func trashRetention() time.Duration {
+	hours := config.C.CommonConf.TrashRetention
+	if hours <= 0 {
+		hours = defaultTrashRetention
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+func (s *Service) initTrashHandler() {
+	s.router.GET("/api/v1/ns/trash", s.handlerTrashList)
+	s.router.PUT("/api/v1/ns/trash", s.handlerTrashRestore)
+	s.router.DELETE("/api/v1/ns/trash", s.handlerTrashPurge)
+}
+
+// allowTrash check the user could manage the trash by the method, which need the permission
+// of the group of the parent ns, as the ns is removed from the tree.
+func (s *Service) allowTrash(r *http.Request, trash model.Trash, method string) bool {
+	l := strings.SplitN(trash.NS, node.NodeDeli, 2)
+	if len(l) != 2 {
+		return false
+	}
+	parent := l[1]
+	if !tokenAllow(r, parent, model.Group, method) {
+		return false
+	}
+	uid := r.Header.Get(`UID`)
+	if uid == "" {
+		return true
+	}
+	ok, err := s.perm.Check(uid, parent, model.Group, method, r.URL.Path)
+	return err == nil && ok
+}
+
+// handlerTrashList return the removed ns in trash the user could restore.
+func (s *Service) handlerTrashList(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	trashList, err := s.tree.ListTrash()
+	if err != nil {
+		ReturnServerError(w, err)
+		return
+	}
+	visible := []model.Trash{}
+	for _, trash := range trashList {
+		if s.allowTrash(r, trash, http.MethodPut) {
+			visible = append(visible, trash)
+		}
+	}
+	ReturnJson(w, 200, visible)
+}
+
+// handlerTrashRestore restore the removed ns and its groups.
+func (s *Service) handlerTrashRestore(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	id := r.FormValue("id")
+	if id == "" {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	trash, err := s.tree.GetTrash(id)
+	if err != nil {
+		ReturnBadRequest(w, err)
+		return
+	}
+	if !s.allowTrash(r, trash, r.Method) {
+		ReturnForbidden(w, "Not Authorized. No permission of the parent of ns "+trash.NS+".")
+		return
+	}
+	groups := []authorize.Group{}
+	if len(trash.Groups) != 0 {
+		if err := json.Unmarshal(trash.Groups, &groups); err != nil {
+			ReturnServerError(w, err)
+			return
+		}
+	}
+	rows, err := s.perm.RestoreGroupRows(groups)
+	if err != nil {
+		s.logger.Errorf("restore group of ns %s fail: %s", trash.NS, err.Error())
+		ReturnServerError(w, err)
+		return
+	}
+	ns, err := s.tree.RestoreTrash(id, rows...)
+	if err != nil {
+		ReturnServerError(w, err)
+		return
+	}
+	ReturnJson(w, 200, ns)
+}
+
+// handlerTrashPurge remove the trash permanently.
+func (s *Service) handlerTrashPurge(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	id := r.FormValue("id")
+	if id == "" {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	trash, err := s.tree.GetTrash(id)
+	if err != nil {
+		ReturnBadRequest(w, err)
+		return
+	}
+	if !s.allowTrash(r, trash, r.Method) {
+		ReturnForbidden(w, "Not Authorized. No permission of the parent of ns "+trash.NS+".")
+		return
+	}
+	if err := s.tree.PurgeTrash(id); err != nil {
+		ReturnServerError(w, err)
+		return
+	}
+	ReturnOK(w, "success")
+}
+
+// isLeader check whether this node is the leader of the cluster.
+func (s *Service) isLeader() bool {
+	leader, err := s.cluster.WaitForLeader(waitLeaderTimeout)
+	if err != nil {
+		s.logger.Errorf("wait leader fail: %s", err.Error())
+		return false
+	}
+	return leader == s.cluster.Addr()
+}
+
+// purgeTrash purge the expired trash periodically, only run by the leader.
+func (s *Service) purgeTrash() {
+	ticker := time.NewTicker(trashPurgeInterval)
+	for {
+		select {
+		case <-ticker.C:
+			if !s.isLeader() {
+				continue
+			}
+			purged, err := s.tree.PurgeExpiredTrash()
+			if err != nil {
+				s.logger.Errorf("purge expired trash fail: %s", err.Error())
+			}
+			if purged != 0 {
+				s.logger.Infof("purge %d expired trash", purged)
+			}
+		}
+	}
+}
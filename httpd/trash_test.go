@@ -0,0 +1,68 @@
+package httpd
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/tree/node"
+)
+
+func TestTrashPermission(t *testing.T) {
+	svc, cleanup := mustNewService(t, "user1")
+	defer cleanup()
+	h := svc.auth(svc.router)
+
+	for _, n := range []struct {
+		name, parent string
+		tp           int
+	}{
+		{"a", "loda", node.NonLeaf},
+		{"x", "a.loda", node.Leaf},
+		{"b", "loda", node.NonLeaf},
+		{"y", "b.loda", node.Leaf},
+	} {
+		if _, err := svc.tree.NewNode(n.name, "", n.parent, n.tp); err != nil {
+			t.Fatalf("create ns %s fail: %s", n.name, err.Error())
+		}
+	}
+	if err := svc.perm.CreateGroup("loda.a-g1", []string{"user1"}, []string{"user1"},
+		[]string{"a.loda-group-PUT", "a.loda-group-DELETE"}); err != nil {
+		t.Fatalf("create group fail: %s", err.Error())
+	}
+	own, err := svc.tree.TrashNode("x.a.loda", "admin", time.Hour, nil)
+	if err != nil {
+		t.Fatalf("trash ns fail: %s", err.Error())
+	}
+	other, err := svc.tree.TrashNode("y.b.loda", "admin", time.Hour, nil)
+	if err != nil {
+		t.Fatalf("trash ns fail: %s", err.Error())
+	}
+	header := map[string]string{"NS": "a.loda", "Resource": "group"}
+
+	w := do(h, "GET", "/api/v1/ns/trash", "user1-token", "", header)
+	resp := struct {
+		Data []model.Trash `json:"data"`
+	}{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || len(resp.Data) != 1 || resp.Data[0].ID != own.ID {
+		t.Fatalf("user should only list the trash of the ns it could restore: %d %s", w.Code, w.Body.String())
+	}
+	if w := do(h, "PUT", "/api/v1/ns/trash?id="+other.ID, "user1-token", "", header); w.Code != http.StatusForbidden {
+		t.Fatalf("user without permission of the ns should not restore it: %d %s", w.Code, w.Body.String())
+	}
+	if w := do(h, "DELETE", "/api/v1/ns/trash?id="+other.ID, "user1-token", "", header); w.Code != http.StatusForbidden {
+		t.Fatalf("user without permission of the ns should not purge it: %d %s", w.Code, w.Body.String())
+	}
+	if _, err := svc.tree.GetTrash(other.ID); err != nil {
+		t.Fatalf("trash should be kept: %v", err)
+	}
+	if w := do(h, "PUT", "/api/v1/ns/trash?id="+own.ID, "user1-token", "", header); w.Code != http.StatusOK {
+		t.Fatalf("user should restore the ns it has permission: %d %s", w.Code, w.Body.String())
+	}
+	w = do(h, "GET", "/api/v1/ns/trash", "admin-token", "", header)
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || len(resp.Data) != 1 || resp.Data[0].ID != other.ID {
+		t.Fatalf("admin should list all the trash: %d %s", w.Code, w.Body.String())
+	}
+}
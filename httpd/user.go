@@ -1,17 +1,16 @@
 package httpd
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
-	"net/url"
 	"strings"
-	"time"
 
+	"github.com/lodastack/registry/authenticate"
 	"github.com/lodastack/registry/authorize"
 	"github.com/lodastack/registry/common"
 	"github.com/lodastack/registry/config"
+	"github.com/lodastack/registry/model"
 
 	"github.com/julienschmidt/httprouter"
 )
@@ -26,6 +25,7 @@ func (s *Service) initPermissionHandler() {
 	s.router.POST("/api/v1/user/signin", s.HandlerSignin)
 	s.router.GET("/api/v1/user/wework/signin", s.HandlerWeworkSignin)
 	s.router.GET("/api/v1/user/signout", s.HandlerSignout)
+	s.router.PUT(passwordURI, s.HandlerSetPassword)
 
 	s.router.GET("/api/v1/perm/group", s.HandlerGroupGet)
 	s.router.GET("/api/v1/perm/group/list", s.HandlerGroupList)
@@ -41,11 +41,11 @@ func (s *Service) initPermissionHandler() {
 	s.router.PUT("/api/v1/perm/user", s.HandlerUserSet)
 	s.router.DELETE("/api/v1/perm/user", s.HandlerRemoveUser)
 
-	// response ok if the request pass permission check.
-	s.router.GET("/api/v1/perm/check", s.nilHandler)
-	s.router.POST("/api/v1/perm/check", s.nilHandler)
-	s.router.PUT("/api/v1/perm/check", s.nilHandler)
-	s.router.DELETE("/api/v1/perm/check", s.nilHandler)
+	// explain the permission check, response 403 if not pass.
+	s.router.GET(permCheckURI, s.HandlerPermCheck)
+	s.router.POST(permCheckURI, s.HandlerPermCheck)
+	s.router.PUT(permCheckURI, s.HandlerPermCheck)
+	s.router.DELETE(permCheckURI, s.HandlerPermCheck)
 }
 
 // SigninHandler handler signin request
@@ -56,13 +56,24 @@ func (s *Service) HandlerSignin(w http.ResponseWriter, r *http.Request, _ httpro
 		ReturnBadRequest(w, ErrInvalidParam)
 		return
 	}
+	ip := clientIP(r)
+	if locked, left := s.signinLocked(user, ip); locked {
+		ReturnTooManyRequests(w, left, "Too many signin failures. Please retry later.")
+		return
+	}
 
-	if config.C.LDAPConf.Enable {
-		if err := LDAPAuth(user, pass); err != nil {
-			ReturnServerError(w, err)
+	// the password is not checked if no provider enabled, as before.
+	if _, err := s.authn.Authenticate(authenticate.Credential{Username: user, Password: pass}); err != nil &&
+		(err != authenticate.ErrNotSupported || authenticate.Enforced(config.C)) {
+		if err == authenticate.ErrInvalidCredential || err == authenticate.ErrNotSupported {
+			s.signinFail(user, ip)
+			ReturnUnauthorized(w, err.Error())
 			return
 		}
+		ReturnServerError(w, err)
+		return
 	}
+	s.lockout.Reset(signinLockKey(user, ip))
 
 	ok, err := s.perm.CheckUserExist(user)
 	if err != nil {
@@ -75,61 +86,14 @@ func (s *Service) HandlerSignin(w http.ResponseWriter, r *http.Request, _ httpro
 		return
 	}
 
-	key := common.GenUUID()
-	if err := s.cluster.SetSession(key, user); err != nil {
+	key, err := s.perm.CreateSession(user, ip, r.UserAgent())
+	if err != nil {
 		ReturnServerError(w, errors.New("set session failed"))
 		return
 	}
 	ReturnJson(w, 200, UserToken{User: user, Token: key})
 }
 
-const WCtokenAPI = "https://qyapi.weixin.qq.com/cgi-bin/gettoken?corpid=%s&corpsecret=%s"
-
-var WCTOKEN string
-var WCTOKEN_CREATETIME int64
-
-/*
-{
-   "access_token": "accesstoken000001",
-   "expires_in": 7200
-}
-*/
-
-type tokenResp struct {
-	Token   string `json:"access_token"`
-	Expires int    `json:"expires_in"`
-}
-
-func token(cid string, csec string, refresh bool) error {
-	var diff int64 = 7000
-	now := time.Now().Unix()
-	if now-WCTOKEN_CREATETIME < diff && WCTOKEN != "" && !refresh {
-		return nil
-	}
-	urlencoded := fmt.Sprintf(WCtokenAPI, url.QueryEscape(cid), url.QueryEscape(csec))
-	res, err := http.Get(urlencoded)
-	if err != nil {
-		return err
-	}
-	defer res.Body.Close()
-
-	WCTOKEN_CREATETIME = time.Now().Unix()
-	if res.StatusCode != 200 {
-		return fmt.Errorf("remote server not 200: %d", res.StatusCode)
-	}
-	var response tokenResp
-	decoder := json.NewDecoder(res.Body)
-	if err := decoder.Decode(&response); err != nil {
-		return err
-	}
-	if response.Token != "" {
-		WCTOKEN = response.Token
-		return nil
-	} else {
-		return fmt.Errorf("empty token: %s", response.Token)
-	}
-}
-
 // HandlerWeworkSignin handler wechat server request
 // https://work.weixin.qq.com/api/doc
 func (s *Service) HandlerWeworkSignin(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
@@ -145,58 +109,73 @@ func (s *Service) HandlerWeworkSignin(w http.ResponseWriter, r *http.Request, _
 		return
 	}
 
-	token(config.C.WeworkConf.CorpID, config.C.WeworkConf.CorpSecret, false)
-
-	u := fmt.Sprintf("https://qyapi.weixin.qq.com/cgi-bin/user/getuserinfo?access_token=%s&code=%s", WCTOKEN, code)
-	resp, err := http.Get(u)
+	id, err := s.authn.Authenticate(authenticate.Credential{Provider: authenticate.WeworkName, Code: code})
 	if err != nil {
 		ReturnServerError(w, err)
 		return
 	}
-	defer resp.Body.Close()
 
-	type wwResp struct {
-		ErrCode int    `json:"errcode"`
-		ErrMsg  string `json:"errmsg"`
-		UserID  string `json:"UserId"`
+	key, err := s.perm.CreateSession(id.Username, clientIP(r), r.UserAgent())
+	if err != nil {
+		ReturnServerError(w, errors.New("set session failed"))
+		return
 	}
 
-	var wwr wwResp
-	if resp.StatusCode == 200 {
-		decoder := json.NewDecoder(resp.Body)
-		decoder.Decode(&wwr)
-	} else {
-		ReturnServerError(w, fmt.Errorf("status not OK"))
+	ur := fmt.Sprintf(config.C.WeworkConf.Redirect, key, id.Username)
+	http.Redirect(w, r, ur, http.StatusFound)
+}
+
+// HandlerSetPassword set the local password of the user,
+// set the password of other user need the admin permission.
+func (s *Service) HandlerSetPassword(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	uid := r.Header.Get("UID")
+	username := strings.ToLower(r.FormValue("username"))
+	if username == "" {
+		username = uid
+	}
+	password := r.FormValue("password")
+	if username == "" || password == "" {
+		ReturnBadRequest(w, ErrInvalidParam)
 		return
 	}
-
-	if wwr.ErrCode != 0 {
-		ReturnServerError(w, fmt.Errorf("error code not 0, got %d", wwr.ErrCode))
+	a, ok := s.authn.Get(authenticate.LocalName)
+	if !ok {
+		ReturnBadRequest(w, errors.New("local password not enabled"))
 		return
 	}
-
-	key := common.GenUUID()
-	if err := s.cluster.SetSession(key, wwr.UserID); err != nil {
-		ReturnServerError(w, errors.New("set session failed"))
+	if username != uid && !s.isRootAdmin(uid) {
+		ReturnForbidden(w, "Not Authorized. Please check your permission.")
 		return
 	}
-
-	ur := fmt.Sprintf(config.C.WeworkConf.Redirect, key, wwr.UserID)
-	http.Redirect(w, r, ur, http.StatusFound)
+	if u, err := s.perm.GetUser(username); err == nil && u.IsService() {
+		ReturnBadRequest(w, errors.New("service account has no password"))
+		return
+	}
+	if err := a.(*authenticate.Local).SetPassword(username, password); err != nil {
+		s.logger.Errorf("set password of %s fail: %s", username, err.Error())
+		ReturnServerError(w, err)
+		return
+	}
+	ReturnOK(w, "success")
 }
 
 //SignoutHandler handler signout request
 func (s *Service) HandlerSignout(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	var user string
 	key := r.Header.Get("AuthToken")
-	v := s.cluster.GetSession(key)
-	if v == nil {
+	sess, err := s.perm.AuthSession(key)
+	if err == common.ErrSessionNotFound || err == common.ErrSessionExpired || err == common.ErrInvalidParam {
 		ReturnJson(w, 200, UserToken{Token: key})
 		return
 	}
-	user = v.(string)
-	s.cluster.DelSession(key)
-	ReturnJson(w, 200, UserToken{User: user, Token: key})
+	if err == nil {
+		err = s.perm.RevokeSession(sess.ID)
+	}
+	if err != nil && err != common.ErrSessionNotFound {
+		s.logger.Errorf("revoke session of %s fail: %s", sess.Username, err.Error())
+		ReturnServerError(w, errors.New("revoke session failed"))
+		return
+	}
+	ReturnJson(w, 200, UserToken{User: sess.Username, Token: key})
 }
 
 // HandlerGroupGet handle query group resquest
@@ -303,7 +282,7 @@ func (s *Service) HandlerUpdateGroupMember(w http.ResponseWriter, r *http.Reques
 			ReturnNotFound(w, "check user fail")
 			return
 		} else if !ok {
-			if ldapExist := LDAPUserExist(user); !ldapExist {
+			if !s.authn.UserExist(user) {
 				ReturnNotFound(w, "unknow user "+user)
 				return
 			}
@@ -398,6 +377,46 @@ func (s *Service) HandlerRemoveUser(w http.ResponseWriter, r *http.Request, _ ht
 	ReturnJson(w, 200, "success")
 }
 
-func (s *Service) nilHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	ReturnJson(w, 200, r.Header.Get("UID"))
+// HandlerPermCheck explain whether the user has the permission of the query.
+// The query is read from the query param ns/resource/method/uri, default is the NS/Resource header
+// and the method of the request. Explain the permission of other user need the group permission of the ns.
+func (s *Service) HandlerPermCheck(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	uid := r.Header.Get("UID")
+	user := strings.ToLower(r.FormValue("user"))
+	if user == "" {
+		user = uid
+	}
+	ns, res := r.FormValue("ns"), r.FormValue("resource")
+	if ns == "" {
+		ns = r.Header.Get("NS")
+	}
+	if res == "" {
+		res = r.Header.Get("Resource")
+	}
+	method := strings.ToUpper(r.FormValue("method"))
+	if method == "" {
+		method = r.Method
+	}
+	if user == "" || ns == "" || res == "" {
+		ReturnBadRequest(w, ErrInvalidParam)
+		return
+	}
+	if user != uid {
+		if ok, err := s.perm.Check(uid, ns, model.Group, "PUT", ""); err != nil || !ok {
+			ReturnForbidden(w, "Not Authorized. Please check your permission.")
+			return
+		}
+	}
+
+	exp, err := s.perm.Explain(user, ns, res, method, r.FormValue("uri"))
+	if err != nil {
+		s.logger.Errorf("explain permission of %s fail: %s", user, err.Error())
+		ReturnServerError(w, err)
+		return
+	}
+	if !exp.Allow {
+		ReturnJson(w, http.StatusForbidden, exp)
+		return
+	}
+	ReturnJson(w, 200, exp)
 }
@@ -0,0 +1,28 @@
+package httpd
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/lodastack/registry/common"
+)
+
+func TestSignout(t *testing.T) {
+	svc, cleanup := mustNewService(t, "user1")
+	defer cleanup()
+	h := svc.auth(svc.router)
+
+	key, err := svc.perm.CreateSession("user1", "10.0.0.1", "curl")
+	if err != nil {
+		t.Fatalf("create session fail: %s", err.Error())
+	}
+	if w := do(h, "GET", "/api/v1/user/signout", key, "", nil); w.Code != http.StatusOK {
+		t.Fatalf("signout fail: %d %s", w.Code, w.Body.String())
+	}
+	if _, err := svc.perm.AuthSession(key); err != common.ErrSessionNotFound {
+		t.Fatalf("session should be revoked after signout: %v", err)
+	}
+	if w := do(h, "GET", "/api/v1/user/signout", key, "", nil); w.Code != http.StatusOK {
+		t.Fatalf("signout the revoked session should not fail: %d %s", w.Code, w.Body.String())
+	}
+}
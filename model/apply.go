@@ -0,0 +1,164 @@
+package model
+
+import (
+	"errors"
+	"strings"
+)
+
+// Apply actions of a ApplyChange.
+const (
+	ApplyCreate = "create"
+	ApplyUpdate = "update"
+	ApplyDelete = "delete"
+)
+
+var (
+	// ErrNoPkProperty is returned when the resource type has no pk property to match on.
+	ErrNoPkProperty = errors.New("resource type has no pk property")
+	// ErrDuplicatePk is returned when the document has two resources with the same pk.
+	ErrDuplicatePk = errors.New("duplicate pk in resource list")
+)
+
+// ApplyDocument is the desired state of a ns subtree.
+// Resources is a ns-type-ResourceList map, every ns must be NS or under NS.
+type ApplyDocument struct {
+	NS        string                             `json:"ns"`
+	Resources map[string]map[string]ResourceList `json:"resources"`
+}
+
+// ApplyChange is one change the apply will make.
+type ApplyChange struct {
+	NS     string   `json:"ns"`
+	Type   string   `json:"type"`
+	Action string   `json:"action"`
+	Pk     string   `json:"pk"`
+	Before Resource `json:"before,omitempty"`
+	After  Resource `json:"after,omitempty"`
+}
+
+// ApplyPlan is the changes of a apply.
+type ApplyPlan struct {
+	NS      string        `json:"ns"`
+	Prune   bool          `json:"prune"`
+	DryRun  bool          `json:"dryrun"`
+	Changes []ApplyChange `json:"changes"`
+}
+
+// PkOfType return the pk property of resource type, template type use the pk of its resource.
+func PkOfType(resType string) string {
+	return PkProperty[strings.TrimPrefix(resType, TemplatePrefix)]
+}
+
+// indexByPk return the pk-index map of the resource list.
+func indexByPk(rl ResourceList, pk string) (map[string]int, error) {
+	index := make(map[string]int, len(rl))
+	for i, r := range rl {
+		pkValue, _ := r.ReadProperty(pk)
+		if pkValue == "" {
+			return nil, ErrInvalidParam
+		}
+		if _, ok := index[pkValue]; ok {
+			return nil, ErrDuplicatePk
+		}
+		index[pkValue] = i
+	}
+	return index, nil
+}
+
+// mergeResource merge the desired resource into the live resource.
+// Property set by the last apply but not in desired is removed,
+// property never managed by apply is kept as it is.
+func mergeResource(live, desired, applied Resource) Resource {
+	merged := Resource{}
+	for k, v := range live {
+		merged[k] = v
+	}
+	for k := range applied {
+		if k == IdKey {
+			continue
+		}
+		if _, ok := desired[k]; !ok {
+			delete(merged, k)
+		}
+	}
+	for k, v := range desired {
+		if k == IdKey {
+			continue
+		}
+		merged[k] = v
+	}
+	return merged
+}
+
+func equalResource(a, b Resource) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+	return true
+}
+
+// DiffResourceList compute the three-way diff of live/desired/last applied resource list.
+// Resources are matched by the pk property of resType, not by ID.
+// Return the resource list after apply and the changes.
+// Live resource not in desired is removed only if prune is true.
+func DiffResourceList(ns, resType string, live, desired, applied ResourceList, prune bool) (ResourceList, []ApplyChange, error) {
+	pk := PkOfType(resType)
+	if pk == "" {
+		return nil, nil, ErrNoPkProperty
+	}
+	desiredIndex, err := indexByPk(desired, pk)
+	if err != nil {
+		return nil, nil, err
+	}
+	appliedIndex, err := indexByPk(applied, pk)
+	if err != nil {
+		appliedIndex = map[string]int{}
+	}
+
+	result, changes := ResourceList{}, []ApplyChange{}
+	seen := make(map[string]bool, len(desired))
+	for _, r := range live {
+		pkValue, _ := r.ReadProperty(pk)
+		i, ok := desiredIndex[pkValue]
+		if !ok {
+			if prune {
+				changes = append(changes, ApplyChange{NS: ns, Type: resType, Action: ApplyDelete, Pk: pkValue, Before: r})
+				continue
+			}
+			result = append(result, r)
+			continue
+		}
+		seen[pkValue] = true
+
+		var lastApplied Resource
+		if j, ok := appliedIndex[pkValue]; ok {
+			lastApplied = applied[j]
+		}
+		merged := mergeResource(r, desired[i], lastApplied)
+		if !equalResource(merged, r) {
+			changes = append(changes, ApplyChange{NS: ns, Type: resType, Action: ApplyUpdate, Pk: pkValue, Before: r, After: merged})
+		}
+		result = append(result, merged)
+	}
+
+	for _, r := range desired {
+		pkValue, _ := r.ReadProperty(pk)
+		if seen[pkValue] {
+			continue
+		}
+		created := Resource{}
+		for k, v := range r {
+			if k != IdKey {
+				created[k] = v
+			}
+		}
+		changes = append(changes, ApplyChange{NS: ns, Type: resType, Action: ApplyCreate, Pk: pkValue, After: created})
+		result = append(result, created)
+	}
+	return result, changes, nil
+}
@@ -0,0 +1,73 @@
+package model
+
+import "testing"
+
+func TestDiffResourceList(t *testing.T) {
+	live := ResourceList{
+		Resource{IdKey: "id-1", "hostname": "h1", "ip": "10.0.0.1", "status": "online"},
+		Resource{IdKey: "id-2", "hostname": "h2", "ip": "10.0.0.2", "owner": "a"},
+		Resource{IdKey: "id-3", "hostname": "h3", "ip": "10.0.0.3"},
+	}
+	applied := ResourceList{
+		Resource{"hostname": "h2", "ip": "10.0.0.2", "owner": "a"},
+	}
+	desired := ResourceList{
+		Resource{"hostname": "h1", "ip": "10.0.0.1"},
+		Resource{"hostname": "h2", "ip": "10.0.0.20"},
+		Resource{"hostname": "h4", "ip": "10.0.0.4", IdKey: "ignored"},
+	}
+
+	// case 1: without prune.
+	result, changes, err := DiffResourceList("ns", Machine, live, desired, applied, false)
+	if err != nil {
+		t.Fatalf("diff fail: %s", err.Error())
+	}
+	if len(result) != 4 || len(changes) != 2 {
+		t.Fatalf("diff not match with expect, result: %+v, changes: %+v", result, changes)
+	}
+	// h1 not changed, property not managed by apply is kept.
+	if result[0]["status"] != "online" || result[0][IdKey] != "id-1" {
+		t.Fatalf("unmanaged property not kept: %+v", result[0])
+	}
+	// h2 updated, owner set by last apply and not in desired is removed.
+	if changes[0].Action != ApplyUpdate || changes[0].Pk != "h2" {
+		t.Fatalf("update change not match with expect: %+v", changes[0])
+	}
+	if _, ok := result[1]["owner"]; ok || result[1]["ip"] != "10.0.0.20" || result[1][IdKey] != "id-2" {
+		t.Fatalf("three-way merge not match with expect: %+v", result[1])
+	}
+	// h3 kept, h4 created without ID.
+	if result[2]["hostname"] != "h3" || changes[1].Action != ApplyCreate || changes[1].Pk != "h4" {
+		t.Fatalf("create change not match with expect: %+v", changes[1])
+	}
+	if _, ok := result[3][IdKey]; ok {
+		t.Fatalf("created resource should not use ID from document: %+v", result[3])
+	}
+
+	// case 2: with prune.
+	result, changes, err = DiffResourceList("ns", Machine, live, desired, applied, true)
+	if err != nil || len(result) != 3 || len(changes) != 3 {
+		t.Fatalf("diff with prune not match with expect, result: %+v, changes: %+v, error: %v", result, changes, err)
+	}
+	if changes[1].Action != ApplyDelete || changes[1].Pk != "h3" {
+		t.Fatalf("prune change not match with expect: %+v", changes[1])
+	}
+
+	// case 3: apply again change nothing.
+	if _, changes, err = DiffResourceList("ns", Machine, result, desired, desired, true); err != nil || len(changes) != 0 {
+		t.Fatalf("apply again not match with expect, changes: %+v, error: %v", changes, err)
+	}
+
+	// case 4: invalid input.
+	if _, _, err = DiffResourceList("ns", "unknown", live, desired, nil, false); err != ErrNoPkProperty {
+		t.Fatalf("diff type without pk not match with expect: %v", err)
+	}
+	dup := append(ResourceList{}, desired...)
+	dup = append(dup, Resource{"hostname": "h1"})
+	if _, _, err = DiffResourceList("ns", Machine, live, dup, nil, false); err != ErrDuplicatePk {
+		t.Fatalf("diff duplicate pk not match with expect: %v", err)
+	}
+	if _, _, err = DiffResourceList("ns", TemplatePrefix+Collect, nil, ResourceList{Resource{"name": "a"}}, nil, false); err != nil {
+		t.Fatalf("diff template type fail: %v", err)
+	}
+}
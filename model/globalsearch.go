@@ -0,0 +1,152 @@
+package model
+
+import (
+	"sort"
+	"strings"
+)
+
+// Match quality of a global search hit, the larger the better.
+const (
+	MatchContain = iota + 1
+	MatchPrefix
+	MatchExact
+)
+
+// DefaultSearchLimit is the default max number of hits a global search return.
+const DefaultSearchLimit = 100
+
+// GlobalSearch search the query in all resource types.
+// The query is matched case-insensitively against the value of Key,
+// or every property if Key is empty.
+// Allow filter the ns and type could be searched before the limit applied, all if nil.
+type GlobalSearch struct {
+	Query string
+	Key   string
+	Limit int
+	Allow func(ns, resType string) bool
+}
+
+// Allowed return whether the resources of the type in the ns could be searched.
+func (s GlobalSearch) Allowed(ns, resType string) bool {
+	return s.Allow == nil || s.Allow(ns, resType)
+}
+
+// SearchHit is one resource matched by the global search.
+type SearchHit struct {
+	Resource Resource `json:"resource"`
+	Key      string   `json:"key"`
+	Score    int      `json:"score"`
+}
+
+// SearchGroup is the hits of one type in one ns, ordered by score.
+type SearchGroup struct {
+	Type  string      `json:"type"`
+	NS    string      `json:"ns"`
+	Score int         `json:"score"`
+	Hits  []SearchHit `json:"hits"`
+}
+
+// GlobalSearchResult is the result of a global search, the group with the best hit is first.
+// Truncated is true if some hits are dropped by the limit.
+type GlobalSearchResult struct {
+	Total     int           `json:"total"`
+	Truncated bool          `json:"truncated"`
+	Groups    []SearchGroup `json:"groups"`
+}
+
+func matchScore(value, query string) int {
+	value = strings.ToLower(value)
+	switch {
+	case value == query:
+		return MatchExact
+	case strings.HasPrefix(value, query):
+		return MatchPrefix
+	case strings.Contains(value, query):
+		return MatchContain
+	}
+	return 0
+}
+
+// Match return the best matched property of the resource and its score, 0 if not matched.
+func (s GlobalSearch) Match(r Resource) (string, int) {
+	query := strings.ToLower(s.Query)
+	if s.Key != "" {
+		v, ok := r.ReadProperty(s.Key)
+		if !ok {
+			return "", 0
+		}
+		return s.Key, matchScore(v, query)
+	}
+	bestKey, best := "", 0
+	for k, v := range r {
+		score := matchScore(v, query)
+		if score > best || (score == best && score != 0 && k < bestKey) {
+			bestKey, best = k, score
+		}
+	}
+	return bestKey, best
+}
+
+type searchItem struct {
+	ns, resType string
+	hit         SearchHit
+}
+
+// SearchCollector collect the hits of a global search and rank them.
+type SearchCollector struct {
+	search GlobalSearch
+	items  []searchItem
+}
+
+// NewSearchCollector return a collector of the search, use the default limit if not set.
+func NewSearchCollector(search GlobalSearch) *SearchCollector {
+	if search.Limit <= 0 {
+		search.Limit = DefaultSearchLimit
+	}
+	return &SearchCollector{search: search}
+}
+
+// Collect match the resources of the type in the ns, skip if not allowed.
+func (c *SearchCollector) Collect(ns, resType string, rl ResourceList) {
+	if !c.search.Allowed(ns, resType) {
+		return
+	}
+	for _, r := range rl {
+		if key, score := c.search.Match(r); score != 0 {
+			c.items = append(c.items, searchItem{ns: ns, resType: resType, hit: SearchHit{Resource: r, Key: key, Score: score}})
+		}
+	}
+}
+
+// Result return the best hits up to the limit, grouped by type and ns.
+func (c *SearchCollector) Result() GlobalSearchResult {
+	sort.SliceStable(c.items, func(i, j int) bool {
+		a, b := c.items[i], c.items[j]
+		if a.hit.Score != b.hit.Score {
+			return a.hit.Score > b.hit.Score
+		}
+		if a.resType != b.resType {
+			return a.resType < b.resType
+		}
+		return a.ns < b.ns
+	})
+	result := GlobalSearchResult{Total: len(c.items), Groups: []SearchGroup{}}
+	items := c.items
+	if len(items) > c.search.Limit {
+		items, result.Truncated = items[:c.search.Limit], true
+	}
+
+	index := map[[2]string]int{}
+	for _, item := range items {
+		k := [2]string{item.resType, item.ns}
+		i, ok := index[k]
+		if !ok {
+			// the first hit of the group is the best one.
+			i = len(result.Groups)
+			index[k] = i
+			result.Groups = append(result.Groups, SearchGroup{Type: item.resType, NS: item.ns, Score: item.hit.Score})
+		}
+		result.Groups[i].Hits = append(result.Groups[i].Hits, item.hit)
+	}
+	return result
+}
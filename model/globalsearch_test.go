@@ -0,0 +1,49 @@
+package model
+
+import "testing"
+
+func TestGlobalSearch(t *testing.T) {
+	c := NewSearchCollector(GlobalSearch{Query: "10.0.0.1"})
+	c.Collect("web.loda", Machine, ResourceList{
+		Resource{"hostname": "web-1", "ip": "10.0.0.1"},
+		Resource{"hostname": "web-2", "ip": "10.0.0.12"},
+		Resource{"hostname": "web-3", "ip": "10.0.0.3"},
+	})
+	c.Collect("db.loda", Machine, ResourceList{
+		Resource{"hostname": "db-1", "ip": "192.10.0.0.1"},
+	})
+	c.Collect("web.loda", Deploy, ResourceList{
+		Resource{"name": "web", "hosts": "10.0.0.1,10.0.0.2"},
+	})
+
+	result := c.Result()
+	if result.Total != 4 || result.Truncated || len(result.Groups) != 3 {
+		t.Fatalf("search result not match with expect: %+v", result)
+	}
+	// the group with the exact match is first, its hits ordered by score.
+	first := result.Groups[0]
+	if first.NS != "web.loda" || first.Type != Machine || first.Score != MatchExact || len(first.Hits) != 2 {
+		t.Fatalf("first group not match with expect: %+v", first)
+	}
+	if first.Hits[0].Key != "ip" || first.Hits[1].Score != MatchPrefix {
+		t.Fatalf("hits of first group not match with expect: %+v", first.Hits)
+	}
+	if result.Groups[1].Type != Deploy || result.Groups[2].NS != "db.loda" {
+		t.Fatalf("group order not match with expect: %+v", result.Groups)
+	}
+
+	// property query and limit.
+	c = NewSearchCollector(GlobalSearch{Query: "WEB", Key: "hostname", Limit: 1})
+	c.Collect("web.loda", Machine, ResourceList{
+		Resource{"hostname": "web-1", "ip": "10.0.0.1"},
+		Resource{"hostname": "web", "ip": "10.0.0.2"},
+		Resource{"hostname": "db", "ip": "web"},
+	})
+	result = c.Result()
+	if result.Total != 2 || !result.Truncated || len(result.Groups) != 1 || len(result.Groups[0].Hits) != 1 {
+		t.Fatalf("limited search result not match with expect: %+v", result)
+	}
+	if result.Groups[0].Hits[0].Resource["hostname"] != "web" {
+		t.Fatalf("best hit not kept by limit: %+v", result.Groups[0].Hits)
+	}
+}
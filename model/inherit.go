@@ -0,0 +1,202 @@
+package model
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"sort"
+	"strings"
+
+	"github.com/lodastack/registry/common"
+)
+
+// InheritOverridden is the action of a template resource not propagated,
+// because the child has overridden it locally.
+const InheritOverridden = "overridden"
+
+// InheritPrefix is the key prefix which save the inherit record of a node.
+// The record is a pk-fingerprint map of the template resources propagated to the node.
+var InheritPrefix = "_inherit_"
+
+// alarm property generated by the ns, not compared between template and child.
+var nsAlarmProperty = []string{"db", "version", "md5", "groups"}
+
+// Fingerprint return the md5 of the resource, ID and the ns dependent property are excluded.
+func Fingerprint(resType string, r Resource) string {
+	isAlarm := strings.TrimPrefix(resType, TemplatePrefix) == Alarm
+	keys := make([]string, 0, len(r))
+	for k := range r {
+		if k == IdKey {
+			continue
+		}
+		if _, ok := common.ContainString(nsAlarmProperty, k); isAlarm && ok {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	md5Ctx := md5.New()
+	for _, k := range keys {
+		md5Ctx.Write([]byte(k))
+		md5Ctx.Write(deliVal)
+		md5Ctx.Write([]byte(r[k]))
+		md5Ctx.Write(deliProp)
+	}
+	return hex.EncodeToString(md5Ctx.Sum(nil))
+}
+
+func copyResource(r Resource) Resource {
+	out := make(Resource, len(r))
+	for k, v := range r {
+		out[k] = v
+	}
+	return out
+}
+
+// InheritTemplate merge the template of parent into the resource list of child.
+// record is the inherit record of the child, the fingerprint of the template
+// resource last propagated, used to tell whether the child resource is overridden:
+//   - resource not in the record and not in the child is created;
+//   - resource not changed since last propagate is updated or removed as the template;
+//   - resource changed or removed by the child is overridden and left as it is.
+//
+// Return the child resource list, the changes and the new inherit record.
+func InheritTemplate(ns, resType string, tmpl, live ResourceList, record map[string]string) (ResourceList, []ApplyChange, map[string]string, error) {
+	pk := PkOfType(resType)
+	if pk == "" {
+		return nil, nil, nil, ErrNoPkProperty
+	}
+	tmplIndex, err := indexByPk(tmpl, pk)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if record == nil {
+		record = map[string]string{}
+	}
+
+	result, changes := ResourceList{}, []ApplyChange{}
+	newRecord := make(map[string]string, len(tmpl))
+	seen := map[string]bool{}
+	for _, r := range live {
+		pkValue, _ := r.ReadProperty(pk)
+		seen[pkValue] = true
+		lastFp, inherited := record[pkValue]
+		i, inTmpl := tmplIndex[pkValue]
+		if !inTmpl {
+			if inherited && Fingerprint(resType, r) == lastFp {
+				changes = append(changes, ApplyChange{NS: ns, Type: resType, Action: ApplyDelete, Pk: pkValue, Before: r})
+				continue
+			}
+			if inherited {
+				changes = append(changes, ApplyChange{NS: ns, Type: resType, Action: InheritOverridden, Pk: pkValue, Before: r})
+			}
+			result = append(result, r)
+			continue
+		}
+
+		tmplFp, childFp := Fingerprint(resType, tmpl[i]), Fingerprint(resType, r)
+		switch {
+		case childFp == tmplFp:
+			// same as the template, adopt it.
+			newRecord[pkValue] = tmplFp
+			result = append(result, r)
+		case inherited && childFp == lastFp:
+			updated := copyResource(tmpl[i])
+			if id, ok := r.ID(); ok {
+				updated[IdKey] = id
+			}
+			newRecord[pkValue] = tmplFp
+			changes = append(changes, ApplyChange{NS: ns, Type: resType, Action: ApplyUpdate, Pk: pkValue, Before: r, After: updated})
+			result = append(result, updated)
+		default:
+			// keep the record, the child may revert to the template someday.
+			if inherited {
+				newRecord[pkValue] = lastFp
+			}
+			changes = append(changes, ApplyChange{NS: ns, Type: resType, Action: InheritOverridden, Pk: pkValue, Before: r, After: tmpl[i]})
+			result = append(result, r)
+		}
+	}
+
+	for _, r := range tmpl {
+		pkValue, _ := r.ReadProperty(pk)
+		if seen[pkValue] {
+			continue
+		}
+		if _, inherited := record[pkValue]; inherited {
+			// removed by the child.
+			newRecord[pkValue] = record[pkValue]
+			changes = append(changes, ApplyChange{NS: ns, Type: resType, Action: InheritOverridden, Pk: pkValue, After: r})
+			continue
+		}
+		created := copyResource(r)
+		delete(created, IdKey)
+		newRecord[pkValue] = Fingerprint(resType, r)
+		changes = append(changes, ApplyChange{NS: ns, Type: resType, Action: ApplyCreate, Pk: pkValue, After: created})
+		result = append(result, created)
+	}
+	return result, changes, newRecord, nil
+}
+
+// Origin of a resource in the effective view.
+const (
+	OriginLocal     = "local"
+	OriginInherited = "inherited"
+)
+
+// EffectiveResource is a resource of a ns annotated with where it comes from.
+// Source is the nearest ancestor whose template has the resource,
+// Root is the top-most ancestor the template resource is inherited from.
+// Diverged is true if the resource is different from the template of Source.
+type EffectiveResource struct {
+	Resource Resource `json:"resource"`
+	Origin   string   `json:"origin"`
+	Source   string   `json:"source,omitempty"`
+	Root     string   `json:"root,omitempty"`
+	Diverged bool     `json:"diverged"`
+}
+
+// AncestorTemplate is the template of one ancestor ns.
+type AncestorTemplate struct {
+	NS       string
+	Template ResourceList
+}
+
+// EffectiveResourceList annotate the resource list of a ns with the templates of its ancestors,
+// ancestors are ordered from the parent to the root. Resources are matched by pk property.
+func EffectiveResourceList(resType string, rl ResourceList, ancestors []AncestorTemplate) ([]EffectiveResource, error) {
+	pk := PkOfType(resType)
+	if pk == "" {
+		return nil, ErrNoPkProperty
+	}
+	indexes := make([]map[string]int, len(ancestors))
+	for i, a := range ancestors {
+		index, err := indexByPk(a.Template, pk)
+		if err != nil {
+			return nil, err
+		}
+		indexes[i] = index
+	}
+
+	effective := make([]EffectiveResource, 0, len(rl))
+	for _, r := range rl {
+		e := EffectiveResource{Resource: r, Origin: OriginLocal}
+		pkValue, _ := r.ReadProperty(pk)
+		for i := range ancestors {
+			j, ok := indexes[i][pkValue]
+			if !ok {
+				if e.Source != "" {
+					break
+				}
+				continue
+			}
+			if e.Source == "" {
+				e.Origin, e.Source = OriginInherited, ancestors[i].NS
+				e.Diverged = Fingerprint(resType, r) != Fingerprint(resType, ancestors[i].Template[j])
+			}
+			e.Root = ancestors[i].NS
+		}
+		effective = append(effective, e)
+	}
+	return effective, nil
+}
@@ -0,0 +1,84 @@
+package model
+
+import "testing"
+
+func TestInheritTemplate(t *testing.T) {
+	tmpl := ResourceList{
+		Resource{IdKey: "t-1", "name": "a", "interval": "30"},
+		Resource{IdKey: "t-2", "name": "b", "interval": "60"},
+		Resource{IdKey: "t-4", "name": "d", "interval": "60"},
+	}
+	record := map[string]string{
+		"a": Fingerprint(Collect, Resource{"name": "a", "interval": "60"}),
+		"b": Fingerprint(Collect, Resource{"name": "b", "interval": "60"}),
+		"c": Fingerprint(Collect, Resource{"name": "c", "interval": "60"}),
+		"e": Fingerprint(Collect, Resource{"name": "e", "interval": "60"}),
+	}
+	live := ResourceList{
+		Resource{IdKey: "id-1", "name": "a", "interval": "60"},
+		Resource{IdKey: "id-2", "name": "b", "interval": "10"},
+		Resource{IdKey: "id-3", "name": "c", "interval": "60"},
+		Resource{IdKey: "id-5", "name": "local", "interval": "60"},
+	}
+
+	result, changes, newRecord, err := InheritTemplate("ns", Collect, tmpl, live, record)
+	if err != nil {
+		t.Fatalf("inherit fail: %s", err.Error())
+	}
+	expect := []struct{ pk, action string }{
+		{"a", ApplyUpdate}, {"b", InheritOverridden}, {"c", ApplyDelete}, {"d", ApplyCreate},
+	}
+	if len(changes) != len(expect) {
+		t.Fatalf("changes not match with expect: %+v", changes)
+	}
+	for i, e := range expect {
+		if changes[i].Pk != e.pk || changes[i].Action != e.action {
+			t.Fatalf("change %d not match with expect: %+v", i, changes[i])
+		}
+	}
+	if len(result) != 4 || result[0][IdKey] != "id-1" || result[0]["interval"] != "30" || result[1]["interval"] != "10" {
+		t.Fatalf("result not match with expect: %+v", result)
+	}
+	if _, ok := result[3][IdKey]; ok {
+		t.Fatalf("created resource should not use ID of template: %+v", result[3])
+	}
+	// b keep the last record, e removed by template and child is forgotten.
+	if newRecord["b"] != record["b"] || len(newRecord) != 3 {
+		t.Fatalf("record not match with expect: %+v", newRecord)
+	}
+
+	// propagate again change nothing.
+	if _, changes, _, err = InheritTemplate("ns", Collect, tmpl, result, newRecord); err != nil || len(changes) != 1 {
+		t.Fatalf("propagate again not match with expect, changes: %+v, error: %v", changes, err)
+	}
+}
+
+func TestEffectiveResourceList(t *testing.T) {
+	rl := ResourceList{
+		Resource{IdKey: "id-1", "name": "a", "interval": "60"},
+		Resource{IdKey: "id-2", "name": "b", "interval": "10"},
+		Resource{IdKey: "id-3", "name": "local", "interval": "60"},
+	}
+	ancestors := []AncestorTemplate{
+		{NS: "product.loda", Template: ResourceList{
+			Resource{IdKey: "t-1", "name": "a", "interval": "60"},
+			Resource{IdKey: "t-2", "name": "b", "interval": "60"},
+		}},
+		{NS: "loda", Template: ResourceList{
+			Resource{IdKey: "t-3", "name": "a", "interval": "30"},
+		}},
+	}
+	effective, err := EffectiveResourceList(Collect, rl, ancestors)
+	if err != nil || len(effective) != 3 {
+		t.Fatalf("effective not match with expect: %+v, error: %v", effective, err)
+	}
+	if e := effective[0]; e.Origin != OriginInherited || e.Source != "product.loda" || e.Root != "loda" || e.Diverged {
+		t.Fatalf("inherited resource not match with expect: %+v", e)
+	}
+	if e := effective[1]; e.Source != "product.loda" || e.Root != "product.loda" || !e.Diverged {
+		t.Fatalf("diverged resource not match with expect: %+v", e)
+	}
+	if e := effective[2]; e.Origin != OriginLocal || e.Source != "" {
+		t.Fatalf("local resource not match with expect: %+v", e)
+	}
+}
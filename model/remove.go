@@ -0,0 +1,43 @@
+package model
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// RemoveNodePlan is the resources of one node removed by a recursive remove.
+type RemoveNodePlan struct {
+	NS         string   `json:"ns"`
+	Machines   []string `json:"machines,omitempty"`
+	Alarms     []string `json:"alarms,omitempty"`
+	Dashboards []string `json:"dashboards,omitempty"`
+}
+
+// RemovePlan is everything a recursive remove of NS affects.
+// Token is the fingerprint of the plan, required to confirm the remove,
+// so that the remove is refused if anything changed after the preview.
+type RemovePlan struct {
+	NS         string           `json:"ns"`
+	MoveToPool bool             `json:"movetopool"`
+	Nodes      []RemoveNodePlan `json:"nodes"`
+	Groups     []string         `json:"groups"`
+	Token      string           `json:"token"`
+}
+
+// GenToken return the fingerprint of the plan.
+func (p RemovePlan) GenToken() string {
+	p.Token = ""
+	b, _ := json.Marshal(p)
+	sum := md5.Sum(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// NsList return the ns of all nodes in the plan.
+func (p RemovePlan) NsList() []string {
+	nsList := make([]string, len(p.Nodes))
+	for i := range p.Nodes {
+		nsList[i] = p.Nodes[i].NS
+	}
+	return nsList
+}
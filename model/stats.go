@@ -0,0 +1,69 @@
+package model
+
+// StatsTypes is the resource types counted by NsStats.
+var StatsTypes = []string{Machine, Collect, Alarm, Deploy}
+
+// AlarmStats is the number of alarms by enable flag and level.
+type AlarmStats struct {
+	Enabled  int            `json:"enabled"`
+	Disabled int            `json:"disabled"`
+	Levels   map[string]int `json:"levels"`
+}
+
+// NsStats is the roll-up counts of the resources under a ns.
+// Resources is the type-number map, Machines is the status-number map.
+type NsStats struct {
+	Resources map[string]int `json:"resources"`
+	Machines  map[string]int `json:"machines"`
+	Alarms    AlarmStats     `json:"alarms"`
+}
+
+// NewNsStats return a empty NsStats.
+func NewNsStats() NsStats {
+	return NsStats{
+		Resources: map[string]int{},
+		Machines:  map[string]int{},
+		Alarms:    AlarmStats{Levels: map[string]int{}},
+	}
+}
+
+// Count add the resources of the type to the stats.
+func (s *NsStats) Count(resType string, rl ResourceList) {
+	s.Resources[resType] += len(rl)
+	switch resType {
+	case Machine:
+		for _, r := range rl {
+			status, _ := r.ReadProperty(HostStatusProp)
+			if status == "" {
+				status = Online
+			}
+			s.Machines[status]++
+		}
+	case Alarm:
+		for _, r := range rl {
+			if enable, _ := r.ReadProperty("enable"); enable == "false" {
+				s.Alarms.Disabled++
+			} else {
+				s.Alarms.Enabled++
+			}
+			if level, _ := r.ReadProperty("level"); level != "" {
+				s.Alarms.Levels[level]++
+			}
+		}
+	}
+}
+
+// Merge add the stats of a child ns to the stats.
+func (s *NsStats) Merge(child NsStats) {
+	for k, v := range child.Resources {
+		s.Resources[k] += v
+	}
+	for k, v := range child.Machines {
+		s.Machines[k] += v
+	}
+	s.Alarms.Enabled += child.Alarms.Enabled
+	s.Alarms.Disabled += child.Alarms.Disabled
+	for k, v := range child.Alarms.Levels {
+		s.Alarms.Levels[k] += v
+	}
+}
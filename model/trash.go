@@ -0,0 +1,20 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Trash is a removed ns kept for restore until ExpireAt.
+// Node is the removed node with its children, the buckets of NodeIDs are kept until purged.
+// Groups is the removed groups of the ns and its children.
+type Trash struct {
+	ID        string          `json:"id"`
+	NS        string          `json:"ns"`
+	NodeIDs   []string        `json:"nodeids"`
+	Node      json.RawMessage `json:"node"`
+	Groups    json.RawMessage `json:"groups,omitempty"`
+	Operator  string          `json:"operator"`
+	DeletedAt time.Time       `json:"deletedat"`
+	ExpireAt  time.Time       `json:"expireat"`
+}
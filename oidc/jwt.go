@@ -0,0 +1,208 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// clockSkew is the time difference allowed between the registry and the provider.
+const clockSkew = time.Minute
+
+var (
+	ErrInvalidToken  = errors.New("invalid id token")
+	ErrUnknownKey    = errors.New("signing key of id token not found")
+	ErrInvalidClaims = errors.New("invalid claims of id token")
+	ErrTokenExpired  = errors.New("id token expired")
+)
+
+// Claims is the claims of the ID token.
+type Claims map[string]interface{}
+
+// String return the string claim, empty if not found or not a string.
+func (c Claims) String(name string) string {
+	v, _ := c[name].(string)
+	return v
+}
+
+// Strings return the claim as string list, a single string is treated as a list of one.
+func (c Claims) Strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func (c Claims) time(name string) (time.Time, bool) {
+	v, ok := c[name].(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(v), 0), true
+}
+
+// jwk is one key of the JWKS, only RSA and P-256 EC keys are supported.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// publicKey return the public key of the jwk, nil if the key type is not supported.
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch {
+	case k.Kty == "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case k.Kty == "EC" && k.Crv == "P-256":
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	}
+	return nil, nil
+}
+
+// parseJWKS return the signing keys of the JWKS by kid.
+func parseJWKS(body []byte) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(body, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			return nil, err
+		}
+		if pub != nil {
+			keys[k.Kid] = pub
+		}
+	}
+	return keys, nil
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// parseJWT split the compact JWS into its header, claims, signing input and signature.
+func parseJWT(raw string) (jwtHeader, Claims, []byte, []byte, error) {
+	var header jwtHeader
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return header, nil, nil, nil, ErrInvalidToken
+	}
+	headerByte, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return header, nil, nil, nil, ErrInvalidToken
+	}
+	claimsByte, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return header, nil, nil, nil, ErrInvalidToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return header, nil, nil, nil, ErrInvalidToken
+	}
+	claims := Claims{}
+	if json.Unmarshal(headerByte, &header) != nil || json.Unmarshal(claimsByte, &claims) != nil {
+		return header, nil, nil, nil, ErrInvalidToken
+	}
+	return header, claims, []byte(parts[0] + "." + parts[1]), sig, nil
+}
+
+// verifySignature verify the RS256 or ES256 signature by the key.
+func verifySignature(alg string, key crypto.PublicKey, signed, sig []byte) error {
+	digest := sha256.Sum256(signed)
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		if alg != "RS256" {
+			return ErrInvalidToken
+		}
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig)
+	case *ecdsa.PublicKey:
+		if alg != "ES256" || len(sig) != 64 {
+			return ErrInvalidToken
+		}
+		r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(pub, digest[:], r, s) {
+			return ErrInvalidToken
+		}
+		return nil
+	}
+	return ErrInvalidToken
+}
+
+// verifyClaims check the issuer, audience, expiry and nonce of the claims.
+func verifyClaims(claims Claims, issuer, clientID, nonce string, now time.Time) error {
+	if claims.String("iss") != issuer || claims.String("nonce") != nonce {
+		return ErrInvalidClaims
+	}
+	audOK := false
+	for _, aud := range claims.Strings("aud") {
+		audOK = audOK || aud == clientID
+	}
+	if !audOK {
+		return ErrInvalidClaims
+	}
+	exp, ok := claims.time("exp")
+	if !ok {
+		return ErrInvalidClaims
+	}
+	if now.After(exp.Add(clockSkew)) {
+		return ErrTokenExpired
+	}
+	if iat, ok := claims.time("iat"); ok && iat.After(now.Add(clockSkew)) {
+		return ErrInvalidClaims
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package oidc
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	m "github.com/lodastack/store/model"
+)
+
+const (
+	// Bucket is the bucket to save the pending signins, so that the callback could reach any node.
+	Bucket = "oidc"
+
+	// loginTTL is the time a signin could take at the provider.
+	loginTTL = 10 * time.Minute
+)
+
+// Cluster is the store used by the LoginStore.
+type Cluster interface {
+	// View returns the value for the given key.
+	View(bucket, key []byte) ([]byte, error)
+
+	// ViewPrefix returns the value for the keys has the keyPrefix.
+	ViewPrefix(bucket, keyPrefix []byte) (map[string][]byte, error)
+
+	// RemoveKey removes the key from the bucket.
+	RemoveKey(bucket, key []byte) error
+
+	// Batch update values for given keys in given buckets, via distributed consensus.
+	Batch(rows []m.Row) error
+
+	// Create a bucket via distributed consensus if not exist.
+	CreateBucketIfNotExist(name []byte) error
+}
+
+// Login is a signin started at the provider and waiting for the callback.
+type Login struct {
+	Provider string    `json:"provider"`
+	Nonce    string    `json:"nonce"`
+	Verifier string    `json:"verifier"`
+	ExpireAt time.Time `json:"expireat"`
+}
+
+// LoginStore keep the pending signins by state in the cluster, a state could only be used once.
+type LoginStore struct {
+	mu      sync.Mutex
+	cluster Cluster
+}
+
+// NewLoginStore return the LoginStore, create the bucket if not exist.
+func NewLoginStore(cluster Cluster) (*LoginStore, error) {
+	if err := cluster.CreateBucketIfNotExist([]byte(Bucket)); err != nil {
+		return nil, err
+	}
+	return &LoginStore{cluster: cluster}, nil
+}
+
+// Begin start a signin of the provider, return the state and the login.
+// The expired signins are removed at the same time.
+func (s *LoginStore) Begin(provider string) (string, Login, error) {
+	state, err := randString()
+	if err != nil {
+		return "", Login{}, err
+	}
+	login := Login{Provider: provider, ExpireAt: time.Now().Add(loginTTL)}
+	if login.Nonce, err = randString(); err != nil {
+		return "", login, err
+	}
+	if login.Verifier, err = randString(); err != nil {
+		return "", login, err
+	}
+	b, err := json.Marshal(login)
+	if err != nil {
+		return "", login, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	loginMap, err := s.cluster.ViewPrefix([]byte(Bucket), []byte{})
+	if err != nil {
+		return "", login, err
+	}
+	rows := []m.Row{{Bucket: []byte(Bucket), Key: []byte(state), Value: b}}
+	now := time.Now()
+	for k, v := range loginMap {
+		var l Login
+		if len(v) != 0 && (json.Unmarshal(v, &l) != nil || now.After(l.ExpireAt)) {
+			rows = append(rows, m.Row{Bucket: []byte(Bucket), Key: []byte(k), Value: []byte{}})
+		}
+	}
+	return state, login, s.cluster.Batch(rows)
+}
+
+// Finish return the login of the state and remove it, false if not found or expired.
+func (s *LoginStore) Finish(state string) (Login, bool) {
+	login := Login{}
+	if state == "" {
+		return login, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := s.cluster.View([]byte(Bucket), []byte(state))
+	if err != nil || len(b) == 0 || json.Unmarshal(b, &login) != nil {
+		return login, false
+	}
+	if err := s.cluster.RemoveKey([]byte(Bucket), []byte(state)); err != nil {
+		return login, false
+	}
+	return login, time.Now().Before(login.ExpireAt)
+}
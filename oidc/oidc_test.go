@@ -0,0 +1,285 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lodastack/registry/config"
+	m "github.com/lodastack/store/model"
+)
+
+// fakeProvider is a local stand-in identity provider,
+// it issue the ID token with the claims of the code if the PKCE verifier match.
+type fakeProvider struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]fakeCode
+}
+
+// memCluster is the cluster in memory, the empty value is removed.
+type memCluster struct {
+	sync.Mutex
+	data map[string][]byte
+}
+
+func (c *memCluster) View(bucket, key []byte) ([]byte, error) {
+	c.Lock()
+	defer c.Unlock()
+	return c.data[string(key)], nil
+}
+
+func (c *memCluster) ViewPrefix(bucket, keyPrefix []byte) (map[string][]byte, error) {
+	c.Lock()
+	defer c.Unlock()
+	result := map[string][]byte{}
+	for k, v := range c.data {
+		if strings.HasPrefix(k, string(keyPrefix)) {
+			result[k] = v
+		}
+	}
+	return result, nil
+}
+
+func (c *memCluster) RemoveKey(bucket, key []byte) error {
+	return c.Batch([]m.Row{{Bucket: bucket, Key: key}})
+}
+
+func (c *memCluster) Batch(rows []m.Row) error {
+	c.Lock()
+	defer c.Unlock()
+	for _, row := range rows {
+		if len(row.Value) == 0 {
+			delete(c.data, string(row.Key))
+			continue
+		}
+		c.data[string(row.Key)] = row.Value
+	}
+	return nil
+}
+
+func (c *memCluster) CreateBucketIfNotExist(name []byte) error { return nil }
+
+type fakeCode struct {
+	challenge string
+	claims    Claims
+}
+
+func b64(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal("generate key fail:", err)
+	}
+	p := &fakeProvider{key: key, codes: map[string]fakeCode{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Discovery{Issuer: p.URL, AuthorizationEndpoint: p.URL + "/authorize",
+			TokenEndpoint: p.URL + "/token", JWKSURI: p.URL + "/jwks"})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string][]jwk{"keys": {{Kty: "RSA", Kid: "k1", Use: "sig",
+			N: b64(key.N.Bytes()), E: b64(big.NewInt(int64(key.E)).Bytes())}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		code, ok := p.codes[r.FormValue("code")]
+		delete(p.codes, r.FormValue("code"))
+		p.mu.Unlock()
+		if !ok || r.FormValue("client_id") != "registry" || Challenge(r.FormValue("code_verifier")) != code.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": p.sign(t, code.claims)})
+	})
+	p.Server = httptest.NewServer(mux)
+	return p
+}
+
+func (p *fakeProvider) sign(t *testing.T, claims Claims) string {
+	header, _ := json.Marshal(jwtHeader{Alg: "RS256", Kid: "k1"})
+	payload, _ := json.Marshal(claims)
+	signed := b64(header) + "." + b64(payload)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal("sign fail:", err)
+	}
+	return signed + "." + b64(sig)
+}
+
+// authorize simulate the user signin at the provider, return the code.
+func (p *fakeProvider) authorize(t *testing.T, authURL string, claims Claims) string {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal("parse auth url fail:", err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("client_id") != "registry" {
+		t.Fatalf("auth url not match with expect: %s", authURL)
+	}
+	claims["nonce"] = q.Get("nonce")
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	code := "code-" + q.Get("state")
+	p.codes[code] = fakeCode{challenge: q.Get("code_challenge"), claims: claims}
+	return code
+}
+
+func (p *fakeProvider) claims(username string, groups ...string) Claims {
+	g := []interface{}{}
+	for _, group := range groups {
+		g = append(g, group)
+	}
+	now := time.Now()
+	return Claims{"iss": p.URL, "aud": "registry", "sub": "1", "preferred_username": username,
+		"groups": g, "iat": float64(now.Unix()), "exp": float64(now.Add(time.Hour).Unix())}
+}
+
+func TestProvider(t *testing.T) {
+	fake := newFakeProvider(t)
+	defer fake.Close()
+	provider := NewProvider(config.OIDCProviderConfig{Name: "sso", Issuer: fake.URL, ClientID: "registry",
+		RedirectURL: "http://registry/callback", UsernameClaim: "preferred_username",
+		GroupMap: map[string]string{"ops": "loda-op"}})
+	logins, err := NewLoginStore(&memCluster{data: map[string][]byte{}})
+	if err != nil {
+		t.Fatal("NewLoginStore fail:", err)
+	}
+
+	// the whole authorization code flow.
+	state, login, err := logins.Begin(provider.Name())
+	if err != nil {
+		t.Fatal("Begin fail:", err)
+	}
+	authURL, err := provider.AuthCodeURL(state, login.Nonce, login.Verifier)
+	if err != nil {
+		t.Fatal("AuthCodeURL fail:", err)
+	}
+	code := fake.authorize(t, authURL, fake.claims("User1", "ops", "dev"))
+	login, ok := logins.Finish(state)
+	if !ok {
+		t.Fatal("login of the state should be found")
+	}
+	if _, ok := logins.Finish(state); ok {
+		t.Fatal("state should only be used once")
+	}
+	raw, err := provider.Exchange(code, login.Verifier)
+	if err != nil {
+		t.Fatal("Exchange fail:", err)
+	}
+	claims, err := provider.Verify(raw, login.Nonce)
+	if err != nil {
+		t.Fatal("Verify fail:", err)
+	}
+	if username, groups := provider.Identity(claims); username != "user1" || len(groups) != 1 || groups[0] != "loda-op" {
+		t.Fatalf("identity not match with expect: %s, %v", username, groups)
+	}
+	// the username is the subject by default, which could not be changed by the user.
+	if username, _ := NewProvider(config.OIDCProviderConfig{}).Identity(claims); username != "1" {
+		t.Fatalf("default username not match with expect: %s", username)
+	}
+
+	// the code could not be exchanged without the right verifier.
+	code = fake.authorize(t, authURL, fake.claims("user1"))
+	if _, err := provider.Exchange(code, "wrong"); err != ErrExchange {
+		t.Fatalf("exchange with wrong verifier should fail: %v", err)
+	}
+
+	// invalid id tokens.
+	expired := fake.claims("user1")
+	expired["nonce"], expired["exp"] = login.Nonce, float64(time.Now().Add(-time.Hour).Unix())
+	otherAud := fake.claims("user1")
+	otherAud["nonce"], otherAud["aud"] = login.Nonce, []interface{}{"other"}
+	for _, c := range []struct {
+		raw string
+		err error
+	}{
+		{fake.sign(t, expired), ErrTokenExpired},
+		{fake.sign(t, otherAud), ErrInvalidClaims},
+		{raw[:len(raw)-4] + "AAAA", ErrInvalidToken},
+		{"a.b", ErrInvalidToken},
+	} {
+		if _, err := provider.Verify(c.raw, login.Nonce); err != c.err {
+			t.Fatalf("verify %s not match with expect: %v", c.raw, err)
+		}
+	}
+	if _, err := provider.Verify(raw, "other nonce"); err != ErrInvalidClaims {
+		t.Fatalf("verify with other nonce should fail: %v", err)
+	}
+}
+
+func TestVerifyES256(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := parseJWKS([]byte(`{"keys":[{"kty":"EC","kid":"e1","crv":"P-256","x":"` +
+		b64(key.X.Bytes()) + `","y":"` + b64(key.Y.Bytes()) + `"}]}`))
+	if err != nil || keys["e1"] == nil {
+		t.Fatalf("parse JWKS fail: %v", err)
+	}
+	signed := []byte("header.payload")
+	digest := sha256.Sum256(signed)
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := make([]byte, 64)
+	rb, sb := r.Bytes(), s.Bytes()
+	copy(sig[32-len(rb):32], rb)
+	copy(sig[64-len(sb):], sb)
+	if err := verifySignature("ES256", keys["e1"], signed, sig); err != nil {
+		t.Fatal("verify ES256 fail:", err)
+	}
+	if err := verifySignature("RS256", keys["e1"], signed, sig); err == nil {
+		t.Fatal("verify with mismatched alg should fail")
+	}
+}
+
+func TestLoginStore(t *testing.T) {
+	c := &memCluster{data: map[string][]byte{}}
+	node1, err := NewLoginStore(c)
+	if err != nil {
+		t.Fatal("NewLoginStore fail:", err)
+	}
+	node2, _ := NewLoginStore(c)
+
+	// the callback could reach another node.
+	state, login, err := node1.Begin("sso")
+	if err != nil {
+		t.Fatal("Begin fail:", err)
+	}
+	if finished, ok := node2.Finish(state); !ok || finished.Nonce != login.Nonce || finished.Verifier != login.Verifier {
+		t.Fatalf("login of the state should be found on other node: %+v", finished)
+	}
+	if _, ok := node1.Finish(state); ok {
+		t.Fatal("state should only be used once")
+	}
+
+	// the expired login could not be used, and is removed by the next signin.
+	expired, _ := json.Marshal(Login{Provider: "sso", ExpireAt: time.Now().Add(-time.Second)})
+	c.Batch([]m.Row{{Key: []byte("expired"), Value: expired}})
+	if _, _, err := node1.Begin("sso"); err != nil {
+		t.Fatal("Begin fail:", err)
+	}
+	if _, ok := c.data["expired"]; ok || len(c.data) != 1 {
+		t.Fatalf("expired login should be removed: %v", c.data)
+	}
+}
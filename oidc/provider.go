@@ -0,0 +1,251 @@
+// Package oidc implement the OpenID Connect authorization code flow with PKCE,
+// the ID token is verified by the JWKS of the provider.
+package oidc
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lodastack/registry/config"
+	"github.com/lodastack/registry/utils"
+)
+
+const (
+	// httpTimeout is the timeout in seconds to query the provider.
+	httpTimeout = 10
+	// keyRefreshInterval is the min interval to refresh the JWKS when the key is not found.
+	keyRefreshInterval = time.Minute
+
+	defaultUsernameClaim = "sub"
+	defaultGroupsClaim   = "groups"
+)
+
+var (
+	ErrDiscovery = errors.New("invalid discovery document of the provider")
+	ErrExchange  = errors.New("exchange the authorization code fail")
+)
+
+// Discovery is the provider metadata of the discovery document.
+type Discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider is an OpenID Connect provider, the discovery document and JWKS are fetched lazily.
+type Provider struct {
+	conf config.OIDCProviderConfig
+
+	mu        sync.Mutex
+	discovery *Discovery
+	keys      map[string]crypto.PublicKey
+	keysAt    time.Time
+}
+
+// NewProvider return the provider of the config.
+func NewProvider(conf config.OIDCProviderConfig) *Provider {
+	return &Provider{conf: conf}
+}
+
+// Name return the name of the provider.
+func (p *Provider) Name() string { return p.conf.Name }
+
+func get(rawURL string) ([]byte, error) {
+	q := utils.HttpQuery{Method: http.MethodGet, Url: rawURL, Timeout: httpTimeout}
+	if err := q.DoQuery(); err != nil {
+		return nil, err
+	}
+	if q.Result.Status != http.StatusOK {
+		return nil, fmt.Errorf("query %s fail, status %d", rawURL, q.Result.Status)
+	}
+	return q.Result.Body, nil
+}
+
+// Discover return the discovery document of the provider, fetched once.
+func (p *Provider) Discover() (*Discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+	body, err := get(strings.TrimSuffix(p.conf.Issuer, "/") + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, err
+	}
+	d := &Discovery{}
+	if err := json.Unmarshal(body, d); err != nil {
+		return nil, err
+	}
+	if d.Issuer != p.conf.Issuer || d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, ErrDiscovery
+	}
+	p.discovery = d
+	return d, nil
+}
+
+// key return the signing key by kid, the JWKS is refreshed if the key not found.
+func (p *Provider) key(jwksURI, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(p.keysAt) < keyRefreshInterval {
+		return nil, ErrUnknownKey
+	}
+	body, err := get(jwksURI)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := parseJWKS(body)
+	if err != nil {
+		return nil, err
+	}
+	p.keys, p.keysAt = keys, time.Now()
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+func (p *Provider) scopes() string {
+	scopes := []string{"openid"}
+	for _, scope := range p.conf.Scopes {
+		if scope != "openid" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return strings.Join(scopes, " ")
+}
+
+// AuthCodeURL return the URL of the provider to signin, with the PKCE challenge of the verifier.
+func (p *Provider) AuthCodeURL(state, nonce, verifier string) (string, error) {
+	d, err := p.Discover()
+	if err != nil {
+		return "", err
+	}
+	v := url.Values{}
+	v.Set("response_type", "code")
+	v.Set("client_id", p.conf.ClientID)
+	v.Set("redirect_uri", p.conf.RedirectURL)
+	v.Set("scope", p.scopes())
+	v.Set("state", state)
+	v.Set("nonce", nonce)
+	v.Set("code_challenge", Challenge(verifier))
+	v.Set("code_challenge_method", "S256")
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return d.AuthorizationEndpoint + sep + v.Encode(), nil
+}
+
+// Exchange exchange the authorization code for the raw ID token.
+func (p *Provider) Exchange(code, verifier string) (string, error) {
+	d, err := p.Discover()
+	if err != nil {
+		return "", err
+	}
+	v := url.Values{}
+	v.Set("grant_type", "authorization_code")
+	v.Set("code", code)
+	v.Set("redirect_uri", p.conf.RedirectURL)
+	v.Set("client_id", p.conf.ClientID)
+	v.Set("client_secret", p.conf.ClientSecret)
+	v.Set("code_verifier", verifier)
+	q := utils.HttpQuery{Method: http.MethodPost, Url: d.TokenEndpoint, BodyType: utils.Form,
+		Body: []byte(v.Encode()), Timeout: httpTimeout}
+	if err := q.DoQuery(); err != nil {
+		return "", err
+	}
+	if q.Result.Status != http.StatusOK {
+		return "", ErrExchange
+	}
+	var resp struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.Unmarshal(q.Result.Body, &resp); err != nil || resp.IDToken == "" {
+		return "", ErrExchange
+	}
+	return resp.IDToken, nil
+}
+
+// Verify verify the signature and the claims of the raw ID token.
+func (p *Provider) Verify(raw, nonce string) (Claims, error) {
+	d, err := p.Discover()
+	if err != nil {
+		return nil, err
+	}
+	header, claims, signed, sig, err := parseJWT(raw)
+	if err != nil {
+		return nil, err
+	}
+	key, err := p.key(d.JWKSURI, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, signed, sig); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if err := verifyClaims(claims, d.Issuer, p.conf.ClientID, nonce, time.Now()); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// Identity return the username and the registry groups of the claims.
+// Only the groups in the groupmap config are returned.
+func (p *Provider) Identity(claims Claims) (string, []string) {
+	usernameClaim, groupsClaim := p.conf.UsernameClaim, p.conf.GroupsClaim
+	if usernameClaim == "" {
+		usernameClaim = defaultUsernameClaim
+	}
+	if groupsClaim == "" {
+		groupsClaim = defaultGroupsClaim
+	}
+	username := strings.ToLower(claims.String(usernameClaim))
+	groups := []string{}
+	for _, g := range claims.Strings(groupsClaim) {
+		if gName, ok := p.conf.GroupMap[g]; ok {
+			groups = append(groups, gName)
+		}
+	}
+	return username, groups
+}
+
+// ManagedGroups return the registry groups the provider manage the membership.
+func (p *Provider) ManagedGroups() []string {
+	groups := make([]string, 0, len(p.conf.GroupMap))
+	for _, gName := range p.conf.GroupMap {
+		groups = append(groups, gName)
+	}
+	return groups
+}
+
+// AutoCreate return whether to create the user not exist.
+func (p *Provider) AutoCreate() bool { return p.conf.AutoCreate }
+
+func randString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Challenge return the S256 PKCE challenge of the verifier.
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,165 @@
+// Package ratelimit limit the requests by token buckets, and lock the signin after repeated failures.
+// The state is kept in memory of each node.
+package ratelimit
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lodastack/registry/config"
+)
+
+// bucket is the token bucket of a key.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter limit the requests of each key by a token bucket,
+// which is filled by rate tokens per second and hold at most burst tokens.
+type Limiter struct {
+	rate  float64
+	burst int
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	throttled uint64
+	now       func() time.Time
+}
+
+// LimiterStats is the state of the limiter.
+type LimiterStats struct {
+	Rate      float64 `json:"rate"`
+	Burst     int     `json:"burst"`
+	Keys      int     `json:"keys"`
+	Throttled uint64  `json:"throttled"`
+}
+
+// NewLimiter return the limiter, the burst is at least 1.
+func NewLimiter(rate float64, burst int) *Limiter {
+	if burst < 1 {
+		burst = int(math.Max(1, math.Ceil(rate)))
+	}
+	return &Limiter{rate: rate, burst: burst, buckets: make(map[string]*bucket), now: time.Now}
+}
+
+// Allow take a token of the key, and return the time to wait for the next token if no token left.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(l.burst), b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	l.throttled++
+	return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+}
+
+// Purge remove the buckets which have been full, return the number removed.
+func (l *Limiter) Purge() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	purged := 0
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= float64(l.burst) {
+			delete(l.buckets, key)
+			purged++
+		}
+	}
+	return purged
+}
+
+// Stats return the state of the limiter.
+func (l *Limiter) Stats() LimiterStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return LimiterStats{Rate: l.rate, Burst: l.burst, Keys: len(l.buckets), Throttled: l.throttled}
+}
+
+// Route is the limiters of each IP and each user of the routes with the prefix, nil if not limited.
+type Route struct {
+	Prefix string
+	IP     *Limiter
+	User   *Limiter
+}
+
+// RouteStats is the state of the route limiters.
+type RouteStats struct {
+	Prefix string        `json:"prefix"`
+	IP     *LimiterStats `json:"ip,omitempty"`
+	User   *LimiterStats `json:"user,omitempty"`
+}
+
+// Routes is the route limiters ordered by the prefix length desc.
+type Routes []*Route
+
+// NewRoutes return the route limiters of the config.
+func NewRoutes(conf []config.RouteLimitConfig) Routes {
+	routes := make(Routes, 0, len(conf))
+	for _, c := range conf {
+		r := &Route{Prefix: c.Prefix}
+		if c.IPRate > 0 {
+			r.IP = NewLimiter(c.IPRate, c.IPBurst)
+		}
+		if c.UserRate > 0 {
+			r.User = NewLimiter(c.UserRate, c.UserBurst)
+		}
+		routes = append(routes, r)
+	}
+	sort.SliceStable(routes, func(i, j int) bool { return len(routes[i].Prefix) > len(routes[j].Prefix) })
+	return routes
+}
+
+// Match return the route of the longest prefix matched the path, nil if not found.
+func (rs Routes) Match(path string) *Route {
+	for _, r := range rs {
+		if strings.HasPrefix(path, r.Prefix) {
+			return r
+		}
+	}
+	return nil
+}
+
+// Purge remove the full buckets of all the routes.
+func (rs Routes) Purge() int {
+	purged := 0
+	for _, r := range rs {
+		if r.IP != nil {
+			purged += r.IP.Purge()
+		}
+		if r.User != nil {
+			purged += r.User.Purge()
+		}
+	}
+	return purged
+}
+
+// Stats return the state of all the routes.
+func (rs Routes) Stats() []RouteStats {
+	stats := make([]RouteStats, 0, len(rs))
+	for _, r := range rs {
+		s := RouteStats{Prefix: r.Prefix}
+		if r.IP != nil {
+			ip := r.IP.Stats()
+			s.IP = &ip
+		}
+		if r.User != nil {
+			user := r.User.Stats()
+			s.User = &user
+		}
+		stats = append(stats, s)
+	}
+	return stats
+}
@@ -0,0 +1,135 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lodastack/registry/config"
+)
+
+const (
+	defaultLockoutThreshold = 5
+	defaultLockoutBase      = 30 * time.Second
+	defaultLockoutMax       = time.Hour
+)
+
+type lockEntry struct {
+	failures int
+	until    time.Time
+	last     time.Time
+}
+
+// Lockout lock the key after threshold failures, for base duration doubled by every more failure up to max.
+type Lockout struct {
+	threshold int
+	base      time.Duration
+	max       time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*lockEntry
+	now     func() time.Time
+}
+
+// LockoutStats is the state of the lockout.
+type LockoutStats struct {
+	Threshold int `json:"threshold"`
+	Failing   int `json:"failing"`
+	Locked    int `json:"locked"`
+}
+
+// NewLockout return the lockout of the config, use the default if not set.
+func NewLockout(c config.LimitConfig) *Lockout {
+	l := &Lockout{
+		threshold: c.LockoutThreshold,
+		base:      time.Duration(c.LockoutBase) * time.Second,
+		max:       time.Duration(c.LockoutMax) * time.Second,
+		entries:   make(map[string]*lockEntry),
+		now:       time.Now,
+	}
+	if l.threshold <= 0 {
+		l.threshold = defaultLockoutThreshold
+	}
+	if l.base <= 0 {
+		l.base = defaultLockoutBase
+	}
+	if l.max <= 0 {
+		l.max = defaultLockoutMax
+	}
+	return l
+}
+
+// Locked return whether the key is locked and the time left.
+func (l *Lockout) Locked(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e, ok := l.entries[key]
+	if !ok {
+		return false, 0
+	}
+	if left := e.until.Sub(l.now()); left > 0 {
+		return true, left
+	}
+	return false, 0
+}
+
+// Fail record a failure of the key, return the time the key is locked, 0 if not locked.
+func (l *Lockout) Fail(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	e, ok := l.entries[key]
+	if !ok || now.Sub(e.last) > l.max {
+		e = &lockEntry{}
+		l.entries[key] = e
+	}
+	e.failures++
+	e.last = now
+	if e.failures < l.threshold {
+		return 0
+	}
+	d := l.base
+	for i := l.threshold; i < e.failures && d < l.max; i++ {
+		d *= 2
+	}
+	if d > l.max {
+		d = l.max
+	}
+	e.until = now.Add(d)
+	return d
+}
+
+// Reset clear the failures of the key.
+func (l *Lockout) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.entries, key)
+}
+
+// Purge remove the keys not failed in the max lock time, return the number removed.
+func (l *Lockout) Purge() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	purged := 0
+	for key, e := range l.entries {
+		if now.Sub(e.last) > l.max && now.After(e.until) {
+			delete(l.entries, key)
+			purged++
+		}
+	}
+	return purged
+}
+
+// Stats return the state of the lockout.
+func (l *Lockout) Stats() LockoutStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	stats := LockoutStats{Threshold: l.threshold, Failing: len(l.entries)}
+	for _, e := range l.entries {
+		if e.until.After(now) {
+			stats.Locked++
+		}
+	}
+	return stats
+}
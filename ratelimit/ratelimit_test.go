@@ -0,0 +1,105 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lodastack/registry/config"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time      { return c.t }
+func (c *fakeClock) add(d time.Duration) { c.t = c.t.Add(d) }
+func newFakeClock() *fakeClock           { return &fakeClock{t: time.Unix(1500000000, 0)} }
+
+func TestLimiter(t *testing.T) {
+	clock := newFakeClock()
+	l := NewLimiter(2, 3)
+	l.now = clock.now
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("ip1"); !ok {
+			t.Fatalf("request %d should be allowed by the burst", i)
+		}
+	}
+	ok, wait := l.Allow("ip1")
+	if ok || wait != 500*time.Millisecond {
+		t.Fatalf("request over the burst should wait 500ms, got %v %s", ok, wait)
+	}
+	if ok, _ := l.Allow("ip2"); !ok {
+		t.Fatal("other key should not be limited")
+	}
+
+	clock.add(500 * time.Millisecond)
+	if ok, _ := l.Allow("ip1"); !ok {
+		t.Fatal("request should be allowed after refilled")
+	}
+	if st := l.Stats(); st.Keys != 2 || st.Throttled != 1 {
+		t.Fatalf("stats not match expect: %+v", st)
+	}
+
+	clock.add(2 * time.Second)
+	if purged := l.Purge(); purged != 2 || l.Stats().Keys != 0 {
+		t.Fatalf("full buckets should be purged, got %d", purged)
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	routes := NewRoutes([]config.RouteLimitConfig{
+		{Prefix: "/api/v1", IPRate: 10, UserRate: 5},
+		{Prefix: "/api/v1/agent", IPRate: 1},
+	})
+	if r := routes.Match("/api/v1/agent/ns"); r == nil || r.Prefix != "/api/v1/agent" || r.User != nil {
+		t.Fatalf("longest prefix should be matched: %+v", r)
+	}
+	if r := routes.Match("/api/v1/ns"); r == nil || r.Prefix != "/api/v1" || r.IP == nil || r.User == nil {
+		t.Fatalf("route not match expect: %+v", r)
+	}
+	if r := routes.Match("/metrics"); r != nil {
+		t.Fatalf("route should not be matched: %+v", r)
+	}
+	if st := routes.Stats(); len(st) != 2 || st[0].IP.Burst != 1 || st[1].User.Burst != 5 {
+		t.Fatalf("stats not match expect: %+v", st)
+	}
+}
+
+func TestLockout(t *testing.T) {
+	clock := newFakeClock()
+	l := NewLockout(config.LimitConfig{LockoutThreshold: 3, LockoutBase: 10, LockoutMax: 30})
+	l.now = clock.now
+
+	for i := 0; i < 2; i++ {
+		if d := l.Fail("user:user1"); d != 0 {
+			t.Fatalf("should not be locked before the threshold, got %s", d)
+		}
+	}
+	if locked, _ := l.Locked("user:user1"); locked {
+		t.Fatal("should not be locked before the threshold")
+	}
+	for _, expect := range []time.Duration{10 * time.Second, 20 * time.Second, 30 * time.Second, 30 * time.Second} {
+		if d := l.Fail("user:user1"); d != expect {
+			t.Fatalf("lock time not match expect %s, got %s", expect, d)
+		}
+	}
+	if locked, left := l.Locked("user:user1"); !locked || left != 30*time.Second {
+		t.Fatalf("should be locked for 30s, got %v %s", locked, left)
+	}
+	if st := l.Stats(); st.Failing != 1 || st.Locked != 1 {
+		t.Fatalf("stats not match expect: %+v", st)
+	}
+
+	clock.add(31 * time.Second)
+	if locked, _ := l.Locked("user:user1"); locked {
+		t.Fatal("should be unlocked after the lock time")
+	}
+	if purged := l.Purge(); purged != 1 {
+		t.Fatalf("idle entry should be purged, got %d", purged)
+	}
+
+	l.Fail("user:user2")
+	l.Reset("user:user2")
+	if st := l.Stats(); st.Failing != 0 {
+		t.Fatalf("failures should be reset: %+v", st)
+	}
+}
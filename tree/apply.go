@@ -0,0 +1,210 @@
+package tree
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/lodastack/registry/authorize"
+	"github.com/lodastack/registry/common"
+	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/tree/node"
+
+	m "github.com/lodastack/store/model"
+)
+
+const (
+	// applyBucket save the last applied resource list of each node/resource type,
+	// used as the base of the three-way diff.
+	applyBucket = "apply"
+)
+
+func getApplyKey(nodeID, resType string) []byte { return []byte(nodeID + "-" + resType) }
+
+func (t *Tree) initApplyBucket() error {
+	if err := t.cluster.CreateBucketIfNotExist([]byte(applyBucket)); err != nil {
+		t.logger.Errorf("tree init %s CreateBucketIfNotExist fail: %s", applyBucket, err.Error())
+		return err
+	}
+	return nil
+}
+
+// lastApplied return the resource list applied last time.
+func (t *Tree) lastApplied(nodeID, resType string) (model.ResourceList, error) {
+	rl := model.ResourceList{}
+	v, err := t.cluster.View([]byte(applyBucket), getApplyKey(nodeID, resType))
+	if err != nil || len(v) == 0 {
+		return rl, err
+	}
+	err = json.Unmarshal(v, &rl)
+	return rl, err
+}
+
+// inSubtree check whether the ns is the root ns or under it.
+func inSubtree(ns, root string) bool {
+	return ns == root || strings.HasSuffix(ns, node.NodeDeli+root)
+}
+
+// prepareDesired normalize the desired resources the same way as the resource API does.
+func prepareDesired(resType string, rl model.ResourceList) (model.ResourceList, error) {
+	desired := make(model.ResourceList, len(rl))
+	for i, r := range rl {
+		desired[i] = model.Resource{}
+		for k, v := range r {
+			desired[i][k] = v
+		}
+	}
+	switch strings.TrimPrefix(resType, template) {
+	case model.Collect:
+		if err := model.UpdateCollectName(desired...); err != nil {
+			return nil, err
+		}
+	case model.Machine:
+		for i := range desired {
+			if status, _ := desired[i].ReadProperty(model.HostStatusProp); status == "" {
+				desired[i].SetProperty(model.HostStatusProp, model.Online)
+			}
+		}
+	}
+	return desired, nil
+}
+
+// finishAlarm regenerate the ns dependent property of alarm after merge.
+func finishAlarm(ns string, changes []model.ApplyChange, result model.ResourceList) error {
+	pk := model.PkProperty[model.Alarm]
+	for i := range changes {
+		if changes[i].Action == model.ApplyDelete {
+			continue
+		}
+		for j := range result {
+			if result[j][pk] != changes[i].Pk {
+				continue
+			}
+			id, _ := result[j].ID()
+			if groups, _ := result[j].ReadProperty("groups"); groups == "" {
+				result[j]["groups"] = authorize.GetNsOpGName(ns)
+			}
+			alarm, err := model.NewAlarmResourceByMap(ns, result[j], id)
+			if err != nil {
+				return err
+			}
+			result[j] = alarm
+			changes[i].After = alarm
+		}
+	}
+	return nil
+}
+
+// templateChange is a template of a ns changed by apply, which need to be propagated.
+type templateChange struct {
+	ns, resType string
+}
+
+// Apply make the resources of the ns subtree match the document.
+// The changes are computed by a three-way diff of the live resources, the document
+// and the resources applied last time, matched by model.PkProperty.
+// All changes are written in one batch, then the changed templates are propagated,
+// both under the tree lock so the descendants are not changed in between.
+// Return the plan without any change if dryRun.
+func (t *Tree) Apply(doc model.ApplyDocument, prune, dryRun bool) (model.ApplyPlan, error) {
+	t.Mu.Lock()
+	defer t.Mu.Unlock()
+	plan, err := t.apply(doc, prune, dryRun)
+	if err != nil || dryRun {
+		return plan, err
+	}
+
+	propagated := map[templateChange]bool{}
+	for _, c := range plan.Changes {
+		tc := templateChange{ns: c.NS, resType: c.Type}
+		if !isTemplate(c.Type) || model.PkOfType(c.Type) == "" || propagated[tc] {
+			continue
+		}
+		propagated[tc] = true
+		if _, err := t.propagateTemplate(tc.ns, tc.resType, false); err != nil {
+			t.logger.Errorf("propagate template %s of ns %s fail: %s", tc.resType, tc.ns, err.Error())
+			return plan, err
+		}
+	}
+	return plan, nil
+}
+
+// apply compute the changes of the document and write them in one batch if not dryRun.
+// The caller should hold the tree lock.
+func (t *Tree) apply(doc model.ApplyDocument, prune, dryRun bool) (model.ApplyPlan, error) {
+	plan := model.ApplyPlan{NS: doc.NS, Prune: prune, DryRun: dryRun, Changes: []model.ApplyChange{}}
+	if doc.NS == "" {
+		return plan, common.ErrInvalidParam
+	}
+
+	allNodes, err := t.AllNodes()
+	if err != nil {
+		return plan, err
+	}
+
+	rows := []m.Row{}
+	for ns, resMap := range doc.Resources {
+		if !inSubtree(ns, doc.NS) {
+			t.logger.Errorf("apply ns %s is not under %s", ns, doc.NS)
+			return plan, common.ErrInvalidParam
+		}
+		n, err := allNodes.GetByNS(ns)
+		if err != nil {
+			t.logger.Errorf("apply to ns %s fail: %v", ns, err)
+			return plan, err
+		}
+		for resType, rl := range resMap {
+			if !n.AllowResource(resType) {
+				return plan, common.ErrSetResourceToLeaf
+			}
+			desired, err := prepareDesired(resType, rl)
+			if err != nil {
+				return plan, err
+			}
+			live, err := t.resource.GetResourceList(ns, resType)
+			if err != nil {
+				return plan, err
+			}
+			applied, err := t.lastApplied(n.ID, resType)
+			if err != nil {
+				t.logger.Errorf("read last applied of ns %s type %s fail: %v", ns, resType, err)
+			}
+
+			result, changes, err := model.DiffResourceList(ns, resType, *live, desired, applied, prune)
+			if err != nil {
+				t.logger.Errorf("diff ns %s type %s fail: %v", ns, resType, err)
+				return plan, err
+			}
+			if strings.TrimPrefix(resType, template) == model.Alarm {
+				if err := finishAlarm(ns, changes, result); err != nil {
+					return plan, err
+				}
+			}
+			plan.Changes = append(plan.Changes, changes...)
+
+			appliedByte, err := json.Marshal(desired)
+			if err != nil {
+				return plan, err
+			}
+			rows = append(rows, m.Row{Bucket: []byte(applyBucket), Key: getApplyKey(n.ID, resType), Value: appliedByte})
+			if len(changes) == 0 {
+				continue
+			}
+			resByte := []byte{}
+			if len(result) != 0 {
+				if resByte, err = result.Marshal(); err != nil {
+					return plan, err
+				}
+			}
+			rows = append(rows, m.Row{Bucket: []byte(n.ID), Key: []byte(resType), Value: resByte})
+		}
+	}
+
+	if dryRun || len(rows) == 0 {
+		return plan, nil
+	}
+	if err := t.cluster.Batch(rows); err != nil {
+		t.logger.Errorf("apply ns %s fail: %s", doc.NS, err.Error())
+		return plan, err
+	}
+	return plan, nil
+}
@@ -0,0 +1,80 @@
+package tree
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/tree/node"
+	"github.com/lodastack/registry/tree/test_sample"
+)
+
+func TestApply(t *testing.T) {
+	s := test_sample.MustNewStore(t)
+	defer os.RemoveAll(s.Path())
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	tree, err := NewTree(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tree.NewNode("product", "", node.RootNode, node.NonLeaf); err != nil {
+		t.Fatalf("create nonleaf fail: %s", err.Error())
+	}
+	leafNs := "web.product." + node.RootNode
+	if _, err := tree.NewNode("web", "", "product."+node.RootNode, node.Leaf); err != nil {
+		t.Fatalf("create leaf fail: %s", err.Error())
+	}
+	if err := tree.SetResource(leafNs, model.Machine, model.ResourceList{
+		{"hostname": "h1", "ip": "10.0.0.1"},
+		{"hostname": "h2", "ip": "10.0.0.2"},
+	}); err != nil {
+		t.Fatalf("set machine fail: %s", err.Error())
+	}
+	before, _ := tree.GetResourceList(leafNs, model.Machine)
+	h1ID, _ := (*before)[0].ID()
+
+	doc := model.ApplyDocument{
+		NS: "product." + node.RootNode,
+		Resources: map[string]map[string]model.ResourceList{
+			leafNs: {model.Machine: {
+				{"hostname": "h1", "ip": "10.0.1.1"},
+				{"hostname": "h3", "ip": "10.0.0.3"},
+			}},
+		},
+	}
+
+	// case 1: dry run change nothing.
+	plan, err := tree.Apply(doc, true, true)
+	if err != nil || len(plan.Changes) != 3 {
+		t.Fatalf("dry run not match with expect, plan: %+v, error: %v", plan, err)
+	}
+	if rl, _ := tree.GetResourceList(leafNs, model.Machine); len(*rl) != 2 || (*rl)[0]["ip"] != "10.0.0.1" {
+		t.Fatalf("dry run should not change resource: %+v", *rl)
+	}
+
+	// case 2: apply with prune.
+	if plan, err = tree.Apply(doc, true, false); err != nil || len(plan.Changes) != 3 {
+		t.Fatalf("apply not match with expect, plan: %+v, error: %v", plan, err)
+	}
+	rl, _ := tree.GetResourceList(leafNs, model.Machine)
+	if len(*rl) != 2 || (*rl)[0]["ip"] != "10.0.1.1" || (*rl)[0][model.IdKey] != h1ID || (*rl)[1]["hostname"] != "h3" {
+		t.Fatalf("resource after apply not match with expect: %+v", *rl)
+	}
+
+	// case 3: apply again change nothing.
+	if plan, err = tree.Apply(doc, true, false); err != nil || len(plan.Changes) != 0 {
+		t.Fatalf("apply again not match with expect, plan: %+v, error: %v", plan, err)
+	}
+
+	// case 4: ns out of the subtree.
+	doc.NS = "other." + node.RootNode
+	if _, err = tree.Apply(doc, false, false); err == nil {
+		t.Fatal("apply ns out of subtree success, not match with expect")
+	}
+}
@@ -0,0 +1,99 @@
+package tree
+
+import (
+	"strings"
+
+	"github.com/lodastack/registry/common"
+	"github.com/lodastack/registry/tree/node"
+)
+
+func validLabelKey(key string) bool {
+	return key != "" && !strings.ContainsAny(key, "=!, ")
+}
+
+// updateLabels update the labels of the ns by the update function and save the tree.
+func (t *Tree) updateLabels(ns string, update func(n *node.Node)) error {
+	t.Mu.Lock()
+	defer t.Mu.Unlock()
+	allNodes, err := t.AllNodes()
+	if err != nil {
+		t.logger.Error("get all nodes error when update labels")
+		return err
+	}
+	n, err := allNodes.GetByNS(ns)
+	if err != nil {
+		t.logger.Errorf("GetByNs %s fail, error: %s", ns, err.Error())
+		return err
+	}
+	update(n)
+
+	t.Nodes = allNodes
+	if err := t.saveTree(); err != nil {
+		t.logger.Error("update labels save tree node fail,", err.Error())
+		return err
+	}
+	return nil
+}
+
+// SetNodeLabels add or update the labels of the ns.
+func (t *Tree) SetNodeLabels(ns string, labels map[string]string) error {
+	if len(labels) == 0 {
+		return common.ErrInvalidParam
+	}
+	for k, v := range labels {
+		if !validLabelKey(k) || strings.ContainsAny(v, ",") {
+			return common.ErrInvalidParam
+		}
+	}
+	return t.updateLabels(ns, func(n *node.Node) {
+		n.Labels = node.MergeLabels(n.Labels, labels)
+	})
+}
+
+// RemoveNodeLabels remove the labels of the ns by keys.
+func (t *Tree) RemoveNodeLabels(ns string, keys []string) error {
+	if len(keys) == 0 {
+		return common.ErrInvalidParam
+	}
+	return t.updateLabels(ns, func(n *node.Node) {
+		for _, k := range keys {
+			delete(n.Labels, k)
+		}
+		if len(n.Labels) == 0 {
+			n.Labels = nil
+		}
+	})
+}
+
+// GetNodeLabels return the labels of the ns and the labels inherited from its ancestors.
+func (t *Tree) GetNodeLabels(ns string) (labels, effective map[string]string, err error) {
+	allNodes, err := t.AllNodes()
+	if err != nil {
+		return nil, nil, err
+	}
+	n, err := allNodes.GetByNS(ns)
+	if err != nil {
+		return nil, nil, err
+	}
+	if effective, err = allNodes.EffectiveLabels(ns); err != nil {
+		return nil, nil, err
+	}
+	labels = n.Labels
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	return labels, effective, nil
+}
+
+// QueryNodeByLabels return the ns list which effective labels match the selector.
+func (t *Tree) QueryNodeByLabels(selector string, leafOnly bool) ([]string, error) {
+	sel, err := node.ParseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	allNodes, err := t.AllNodes()
+	if err != nil {
+		return nil, err
+	}
+	return allNodes.QueryByLabels(sel, leafOnly), nil
+}
@@ -1,8 +1,12 @@
 package tree
 
 import (
+	"time"
+
 	"github.com/lodastack/registry/model"
 	"github.com/lodastack/registry/tree/node"
+
+	m "github.com/lodastack/store/model"
 )
 
 type nodeInf interface {
@@ -20,6 +24,12 @@ type nodeInf interface {
 
 	// Return leaf child node of the ns.
 	LeafChildIDs(ns string) ([]string, error)
+
+	// NodeCounts return the leaf and machine number of the node and all its children.
+	NodeCounts(ns string, n *node.Node) (map[string]node.Count, error)
+
+	// NsStats return the roll-up resource counts of the ns and all its children.
+	NsStats(ns string) (map[string]model.NsStats, error)
 }
 
 type resourceInf interface {
@@ -35,6 +45,9 @@ type resourceInf interface {
 	// SearchResourceByNs return the map[ns]resources which match the search.
 	SearchResource(ns, resType string, search model.ResourceSearch) (map[string]*model.ResourceList, error)
 
+	// GlobalSearch search the resources of the types under the ns, all types if not set.
+	GlobalSearch(ns string, search model.GlobalSearch, types ...string) (model.GlobalSearchResult, error)
+
 	// Update Resource By ns and ResourceID.
 	UpdateResource(ns, resType, resID string, updateMap map[string]string) error
 
@@ -81,6 +94,54 @@ type TreeMethod interface {
 	// Update the node property.
 	UpdateNode(ns string, name, comment, machineReg string) error
 
+	// SetNodeLabels add or update the labels of the ns.
+	SetNodeLabels(ns string, labels map[string]string) error
+
+	// RemoveNodeLabels remove the labels of the ns by keys.
+	RemoveNodeLabels(ns string, keys []string) error
+
+	// GetNodeLabels return the labels of the ns and the labels inherited from its ancestors.
+	GetNodeLabels(ns string) (labels, effective map[string]string, err error)
+
+	// QueryNodeByLabels return the ns list which labels match the selector.
+	QueryNodeByLabels(selector string, leafOnly bool) ([]string, error)
+
+	// MoveNode move the ns subtree under newParentNs with newName, return the new ns.
+	MoveNode(ns, newParentNs, newName string, extraRows ...m.Row) (string, error)
+
 	// RemoveNode remove the node with delID from parentNs.
 	RemoveNode(ns string) error
+
+	// TrashNode remove the ns from the tree and keep it in trash.
+	TrashNode(ns, operator string, retention time.Duration, groups []byte, extraRows ...m.Row) (model.Trash, error)
+
+	// ListTrash return the removed ns in trash.
+	ListTrash() ([]model.Trash, error)
+
+	// GetTrash return the trash by ID.
+	GetTrash(id string) (model.Trash, error)
+
+	// RestoreTrash put the removed ns back to the tree.
+	RestoreTrash(id string, extraRows ...m.Row) (string, error)
+
+	// PurgeTrash remove the trash and the buckets of its nodes.
+	PurgeTrash(id string) error
+
+	// PurgeExpiredTrash purge the trash expired.
+	PurgeExpiredTrash() (int, error)
+
+	// RemovePreview return the plan of removing the ns and all its children.
+	RemovePreview(ns string, moveToPool bool) (model.RemovePlan, error)
+
+	// RemoveNodeRecursive move the ns and all its children to trash by the confirmed plan.
+	RemoveNodeRecursive(plan model.RemovePlan, operator string, retention time.Duration, groups []byte, extraRows ...m.Row) (model.Trash, error)
+
+	// Apply make the resources of a ns subtree match the desired state document.
+	Apply(doc model.ApplyDocument, prune, dryRun bool) (model.ApplyPlan, error)
+
+	// PropagateTemplate propagate the template of a NonLeaf ns to its descendants.
+	PropagateTemplate(ns, tmplType string, dryRun bool) ([]model.ApplyChange, error)
+
+	// EffectiveResource return the resource list of ns annotated with its origin.
+	EffectiveResource(ns, resType string) ([]model.EffectiveResource, error)
 }
@@ -0,0 +1,139 @@
+package tree
+
+import (
+	"strings"
+
+	"github.com/lodastack/registry/authorize"
+	"github.com/lodastack/registry/common"
+	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/tree/node"
+
+	m "github.com/lodastack/store/model"
+)
+
+// renameGroups rename every group in the comma separated groups property.
+func renameGroups(groups, oldNs, newNs string) string {
+	gList := strings.Split(groups, ",")
+	for i := range gList {
+		gList[i] = authorize.RenameGName(gList[i], oldNs, newNs)
+	}
+	return strings.Join(gList, ",")
+}
+
+// moveAlarmRows return the rows which rewrite the ns dependent property
+// of the alarm/alarm template of node n, which ns changed from oldNs to newNs.
+func (t *Tree) moveAlarmRows(n *node.Node, nodeNs, oldNs, newNs string) ([]m.Row, error) {
+	rows := []m.Row{}
+	movedNs := nodeNs[:len(nodeNs)-len(oldNs)] + newNs
+	for _, resType := range []string{model.Alarm, template + model.Alarm} {
+		if !n.AllowResource(resType) {
+			continue
+		}
+		rl, err := t.getResourceListByNodeID(n.ID, resType)
+		if err != nil {
+			return nil, err
+		}
+		if len(rl) == 0 {
+			continue
+		}
+		for i := range rl {
+			if groups, _ := rl[i].ReadProperty("groups"); groups != "" {
+				rl[i]["groups"] = renameGroups(groups, oldNs, newNs)
+			}
+			if resType != model.Alarm {
+				continue
+			}
+			id, _ := rl[i].ID()
+			if rl[i], err = model.NewAlarmResourceByMap(movedNs, rl[i], id); err != nil {
+				t.logger.Errorf("rewrite alarm %s of ns %s fail: %s", id, nodeNs, err.Error())
+				return nil, err
+			}
+		}
+		resByte, err := rl.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, m.Row{Bucket: []byte(n.ID), Key: []byte(resType), Value: resByte})
+	}
+
+	for _, child := range n.Children {
+		childRows, err := t.moveAlarmRows(child, node.Join([]string{child.Name, nodeNs}), oldNs, newNs)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, childRows...)
+	}
+	return rows, nil
+}
+
+// MoveNode move the ns subtree under newParentNs and rename it to newName,
+// keep the name if newName is empty. Return the new ns.
+// The alarms of the subtree are rewritten by the new ns, extraRows
+// (e.g. the rows rename the groups) are written with the tree in one batch.
+func (t *Tree) MoveNode(ns, newParentNs, newName string, extraRows ...m.Row) (string, error) {
+	oldParentNs, err := getParentNS(ns)
+	if err != nil {
+		t.logger.Errorf("move ns fail because the ns is root node or invalid, ns: %s", ns)
+		return "", err
+	}
+	if newParentNs == "" {
+		newParentNs = oldParentNs
+	}
+	if newName == "" {
+		newName = node.Split(ns)[0]
+	}
+	newNs := node.Join([]string{newName, newParentNs})
+	if newNs == ns {
+		return ns, nil
+	}
+	if inSubtree(newParentNs, ns) {
+		return "", common.ErrMoveUnderItself
+	}
+
+	t.Mu.Lock()
+	defer t.Mu.Unlock()
+	allNodes, err := t.AllNodes()
+	if err != nil {
+		t.logger.Error("get all nodes error when MoveNode")
+		return "", err
+	}
+	if allNodes.Exist(newNs) {
+		return "", common.ErrNodeAlreadyExist
+	}
+	moveNode, err := allNodes.GetByNS(ns)
+	if err != nil {
+		return "", err
+	}
+	oldParent, err := allNodes.GetByNS(oldParentNs)
+	if err != nil {
+		return "", err
+	}
+	newParent, err := allNodes.GetByNS(newParentNs)
+	if err != nil {
+		return "", err
+	}
+	if newParent.IsLeaf() {
+		return "", common.ErrCreateNodeUnderLeaf
+	}
+
+	rows, err := t.moveAlarmRows(moveNode, ns, ns, newNs)
+	if err != nil {
+		return "", err
+	}
+
+	for i, child := range oldParent.Children {
+		if child.ID == moveNode.ID {
+			oldParent.Children = append(oldParent.Children[:i], oldParent.Children[i+1:]...)
+			break
+		}
+	}
+	moveNode.Name = newName
+	newParent.Children = append(newParent.Children, moveNode)
+	rows = append(rows, extraRows...)
+	if err := t.batchWithTree(allNodes, rows); err != nil {
+		t.logger.Errorf("move ns %s to %s fail: %s", ns, newNs, err.Error())
+		return "", err
+	}
+	t.logger.Infof("move ns %s to %s success", ns, newNs)
+	return newNs, nil
+}
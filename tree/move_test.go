@@ -0,0 +1,65 @@
+package tree
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lodastack/models"
+	"github.com/lodastack/registry/common"
+	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/tree/node"
+	"github.com/lodastack/registry/tree/test_sample"
+)
+
+func TestMoveNode(t *testing.T) {
+	s := test_sample.MustNewStore(t)
+	defer os.RemoveAll(s.Path())
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	tree, err := NewTree(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"product", "infra"} {
+		if _, err := tree.NewNode(name, "", node.RootNode, node.NonLeaf); err != nil {
+			t.Fatalf("create nonleaf fail: %s", err.Error())
+		}
+	}
+	if _, err := tree.NewNode("web", "", "product."+node.RootNode, node.Leaf); err != nil {
+		t.Fatalf("create leaf fail: %s", err.Error())
+	}
+	before, _ := tree.GetResourceList("web.product."+node.RootNode, model.Alarm)
+
+	// case 1: not allow move under itself or to a exist ns.
+	if _, err := tree.MoveNode("product."+node.RootNode, "web.product."+node.RootNode, ""); err != common.ErrMoveUnderItself {
+		t.Fatalf("move under itself not match with expect: %v", err)
+	}
+	if _, err := tree.MoveNode("product."+node.RootNode, node.RootNode, "infra"); err != common.ErrNodeAlreadyExist {
+		t.Fatalf("move to exist ns not match with expect: %v", err)
+	}
+
+	// case 2: move and rename the leaf.
+	newNs, err := tree.MoveNode("web.product."+node.RootNode, "infra."+node.RootNode, "www")
+	if err != nil || newNs != "www.infra."+node.RootNode {
+		t.Fatalf("move node fail: %s, %v", newNs, err)
+	}
+	if _, err := tree.GetNodeByNS("web.product." + node.RootNode); err == nil {
+		t.Fatal("old ns still exist after move")
+	}
+	alarms, err := tree.GetResourceList(newNs, model.Alarm)
+	if err != nil || len(*alarms) != len(*before) {
+		t.Fatalf("alarm after move not match with expect: %v, %v", alarms, err)
+	}
+	for i, alarm := range *alarms {
+		if alarm["db"] != models.DBPrefix+newNs || alarm["groups"] != "loda.infra.www-op" ||
+			!strings.HasPrefix(alarm["version"], newNs) || alarm[model.IdKey] != (*before)[i][model.IdKey] {
+			t.Fatalf("alarm after move not match with expect: %+v", alarm)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package tree
 
 import (
 	"github.com/lodastack/registry/common"
+	"github.com/lodastack/registry/model"
 	"github.com/lodastack/registry/tree/node"
 )
 
@@ -48,3 +49,25 @@ func (t *Tree) LeafChildIDs(ns string) (l []string, err error) {
 	}
 	return
 }
+
+// NodeCounts return the nodeID-Count map of the node of the ns and all its children.
+// The machine numbers are read from the cached stats of the ns, not the store.
+func (t *Tree) NodeCounts(ns string, n *node.Node) (map[string]node.Count, error) {
+	stats, err := t.NsStats(ns)
+	if err != nil {
+		return nil, err
+	}
+	machines := map[string]int{}
+	var leafMachines func(n *node.Node, ns string)
+	leafMachines = func(n *node.Node, ns string) {
+		if n.IsLeaf() {
+			machines[n.ID] = stats[ns].Resources[model.Machine]
+			return
+		}
+		for _, child := range n.Children {
+			leafMachines(child, node.Join([]string{child.Name, ns}))
+		}
+	}
+	leafMachines(n, ns)
+	return n.Counts(machines), nil
+}
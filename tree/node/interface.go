@@ -3,32 +3,47 @@ package node
 import (
 	"github.com/lodastack/registry/common"
 	"github.com/lodastack/registry/tree/cluster"
+
+	m "github.com/lodastack/store/model"
 )
 
 // Inf is the interface node have.
 type Inf interface {
 	// GetNodeByNS return the node by ns.
 	// e.g return root node if get ns $RootNode, default is "loda".
+	// The node is shared with the tree cache, copy it before modify.
 	GetNodeByNS(ns string) (*Node, error)
 
 	// LeafChildIDs return leaf child node ID list of the ns.
 	LeafChildIDs(ns string) ([]string, error)
 
-	// GetNodeIDByNS return the NS of the node ID.
+	// GetNodeIDByNS return the node ID of the ns.
 	GetNodeIDByNS(ns string) (string, error)
 
-	// GetNodeNSByID returh the node ID of the ns.
+	// GetNodeNSByID return the ns of the node ID.
 	GetNodeNSByID(id string) (string, error)
 
-	// AllNodes return the root node.
+	// AllNodes return a copy of the root node, could be modified and saved.
 	AllNodes() (*Node, error)
 
-	// Save the []byte of the nodes.
-	Save(nodeData []byte) error
+	// Init migrate the tree saved as one blob to node records if need,
+	// return whether the tree is inited.
+	Init() (bool, error)
+
+	// Save the changed nodes of the tree.
+	Save(nodes *Node) error
+
+	// Rows return the rows which save the changed nodes of the tree and the new version,
+	// used to save the tree with other data in one batch. Commit after the batch success.
+	Rows(nodes *Node) ([]m.Row, string, error)
+
+	// Commit use the tree saved by Rows as the current tree.
+	Commit(nodes *Node, version string) error
 }
 
 type node struct {
-	cluster cluster.Inf
+	cluster  cluster.Inf
+	snapshot snapshot
 }
 
 // return a node interface object.
@@ -36,43 +51,30 @@ func NewNode(cluster cluster.Inf) Inf {
 	return &node{cluster: cluster}
 }
 
-func (n *node) Save(nodeByte []byte) error {
-	return n.cluster.Update([]byte(NodeDataBucketID), []byte(NodeDataKey), nodeByte)
-}
-
 // Get value from cluster by bucketID and resType.
 func (m *node) getByteFromStore(bucketID, resType string) ([]byte, error) {
 	return m.cluster.View([]byte(bucketID), []byte(resType))
 }
 
-// get allnodes from cluster.
-func (m *node) allNodeByte() ([]byte, error) {
-	return m.getByteFromStore(NodeDataBucketID, NodeDataKey)
-}
-
 // AllNodes return the whole nodes.
 func (m *node) AllNodes() (*Node, error) {
-	v, err := m.allNodeByte()
-	if err != nil || len(v) == 0 {
-		return nil, common.ErrGetNode
-	}
-
-	allNode, err := getAllNodeByByte(v)
+	idx, err := m.current()
 	if err != nil {
-		return nil, common.ErrGetNode
+		return nil, err
 	}
-	return allNode, nil
+	// return the copy of node
+	return (&Node{}).Copy(idx.tree), nil
 }
 
 // GetNSByID return NS by NodeID.
 func (m *node) GetNodeNSByID(id string) (string, error) {
-	nodes, err := m.AllNodes()
+	idx, err := m.current()
 	if err != nil {
 		return "", err
 	}
-	_, ns, err := nodes.GetByID(id)
-	if err != nil {
-		return "", err
+	ns, ok := idx.ids[id]
+	if !ok {
+		return "", common.ErrNodeNotFound
 	}
 	return ns, nil
 }
@@ -90,23 +92,19 @@ func (m *node) GetNodeByNS(ns string) (*Node, error) {
 	if ns == "" {
 		return nil, common.ErrInvalidParam
 	}
-	// TODO: use nodeidKey as cache
-	nodes, err := m.AllNodes()
+	idx, err := m.current()
 	if err != nil {
 		return nil, err
 	}
-	return nodes.GetByNS(ns)
+	node, ok := idx.nss[ns]
+	if !ok {
+		return nil, common.ErrNodeNotFound
+	}
+	return node, nil
 }
 
 // Return leaf IDs of the ns.
 func (m *node) LeafChildIDs(ns string) ([]string, error) {
-	// check the ns exist and valid or not.
-	nodeID, err := m.GetNodeIDByNS(ns)
-	if nodeID == "" || err != nil {
-		return nil, err
-	}
-
-	// read the tree if not get from cache.
 	node, err := m.GetNodeByNS(ns)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,117 @@
+package node
+
+import (
+	"strings"
+
+	"github.com/lodastack/registry/common"
+)
+
+// Requirement is one condition of a label selector.
+// Operator is "=", "!=" or "" which require the label exist.
+type Requirement struct {
+	Key      string
+	Operator string
+	Value    string
+}
+
+// Selector is a comma separated label selector, e.g: env=prod,tier!=db,owner.
+// A node match the selector only if it match all the requirements.
+type Selector []Requirement
+
+// ParseSelector parse the label selector.
+func ParseSelector(selector string) (Selector, error) {
+	sel := Selector{}
+	for _, part := range strings.Split(selector, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		r := Requirement{}
+		if i := strings.Index(part, "!="); i >= 0 {
+			r = Requirement{Key: part[:i], Operator: "!=", Value: part[i+2:]}
+		} else if i := strings.Index(part, "="); i >= 0 {
+			r = Requirement{Key: part[:i], Operator: "=", Value: part[i+1:]}
+		} else {
+			r.Key = part
+		}
+		r.Key, r.Value = strings.TrimSpace(r.Key), strings.TrimSpace(r.Value)
+		if r.Key == "" {
+			return nil, common.ErrInvalidParam
+		}
+		sel = append(sel, r)
+	}
+	if len(sel) == 0 {
+		return nil, common.ErrInvalidParam
+	}
+	return sel, nil
+}
+
+// Match check whether the labels match the selector.
+func (sel Selector) Match(labels map[string]string) bool {
+	for _, r := range sel {
+		v, ok := labels[r.Key]
+		switch r.Operator {
+		case "=":
+			if !ok || v != r.Value {
+				return false
+			}
+		case "!=":
+			if ok && v == r.Value {
+				return false
+			}
+		default:
+			if !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// MergeLabels return the labels of the child inherit from the parent,
+// the label of child override the same key of parent.
+func MergeLabels(parent, child map[string]string) map[string]string {
+	labels := make(map[string]string, len(parent)+len(child))
+	for k, v := range parent {
+		labels[k] = v
+	}
+	for k, v := range child {
+		labels[k] = v
+	}
+	return labels
+}
+
+// EffectiveLabels return the labels of the ns merged with the labels of its ancestors.
+func (n *Node) EffectiveLabels(ns string) (map[string]string, error) {
+	nsSplit := Split(ns)
+	labels := CopyLabels(n.Labels)
+	for i := len(nsSplit) - 2; i >= 0; i-- {
+		ancestor, err := n.GetByNS(Join(nsSplit[i:]))
+		if err != nil {
+			return nil, err
+		}
+		labels = MergeLabels(labels, ancestor.Labels)
+	}
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	return labels, nil
+}
+
+// QueryByLabels return the ns list of the nodes which effective labels match the selector.
+// Only leaf nodes are returned if leafOnly.
+func (n *Node) QueryByLabels(sel Selector, leafOnly bool) []string {
+	result := []string{}
+	var walk func(node *Node, ns string, inherited map[string]string)
+	walk = func(node *Node, ns string, inherited map[string]string) {
+		labels := MergeLabels(inherited, node.Labels)
+		if (!leafOnly || node.IsLeaf()) && sel.Match(labels) {
+			result = append(result, ns)
+		}
+		for _, child := range node.Children {
+			walk(child, Join([]string{child.Name, ns}), labels)
+		}
+	}
+	walk(n, n.Name, nil)
+	return result
+}
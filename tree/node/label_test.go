@@ -0,0 +1,63 @@
+package node
+
+import (
+	"testing"
+)
+
+func TestQueryByLabels(t *testing.T) {
+	tree := &Node{
+		NodeProperty: NodeProperty{Name: RootNode, Type: NonLeaf},
+		Children: []*Node{
+			{
+				NodeProperty: NodeProperty{Name: "product", Type: NonLeaf, Labels: map[string]string{"env": "prod", "owner": "a"}},
+				Children: []*Node{
+					{NodeProperty: NodeProperty{Name: "db", Type: Leaf, Labels: map[string]string{"tier": "db"}}},
+					{NodeProperty: NodeProperty{Name: "web", Type: Leaf, Labels: map[string]string{"tier": "web", "owner": "b"}}},
+				},
+			},
+			{NodeProperty: NodeProperty{Name: "test", Type: Leaf, Labels: map[string]string{"env": "test", "tier": "db"}}},
+		},
+	}
+
+	// case 1: marshal and unmarshal keep the labels.
+	b, err := tree.MarshalJSON()
+	if err != nil {
+		t.Fatalf("marshal fail: %s", err.Error())
+	}
+	copied := &Node{}
+	if err := copied.UnmarshalJSON(b); err != nil || copied.Children[0].Labels["env"] != "prod" {
+		t.Fatalf("unmarshal not match with expect: %+v, %v", copied.Children[0], err)
+	}
+
+	// case 2: labels inherited from the parent.
+	labels, err := tree.EffectiveLabels("web.product." + RootNode)
+	if err != nil || labels["env"] != "prod" || labels["owner"] != "b" || labels["tier"] != "web" {
+		t.Fatalf("effective labels not match with expect: %+v, %v", labels, err)
+	}
+
+	// case 3: query.
+	testCase := map[string][]string{
+		"env=prod,tier=db": {"db.product." + RootNode},
+		"tier=db":          {"db.product." + RootNode, "test." + RootNode},
+		"env!=prod,tier":   {"test." + RootNode},
+		"owner=a":          {"db.product." + RootNode},
+	}
+	for selector, expect := range testCase {
+		sel, err := ParseSelector(selector)
+		if err != nil {
+			t.Fatalf("parse selector %s fail: %s", selector, err.Error())
+		}
+		result := tree.QueryByLabels(sel, true)
+		if len(result) != len(expect) {
+			t.Fatalf("query %s not match with expect: %v", selector, result)
+		}
+		for i := range expect {
+			if result[i] != expect[i] {
+				t.Fatalf("query %s not match with expect: %v", selector, result)
+			}
+		}
+	}
+	if _, err := ParseSelector(",=prod"); err == nil {
+		t.Fatal("parse invalid selector success, not match with expect")
+	}
+}
@@ -104,6 +104,9 @@ type NodeProperty struct {
 	// regexp of machine in one node,
 	// used to auto add a machine into nodes
 	MachineReg string `json:"machinereg"`
+
+	// labels of the node, e.g. env=prod, inherited by the children.
+	Labels map[string]string `json:"labels,omitempty"`
 }
 
 // Node is the item of tree, it has machine match stregy and resource.
@@ -134,6 +137,7 @@ func (n *Node) Copy(ori *Node) *Node {
 			Comment:    ori.Comment,
 			Type:       ori.Type,
 			MachineReg: ori.MachineReg,
+			Labels:     CopyLabels(ori.Labels),
 		},
 		make([]*Node, len(ori.Children)),
 	}
@@ -144,6 +148,18 @@ func (n *Node) Copy(ori *Node) *Node {
 	return n
 }
 
+// CopyLabels return a copy of the labels, nil if the labels is nil.
+func CopyLabels(ori map[string]string) map[string]string {
+	if ori == nil {
+		return nil
+	}
+	labels := make(map[string]string, len(ori))
+	for k, v := range ori {
+		labels[k] = v
+	}
+	return labels
+}
+
 // Update update node machineMatchStrategy property.
 func (n *Node) Update(name, comment, machineMatchStrategy string) {
 	if name != "" {
@@ -34,7 +34,7 @@ func (j *Node) MarshalJSONBuf(buf fflib.EncodingBuffer) error {
 	var obj []byte
 	_ = obj
 	_ = err
-	buf.WriteString(`{"children":`)
+	buf.WriteString(`{ "children":`)
 	if j.Children != nil {
 		buf.WriteString(`[`)
 		for i, v := range j.Children {
@@ -71,6 +71,24 @@ func (j *Node) MarshalJSONBuf(buf fflib.EncodingBuffer) error {
 	fflib.FormatBits2(buf, uint64(j.Type), 10, j.Type < 0)
 	buf.WriteString(`,"machinereg":`)
 	fflib.WriteJsonString(buf, string(j.MachineReg))
+	buf.WriteByte(',')
+	if len(j.Labels) != 0 {
+		if j.Labels == nil {
+			buf.WriteString(`"labels":null`)
+		} else {
+			buf.WriteString(`"labels":{ `)
+			for key, value := range j.Labels {
+				fflib.WriteJsonString(buf, key)
+				buf.WriteString(`:`)
+				fflib.WriteJsonString(buf, string(value))
+				buf.WriteByte(',')
+			}
+			buf.Rewind(1)
+			buf.WriteByte('}')
+		}
+		buf.WriteByte(',')
+	}
+	buf.Rewind(1)
 	buf.WriteByte('}')
 	return nil
 }
@@ -90,6 +108,8 @@ const (
 	ffjtNodeType
 
 	ffjtNodeMachineReg
+
+	ffjtNodeLabels
 )
 
 var ffjKeyNodeChildren = []byte("children")
@@ -104,6 +124,8 @@ var ffjKeyNodeType = []byte("type")
 
 var ffjKeyNodeMachineReg = []byte("machinereg")
 
+var ffjKeyNodeLabels = []byte("labels")
+
 // UnmarshalJSON umarshall json - template of ffjson
 func (j *Node) UnmarshalJSON(input []byte) error {
 	fs := fflib.NewFFLexer(input)
@@ -186,6 +208,14 @@ mainparse:
 						goto mainparse
 					}
 
+				case 'l':
+
+					if bytes.Equal(ffjKeyNodeLabels, kn) {
+						currentKey = ffjtNodeLabels
+						state = fflib.FFParse_want_colon
+						goto mainparse
+					}
+
 				case 'm':
 
 					if bytes.Equal(ffjKeyNodeMachineReg, kn) {
@@ -212,6 +242,12 @@ mainparse:
 
 				}
 
+				if fflib.EqualFoldRight(ffjKeyNodeLabels, kn) {
+					currentKey = ffjtNodeLabels
+					state = fflib.FFParse_want_colon
+					goto mainparse
+				}
+
 				if fflib.SimpleLetterEqualFold(ffjKeyNodeMachineReg, kn) {
 					currentKey = ffjtNodeMachineReg
 					state = fflib.FFParse_want_colon
@@ -283,6 +319,9 @@ mainparse:
 				case ffjtNodeMachineReg:
 					goto handle_MachineReg
 
+				case ffjtNodeLabels:
+					goto handle_Labels
+
 				case ffjtNodenosuchkey:
 					err = fs.SkipField(tok)
 					if err != nil {
@@ -505,6 +544,111 @@ handle_MachineReg:
 	state = fflib.FFParse_after_value
 	goto mainparse
 
+handle_Labels:
+
+	/* handler: j.Labels type=map[string]string kind=map quoted=false*/
+
+	{
+
+		{
+			if tok != fflib.FFTok_left_bracket && tok != fflib.FFTok_null {
+				return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for ", tok))
+			}
+		}
+
+		if tok == fflib.FFTok_null {
+			j.Labels = nil
+		} else {
+
+			j.Labels = make(map[string]string, 0)
+
+			wantVal := true
+
+			for {
+
+				var k string
+
+				var tmpJLabels string
+
+				tok = fs.Scan()
+				if tok == fflib.FFTok_error {
+					goto tokerror
+				}
+				if tok == fflib.FFTok_right_bracket {
+					break
+				}
+
+				if tok == fflib.FFTok_comma {
+					if wantVal == true {
+						// TODO(pquerna): this isn't an ideal error message, this handles
+						// things like [,,,] as an array value.
+						return fs.WrapErr(fmt.Errorf("wanted value token, but got token: %v", tok))
+					}
+					continue
+				} else {
+					wantVal = true
+				}
+
+				/* handler: k type=string kind=string quoted=false*/
+
+				{
+
+					{
+						if tok != fflib.FFTok_string && tok != fflib.FFTok_null {
+							return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for string", tok))
+						}
+					}
+
+					if tok == fflib.FFTok_null {
+
+					} else {
+
+						outBuf := fs.Output.Bytes()
+
+						k = string(string(outBuf))
+
+					}
+				}
+
+				// Expect ':' after key
+				tok = fs.Scan()
+				if tok != fflib.FFTok_colon {
+					return fs.WrapErr(fmt.Errorf("wanted colon token, but got token: %v", tok))
+				}
+
+				tok = fs.Scan()
+				/* handler: tmpJLabels type=string kind=string quoted=false*/
+
+				{
+
+					{
+						if tok != fflib.FFTok_string && tok != fflib.FFTok_null {
+							return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for string", tok))
+						}
+					}
+
+					if tok == fflib.FFTok_null {
+
+					} else {
+
+						outBuf := fs.Output.Bytes()
+
+						tmpJLabels = string(string(outBuf))
+
+					}
+				}
+
+				j.Labels[k] = tmpJLabels
+
+				wantVal = false
+			}
+
+		}
+	}
+
+	state = fflib.FFParse_after_value
+	goto mainparse
+
 wantedvalue:
 	return fs.WrapErr(fmt.Errorf("wanted value token, but got token: %v", tok))
 wrongtokenerror:
@@ -547,7 +691,7 @@ func (j *NodeProperty) MarshalJSONBuf(buf fflib.EncodingBuffer) error {
 	var obj []byte
 	_ = obj
 	_ = err
-	buf.WriteString(`{"id":`)
+	buf.WriteString(`{ "id":`)
 	fflib.WriteJsonString(buf, string(j.ID))
 	buf.WriteString(`,"name":`)
 	fflib.WriteJsonString(buf, string(j.Name))
@@ -557,6 +701,24 @@ func (j *NodeProperty) MarshalJSONBuf(buf fflib.EncodingBuffer) error {
 	fflib.FormatBits2(buf, uint64(j.Type), 10, j.Type < 0)
 	buf.WriteString(`,"machinereg":`)
 	fflib.WriteJsonString(buf, string(j.MachineReg))
+	buf.WriteByte(',')
+	if len(j.Labels) != 0 {
+		if j.Labels == nil {
+			buf.WriteString(`"labels":null`)
+		} else {
+			buf.WriteString(`"labels":{ `)
+			for key, value := range j.Labels {
+				fflib.WriteJsonString(buf, key)
+				buf.WriteString(`:`)
+				fflib.WriteJsonString(buf, string(value))
+				buf.WriteByte(',')
+			}
+			buf.Rewind(1)
+			buf.WriteByte('}')
+		}
+		buf.WriteByte(',')
+	}
+	buf.Rewind(1)
 	buf.WriteByte('}')
 	return nil
 }
@@ -574,6 +736,8 @@ const (
 	ffjtNodePropertyType
 
 	ffjtNodePropertyMachineReg
+
+	ffjtNodePropertyLabels
 )
 
 var ffjKeyNodePropertyID = []byte("id")
@@ -586,6 +750,8 @@ var ffjKeyNodePropertyType = []byte("type")
 
 var ffjKeyNodePropertyMachineReg = []byte("machinereg")
 
+var ffjKeyNodePropertyLabels = []byte("labels")
+
 // UnmarshalJSON umarshall json - template of ffjson
 func (j *NodeProperty) UnmarshalJSON(input []byte) error {
 	fs := fflib.NewFFLexer(input)
@@ -663,6 +829,14 @@ mainparse:
 						goto mainparse
 					}
 
+				case 'l':
+
+					if bytes.Equal(ffjKeyNodePropertyLabels, kn) {
+						currentKey = ffjtNodePropertyLabels
+						state = fflib.FFParse_want_colon
+						goto mainparse
+					}
+
 				case 'm':
 
 					if bytes.Equal(ffjKeyNodePropertyMachineReg, kn) {
@@ -689,6 +863,12 @@ mainparse:
 
 				}
 
+				if fflib.EqualFoldRight(ffjKeyNodePropertyLabels, kn) {
+					currentKey = ffjtNodePropertyLabels
+					state = fflib.FFParse_want_colon
+					goto mainparse
+				}
+
 				if fflib.SimpleLetterEqualFold(ffjKeyNodePropertyMachineReg, kn) {
 					currentKey = ffjtNodePropertyMachineReg
 					state = fflib.FFParse_want_colon
@@ -751,6 +931,9 @@ mainparse:
 				case ffjtNodePropertyMachineReg:
 					goto handle_MachineReg
 
+				case ffjtNodePropertyLabels:
+					goto handle_Labels
+
 				case ffjtNodePropertynosuchkey:
 					err = fs.SkipField(tok)
 					if err != nil {
@@ -899,6 +1082,111 @@ handle_MachineReg:
 	state = fflib.FFParse_after_value
 	goto mainparse
 
+handle_Labels:
+
+	/* handler: j.Labels type=map[string]string kind=map quoted=false*/
+
+	{
+
+		{
+			if tok != fflib.FFTok_left_bracket && tok != fflib.FFTok_null {
+				return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for ", tok))
+			}
+		}
+
+		if tok == fflib.FFTok_null {
+			j.Labels = nil
+		} else {
+
+			j.Labels = make(map[string]string, 0)
+
+			wantVal := true
+
+			for {
+
+				var k string
+
+				var tmpJLabels string
+
+				tok = fs.Scan()
+				if tok == fflib.FFTok_error {
+					goto tokerror
+				}
+				if tok == fflib.FFTok_right_bracket {
+					break
+				}
+
+				if tok == fflib.FFTok_comma {
+					if wantVal == true {
+						// TODO(pquerna): this isn't an ideal error message, this handles
+						// things like [,,,] as an array value.
+						return fs.WrapErr(fmt.Errorf("wanted value token, but got token: %v", tok))
+					}
+					continue
+				} else {
+					wantVal = true
+				}
+
+				/* handler: k type=string kind=string quoted=false*/
+
+				{
+
+					{
+						if tok != fflib.FFTok_string && tok != fflib.FFTok_null {
+							return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for string", tok))
+						}
+					}
+
+					if tok == fflib.FFTok_null {
+
+					} else {
+
+						outBuf := fs.Output.Bytes()
+
+						k = string(string(outBuf))
+
+					}
+				}
+
+				// Expect ':' after key
+				tok = fs.Scan()
+				if tok != fflib.FFTok_colon {
+					return fs.WrapErr(fmt.Errorf("wanted colon token, but got token: %v", tok))
+				}
+
+				tok = fs.Scan()
+				/* handler: tmpJLabels type=string kind=string quoted=false*/
+
+				{
+
+					{
+						if tok != fflib.FFTok_string && tok != fflib.FFTok_null {
+							return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for string", tok))
+						}
+					}
+
+					if tok == fflib.FFTok_null {
+
+					} else {
+
+						outBuf := fs.Output.Bytes()
+
+						tmpJLabels = string(string(outBuf))
+
+					}
+				}
+
+				j.Labels[k] = tmpJLabels
+
+				wantVal = false
+			}
+
+		}
+	}
+
+	state = fflib.FFParse_after_value
+	goto mainparse
+
 wantedvalue:
 	return fs.WrapErr(fmt.Errorf("wanted value token, but got token: %v", tok))
 wrongtokenerror:
@@ -0,0 +1,264 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/lodastack/registry/common"
+
+	m "github.com/lodastack/store/model"
+)
+
+const (
+	// nodeKeyPrefix is the key prefix of every node record in NodeDataBucketID.
+	nodeKeyPrefix = "n-"
+	// versionKey is changed on every save, used to tell whether the snapshot is stale.
+	versionKey = "version"
+)
+
+func getNodeKey(id string) []byte { return []byte(nodeKeyPrefix + id) }
+
+// record is how one node is saved, with the parent ID and the ordered children ID.
+type record struct {
+	Property NodeProperty `json:"property"`
+	Parent   string       `json:"parent"`
+	Children []string     `json:"children"`
+}
+
+// newRecord return the record of the node under the parent.
+func newRecord(n *Node, parent string) ([]byte, error) {
+	r := record{Property: n.NodeProperty, Parent: parent, Children: make([]string, len(n.Children))}
+	for i, child := range n.Children {
+		r.Children[i] = child.ID
+	}
+	return json.Marshal(r)
+}
+
+// build return the tree by the key-record map read from store.
+func build(recordMap map[string][]byte) (*Node, error) {
+	records := make(map[string]record, len(recordMap))
+	var rootID string
+	for key, v := range recordMap {
+		if len(v) == 0 {
+			continue
+		}
+		r := record{}
+		if err := json.Unmarshal(v, &r); err != nil {
+			return nil, fmt.Errorf("unmarshal node %s fail: %v", key, err)
+		}
+		records[r.Property.ID] = r
+		if r.Parent == "" {
+			rootID = r.Property.ID
+		}
+	}
+	if rootID == "" {
+		return nil, common.ErrGetNode
+	}
+
+	var newNode func(id string) *Node
+	newNode = func(id string) *Node {
+		r := records[id]
+		n := &Node{NodeProperty: r.Property, Children: make([]*Node, 0, len(r.Children))}
+		for _, childID := range r.Children {
+			if _, ok := records[childID]; !ok {
+				continue
+			}
+			n.Children = append(n.Children, newNode(childID))
+		}
+		return n
+	}
+	return newNode(rootID), nil
+}
+
+// index is the tree with the ns and ID index of every node, rebuilt on tree change.
+// The nodes in the index are shared by readers and must not be modified.
+type index struct {
+	tree *Node
+	// nodeID-ns map.
+	ids map[string]string
+	// ns-node map.
+	nss map[string]*Node
+	// nodeID-parentID map.
+	parents map[string]string
+}
+
+func newIndex(tree *Node) *index {
+	idx := &index{tree: tree, ids: map[string]string{}, nss: map[string]*Node{}, parents: map[string]string{}}
+	var walk func(n *Node, ns, parent string)
+	walk = func(n *Node, ns, parent string) {
+		idx.ids[n.ID], idx.nss[ns], idx.parents[n.ID] = ns, n, parent
+		for _, child := range n.Children {
+			walk(child, Join([]string{child.Name, ns}), n.ID)
+		}
+	}
+	walk(tree, RootNode, "")
+	return idx
+}
+
+// same return whether the node under the parent has the same record as in the index.
+func (idx *index) same(n *Node, parent string) bool {
+	if idx == nil {
+		return false
+	}
+	ns, ok := idx.ids[n.ID]
+	if !ok || idx.parents[n.ID] != parent {
+		return false
+	}
+	old := idx.nss[ns]
+	if len(old.Children) != len(n.Children) || !reflect.DeepEqual(old.NodeProperty, n.NodeProperty) {
+		return false
+	}
+	for i, child := range n.Children {
+		if old.Children[i].ID != child.ID {
+			return false
+		}
+	}
+	return true
+}
+
+// snapshot is the tree last read or saved, it is never modified,
+// every change is saved to store and replace the snapshot.
+// Only one snapshot is kept, so the memory used is bounded by the tree size.
+type snapshot struct {
+	sync.RWMutex
+	index   *index
+	version string
+}
+
+func (s *snapshot) get(version string) (*index, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	if s.index == nil || s.version != version {
+		return nil, false
+	}
+	return s.index, true
+}
+
+func (s *snapshot) set(tree *Node, version string) *index {
+	idx := newIndex(tree)
+	s.Lock()
+	defer s.Unlock()
+	s.index, s.version = idx, version
+	return idx
+}
+
+// diff return the rows which save the nodes of the tree changed from the snapshot,
+// only the changed nodes are marshaled, the record of removed node is cleared by an empty value.
+func (s *snapshot) diff(tree *Node) ([]m.Row, error) {
+	s.RLock()
+	defer s.RUnlock()
+	rows := []m.Row{}
+	seen := map[string]bool{}
+	var walk func(n *Node, parent string) error
+	walk = func(n *Node, parent string) error {
+		seen[n.ID] = true
+		if !s.index.same(n, parent) {
+			b, err := newRecord(n, parent)
+			if err != nil {
+				return err
+			}
+			rows = append(rows, m.Row{Bucket: []byte(NodeDataBucketID), Key: getNodeKey(n.ID), Value: b})
+		}
+		for _, child := range n.Children {
+			if err := walk(child, n.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if tree != nil {
+		if err := walk(tree, ""); err != nil {
+			return nil, err
+		}
+	}
+	if s.index != nil {
+		for id := range s.index.ids {
+			if !seen[id] {
+				rows = append(rows, m.Row{Bucket: []byte(NodeDataBucketID), Key: getNodeKey(id), Value: []byte{}})
+			}
+		}
+	}
+	return rows, nil
+}
+
+// current return the index of the tree, reload it from store if changed by others.
+func (n *node) current() (*index, error) {
+	version, err := n.cluster.View([]byte(NodeDataBucketID), []byte(versionKey))
+	if err != nil || len(version) == 0 {
+		return nil, common.ErrGetNode
+	}
+	if idx, ok := n.snapshot.get(string(version)); ok {
+		return idx, nil
+	}
+
+	recordMap, err := n.cluster.ViewPrefix([]byte(NodeDataBucketID), []byte(nodeKeyPrefix))
+	if err != nil {
+		return nil, err
+	}
+	tree, err := build(recordMap)
+	if err != nil {
+		return nil, err
+	}
+	return n.snapshot.set(tree, string(version)), nil
+}
+
+// Init migrate the tree saved as one blob at NodeDataKey to node records,
+// return whether the tree is inited.
+func (n *node) Init() (bool, error) {
+	version, err := n.cluster.View([]byte(NodeDataBucketID), []byte(versionKey))
+	if err != nil {
+		return false, err
+	}
+	if len(version) != 0 {
+		return true, nil
+	}
+	blob, err := n.cluster.View([]byte(NodeDataBucketID), []byte(NodeDataKey))
+	if err != nil || len(blob) == 0 {
+		return false, err
+	}
+
+	tree := &Node{}
+	if err := tree.UnmarshalJSON(blob); err != nil {
+		return false, fmt.Errorf("unmarshal node fail: %v", err)
+	}
+	rows, newVersion, err := n.Rows(tree)
+	if err != nil {
+		return false, err
+	}
+	rows = append(rows, m.Row{Bucket: []byte(NodeDataBucketID), Key: []byte(NodeDataKey), Value: []byte{}})
+	if err := n.cluster.Batch(rows); err != nil {
+		return false, err
+	}
+	return true, n.Commit(tree, newVersion)
+}
+
+// Rows return the rows which save the nodes changed from the snapshot and the new version.
+func (n *node) Rows(nodes *Node) ([]m.Row, string, error) {
+	rows, err := n.snapshot.diff(nodes)
+	if err != nil {
+		return nil, "", err
+	}
+	version := common.GenUUID()
+	rows = append(rows, m.Row{Bucket: []byte(NodeDataBucketID), Key: []byte(versionKey), Value: []byte(version)})
+	return rows, version, nil
+}
+
+// Commit use the nodes as the snapshot after saved.
+func (n *node) Commit(nodes *Node, version string) error {
+	n.snapshot.set((&Node{}).Copy(nodes), version)
+	return nil
+}
+
+// Save the nodes changed from the snapshot.
+func (n *node) Save(nodes *Node) error {
+	rows, version, err := n.Rows(nodes)
+	if err != nil {
+		return err
+	}
+	if err := n.cluster.Batch(rows); err != nil {
+		return err
+	}
+	return n.Commit(nodes, version)
+}
@@ -0,0 +1,154 @@
+package node
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lodastack/registry/tree/test_sample"
+)
+
+func TestDiffBuild(t *testing.T) {
+	rows, err := (&snapshot{}).diff(&nodes)
+	if err != nil {
+		t.Fatalf("diff fail: %s", err.Error())
+	}
+	recordMap := make(map[string][]byte, len(rows))
+	for _, row := range rows {
+		recordMap[string(row.Key)] = row.Value
+	}
+	if len(recordMap) != len(nodeNsMap)+1 {
+		t.Fatalf("every node should be saved to empty snapshot, got %d", len(recordMap))
+	}
+	tree, err := build(recordMap)
+	if err != nil {
+		t.Fatalf("build fail: %s", err.Error())
+	}
+	origin, _ := nodes.MarshalJSON()
+	rebuilt, _ := tree.MarshalJSON()
+	if string(origin) != string(rebuilt) {
+		t.Fatalf("tree not match after diff and build:\n%s\n%s", origin, rebuilt)
+	}
+}
+
+func TestSnapshotDiff(t *testing.T) {
+	tree := (&Node{}).Copy(&nodes)
+	s := snapshot{}
+	s.set(tree, "1")
+	if rows, err := s.diff((&Node{}).Copy(tree)); err != nil || len(rows) != 0 {
+		t.Fatalf("diff of same tree should be empty, not %d rows, error: %v", len(rows), err)
+	}
+
+	changed := (&Node{}).Copy(tree)
+	removed := changed.Children[len(changed.Children)-1]
+	changed.Children = changed.Children[:len(changed.Children)-1]
+	changed.MachineReg = "changed"
+	removedNum := len(newIndex(removed).ids)
+
+	rows, err := s.diff(changed)
+	if err != nil {
+		t.Fatalf("diff fail: %s", err.Error())
+	}
+	cleared, updated := 0, 0
+	for _, row := range rows {
+		if len(row.Value) == 0 {
+			cleared++
+		} else if string(row.Key) == string(getNodeKey(changed.ID)) {
+			updated++
+		}
+	}
+	if updated != 1 {
+		t.Fatalf("root record should be updated, rows: %d", len(rows))
+	}
+	if cleared != removedNum {
+		t.Fatalf("removed node %s should be cleared, want %d, got %d", removed.ID, removedNum, cleared)
+	}
+	if len(rows) != cleared+updated {
+		t.Fatalf("unexpected rows in diff: %d", len(rows))
+	}
+
+	// the node moved to another parent is saved with the new parent.
+	moved := (&Node{}).Copy(tree)
+	child := moved.Children[1].Children[0]
+	moved.Children[1].Children = moved.Children[1].Children[1:]
+	moved.Children[2].Children = append(moved.Children[2].Children, child)
+	if rows, err = s.diff(moved); err != nil || len(rows) != 3 {
+		t.Fatalf("moved node and both parents should be saved, got %d rows, error: %v", len(rows), err)
+	}
+}
+
+func TestInit(t *testing.T) {
+	s := test_sample.MustNewStore(t)
+	defer os.RemoveAll(s.Path())
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	if err := s.CreateBucketIfNotExist([]byte(NodeDataBucketID)); err != nil {
+		t.Fatalf("create bucket fail: %s", err.Error())
+	}
+
+	n := NewNode(s)
+	if inited, err := n.Init(); err != nil || inited {
+		t.Fatalf("empty store should not be inited: %v %v", inited, err)
+	}
+
+	// the tree saved as one blob is migrated to node records.
+	blob, _ := getNodesByte()
+	if err := s.Update([]byte(NodeDataBucketID), []byte(NodeDataKey), blob); err != nil {
+		t.Fatalf("save blob fail: %s", err.Error())
+	}
+	if inited, err := n.Init(); err != nil || !inited {
+		t.Fatalf("blob should be migrated: %v %v", inited, err)
+	}
+	if v, err := s.View([]byte(NodeDataBucketID), []byte(NodeDataKey)); err != nil || len(v) != 0 {
+		t.Fatalf("blob should be removed after migrated: %s %v", v, err)
+	}
+	records, err := s.ViewPrefix([]byte(NodeDataBucketID), []byte(nodeKeyPrefix))
+	if err != nil || len(records) != len(nodeNsMap)+1 {
+		t.Fatalf("every node should be saved as record, got %d, error: %v", len(records), err)
+	}
+
+	// read by another node from the records.
+	all, err := NewNode(s).AllNodes()
+	if err != nil {
+		t.Fatalf("read the migrated tree fail: %s", err.Error())
+	}
+	if migrated, _ := all.MarshalJSON(); string(migrated) != string(blob) {
+		t.Fatalf("migrated tree not match:\n%s\n%s", blob, migrated)
+	}
+	if inited, err := n.Init(); err != nil || !inited {
+		t.Fatalf("migrated tree should be inited: %v %v", inited, err)
+	}
+
+	// only the changed node is saved.
+	all.Children[0].Comment = "changed"
+	rows, _, err := n.Rows(all)
+	if err != nil || len(rows) != 2 || string(rows[0].Key) != string(getNodeKey(all.Children[0].ID)) {
+		t.Fatalf("only the changed node and version should be saved, got %d rows, error: %v", len(rows), err)
+	}
+}
+
+func TestIndex(t *testing.T) {
+	idx := newIndex(&nodes)
+	if len(idx.nss) != len(nodeNsMap)+1 || len(idx.ids) != len(idx.nss) {
+		t.Fatalf("index size not match, ns: %d, id: %d, expect: %d", len(idx.nss), len(idx.ids), len(nodeNsMap)+1)
+	}
+	for ns := range nodeNsMap {
+		n, ok := idx.nss[ns]
+		if !ok {
+			t.Fatalf("ns %s not in index", ns)
+		}
+		expect, err := nodes.GetByNS(ns)
+		if err != nil || expect != n {
+			t.Fatalf("node of ns %s not match: %+v, %+v", ns, n, expect)
+		}
+		if idx.ids[n.ID] != ns {
+			t.Fatalf("ns of ID %s not match: %s, expect %s", n.ID, idx.ids[n.ID], ns)
+		}
+	}
+	if n, ok := idx.nss[RootNode]; !ok || n != &nodes {
+		t.Fatalf("root not in index")
+	}
+}
@@ -0,0 +1,93 @@
+package node
+
+// Count is the number of leaves and machines under a node.
+type Count struct {
+	Leaves   int `json:"leaves"`
+	Machines int `json:"machines"`
+}
+
+// View is the node with its children to a limited depth, used to render the tree lazily.
+type View struct {
+	NodeProperty
+	Count    *Count  `json:"count,omitempty"`
+	Children []*View `json:"children"`
+}
+
+// Row is one node of the flattened tree.
+type Row struct {
+	NS         string `json:"ns"`
+	ID         string `json:"id"`
+	Type       int    `json:"type"`
+	Parent     string `json:"parent"`
+	Comment    string `json:"comment"`
+	MachineReg string `json:"machinereg"`
+	Count      *Count `json:"count,omitempty"`
+}
+
+// Counts return the nodeID-Count map of the node and all its children,
+// machines is the leafID-machine number map.
+func (n *Node) Counts(machines map[string]int) map[string]Count {
+	counts := map[string]Count{}
+	var count func(n *Node) Count
+	count = func(n *Node) Count {
+		c := Count{}
+		if n.IsLeaf() {
+			c.Machines = machines[n.ID]
+		}
+		for _, child := range n.Children {
+			childCount := count(child)
+			if child.IsLeaf() {
+				c.Leaves++
+			}
+			c.Leaves += childCount.Leaves
+			c.Machines += childCount.Machines
+		}
+		counts[n.ID] = c
+		return c
+	}
+	count(n)
+	return counts
+}
+
+// NewView return the view of the node with depth levels of children, all levels if depth < 0.
+// The count of each node is set if counts is not nil.
+func NewView(n *Node, depth int, counts map[string]Count) *View {
+	v := &View{NodeProperty: n.NodeProperty, Children: []*View{}}
+	v.Labels = CopyLabels(n.Labels)
+	if c, ok := counts[n.ID]; ok {
+		v.Count = &c
+	}
+	if depth == 0 {
+		return v
+	}
+	for _, child := range n.Children {
+		v.Children = append(v.Children, NewView(child, depth-1, counts))
+	}
+	return v
+}
+
+// Table flatten the view of the ns to rows, the parent is before its children.
+func (v *View) Table(ns string) []Row {
+	rows := []Row{}
+	var flat func(v *View, ns, parent string)
+	flat = func(v *View, ns, parent string) {
+		rows = append(rows, Row{
+			NS:         ns,
+			ID:         v.ID,
+			Type:       v.Type,
+			Parent:     parent,
+			Comment:    v.Comment,
+			MachineReg: v.MachineReg,
+			Count:      v.Count,
+		})
+		for _, child := range v.Children {
+			flat(child, Join([]string{child.Name, ns}), ns)
+		}
+	}
+	parent := ""
+	if elems := Split(ns); len(elems) > 1 {
+		parent = Join(elems[1:])
+	}
+	flat(v, ns, parent)
+	return rows
+}
@@ -0,0 +1,63 @@
+package node
+
+import (
+	"testing"
+)
+
+func TestNodeCounts(t *testing.T) {
+	counts := nodes.Counts(map[string]int{"0-2-1": 1, "0-2-2-1": 2, "0-4": 3})
+	if len(counts) != len(nodeNsMap)+1 {
+		t.Fatalf("counts size not match: %d", len(counts))
+	}
+	for id, expect := range map[string]Count{
+		"loda":    {Leaves: 4, Machines: 6},
+		"0-2":     {Leaves: 2, Machines: 3},
+		"0-2-2":   {Leaves: 1, Machines: 2},
+		"0-3":     {Leaves: 1, Machines: 0},
+		"0-4":     {Leaves: 0, Machines: 3},
+		"0-2-2-2": {Leaves: 0, Machines: 0},
+	} {
+		if counts[id] != expect {
+			t.Fatalf("count of %s not match: %+v, expect %+v", id, counts[id], expect)
+		}
+	}
+}
+
+func TestViewDepth(t *testing.T) {
+	v := NewView(&nodes, 1, nil)
+	if len(v.Children) != len(nodes.Children) || v.Count != nil {
+		t.Fatalf("view of depth 1 not match: %+v", v)
+	}
+	for _, child := range v.Children {
+		if len(child.Children) != 0 {
+			t.Fatalf("child %s of view of depth 1 should have no children", child.ID)
+		}
+	}
+	if v := NewView(&nodes, 0, nil); len(v.Children) != 0 {
+		t.Fatalf("view of depth 0 should have no children")
+	}
+
+	all := NewView(&nodes, -1, nodes.Counts(nil))
+	if rows := all.Table(RootNode); len(rows) != len(nodeNsMap)+1 {
+		t.Fatalf("table size not match: %d", len(rows))
+	}
+}
+
+func TestViewTable(t *testing.T) {
+	n, err := nodes.GetByNS("0-2." + RootNode)
+	if err != nil {
+		t.Fatalf("get node fail: %s", err.Error())
+	}
+	rows := NewView(n, 1, n.Counts(nil)).Table("0-2." + RootNode)
+	if len(rows) != 3 {
+		t.Fatalf("table size not match: %+v", rows)
+	}
+	if rows[0].NS != "0-2."+RootNode || rows[0].Parent != RootNode || rows[0].Count == nil || rows[0].Count.Leaves != 2 {
+		t.Fatalf("first row not match: %+v", rows[0])
+	}
+	for _, row := range rows[1:] {
+		if row.Parent != "0-2."+RootNode || row.NS != row.ID+".0-2."+RootNode {
+			t.Fatalf("child row not match: %+v", row)
+		}
+	}
+}
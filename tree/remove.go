@@ -0,0 +1,204 @@
+package tree
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/lodastack/registry/common"
+	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/tree/node"
+
+	m "github.com/lodastack/store/model"
+)
+
+// removeNodePlan walk the node n and its children, return the remove plan of every node.
+func (t *Tree) removeNodePlan(n *node.Node, ns string) ([]model.RemoveNodePlan, error) {
+	nodePlan := model.RemoveNodePlan{NS: ns}
+	if n.IsLeaf() {
+		machines, err := t.getResourceListByNodeID(n.ID, model.Machine)
+		if err != nil {
+			return nil, err
+		}
+		for _, machine := range machines {
+			hostname, _ := machine.ReadProperty(model.HostnameProp)
+			nodePlan.Machines = append(nodePlan.Machines, hostname)
+		}
+		alarms, err := t.getResourceListByNodeID(n.ID, model.Alarm)
+		if err != nil {
+			return nil, err
+		}
+		for _, alarm := range alarms {
+			name, _ := alarm.ReadProperty(model.PkProperty[model.Alarm])
+			nodePlan.Alarms = append(nodePlan.Alarms, name)
+		}
+	}
+	dashboardByte, err := t.getByteFromStore(n.ID, dashboardType)
+	if err != nil {
+		return nil, err
+	}
+	if len(dashboardByte) != 0 {
+		var dashboards model.DashboardData
+		if err := json.Unmarshal(dashboardByte, &dashboards); err != nil {
+			return nil, err
+		}
+		for _, dashboard := range dashboards {
+			nodePlan.Dashboards = append(nodePlan.Dashboards, dashboard.Title)
+		}
+	}
+
+	plans := []model.RemoveNodePlan{nodePlan}
+	for _, child := range n.Children {
+		childPlans, err := t.removeNodePlan(child, node.Join([]string{child.Name, ns}))
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, childPlans...)
+	}
+	return plans, nil
+}
+
+func allowRemoveRecursive(ns string) error {
+	if ns == node.RootNode {
+		return common.ErrNotAllowDel
+	}
+	for _, meta := range node.InitNodes {
+		if inSubtree(meta.Name, ns) {
+			return common.ErrNotAllowDel
+		}
+	}
+	return nil
+}
+
+// RemovePreview return the plan of removing the ns and all its children,
+// the groups and token of the plan is not set.
+func (t *Tree) RemovePreview(ns string, moveToPool bool) (model.RemovePlan, error) {
+	plan := model.RemovePlan{NS: ns, MoveToPool: moveToPool, Groups: []string{}}
+	if err := allowRemoveRecursive(ns); err != nil {
+		return plan, err
+	}
+	n, err := t.GetNodeByNS(ns)
+	if err != nil {
+		return plan, err
+	}
+	plan.Nodes, err = t.removeNodePlan(n, ns)
+	return plan, err
+}
+
+// poolMachineRows return the rows which move the machines of the removed nodes to the pool node.
+func (t *Tree) poolMachineRows(allNodes *node.Node, removed *node.Node) ([]m.Row, error) {
+	pool, err := allNodes.GetByNS(node.JoinWithRoot([]string{node.PoolNode}))
+	if err != nil {
+		return nil, err
+	}
+	poolMachines, err := t.getResourceListByNodeID(pool.ID, model.Machine)
+	if err != nil {
+		return nil, err
+	}
+	exist := map[string]bool{}
+	for _, machine := range poolMachines {
+		hostname, _ := machine.ReadProperty(model.HostnameProp)
+		exist[hostname] = true
+	}
+
+	leafIDs := []string{removed.ID}
+	if !removed.IsLeaf() {
+		if leafIDs, err = removed.LeafChildIDs(); err != nil && err != common.ErrNoLeafChild {
+			return nil, err
+		}
+	}
+	rows := []m.Row{}
+	for _, leafID := range leafIDs {
+		machines, err := t.getResourceListByNodeID(leafID, model.Machine)
+		if err != nil {
+			return nil, err
+		}
+		if len(machines) == 0 {
+			continue
+		}
+		// the machines are moved out of the trashed leaf, not restored with it.
+		rows = append(rows, m.Row{Bucket: []byte(leafID), Key: []byte(model.Machine), Value: []byte{}})
+		for _, machine := range machines {
+			hostname, _ := machine.ReadProperty(model.HostnameProp)
+			if exist[hostname] {
+				continue
+			}
+			exist[hostname] = true
+			poolMachines = append(poolMachines, machine)
+		}
+	}
+
+	machineByte := []byte{}
+	if len(poolMachines) != 0 {
+		if machineByte, err = poolMachines.Marshal(); err != nil {
+			return nil, err
+		}
+	}
+	return append(rows, m.Row{Bucket: []byte(pool.ID), Key: []byte(model.Machine), Value: machineByte}), nil
+}
+
+// RemoveNodeRecursive move the ns and all its children to trash, the machines are moved
+// to the pool node if plan.MoveToPool. The plan is generated again and checked with
+// the plan token, to make sure nothing changed after the preview.
+// groups is the removed groups of the nodes. The tree, the trash, the pool machines and
+// extraRows (e.g. the rows remove the groups) are written in one batch,
+// the buckets of the removed nodes are dropped when the trash purged.
+func (t *Tree) RemoveNodeRecursive(plan model.RemovePlan, operator string, retention time.Duration, groups []byte, extraRows ...m.Row) (model.Trash, error) {
+	trash := model.Trash{}
+	parentNs, err := getParentNS(plan.NS)
+	if err != nil {
+		return trash, err
+	}
+
+	t.Mu.Lock()
+	defer t.Mu.Unlock()
+	current, err := t.RemovePreview(plan.NS, plan.MoveToPool)
+	if err != nil {
+		return trash, err
+	}
+	current.Groups = plan.Groups
+	if plan.Token == "" || current.GenToken() != plan.Token {
+		return trash, common.ErrTokenMismatch
+	}
+
+	allNodes, err := t.AllNodes()
+	if err != nil {
+		t.logger.Error("get all nodes error when RemoveNodeRecursive")
+		return trash, err
+	}
+	removed, err := allNodes.GetByNS(plan.NS)
+	if err != nil {
+		return trash, err
+	}
+	parent, err := allNodes.GetByNS(parentNs)
+	if err != nil {
+		return trash, err
+	}
+
+	rows := []m.Row{}
+	if plan.MoveToPool {
+		poolRows, err := t.poolMachineRows(allNodes, removed)
+		if err != nil {
+			t.logger.Errorf("move machine of ns %s to pool fail: %s", plan.NS, err.Error())
+			return trash, err
+		}
+		rows = append(rows, poolRows...)
+	}
+	for i, child := range parent.Children {
+		if child.ID == removed.ID {
+			parent.Children = append(parent.Children[:i], parent.Children[i+1:]...)
+			break
+		}
+	}
+	trash, row, err := trashRow(removed, plan.NS, operator, retention, groups)
+	if err != nil {
+		return trash, err
+	}
+	rows = append(rows, row)
+	rows = append(rows, extraRows...)
+	if err := t.batchWithTree(allNodes, rows); err != nil {
+		t.logger.Errorf("remove ns %s recursive fail: %s", plan.NS, err.Error())
+		return trash, err
+	}
+	t.logger.Infof("move ns %s with %d nodes to trash %s success", plan.NS, len(trash.NodeIDs), trash.ID)
+	return trash, nil
+}
@@ -0,0 +1,110 @@
+package tree
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lodastack/registry/common"
+	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/tree/node"
+	"github.com/lodastack/registry/tree/test_sample"
+)
+
+func TestRemoveNodeRecursive(t *testing.T) {
+	s := test_sample.MustNewStore(t)
+	defer os.RemoveAll(s.Path())
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	tree, err := NewTree(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	productNs := "product." + node.RootNode
+	if _, err := tree.NewNode("product", "", node.RootNode, node.NonLeaf); err != nil {
+		t.Fatalf("create nonleaf fail: %s", err.Error())
+	}
+	for _, name := range []string{"web", "api"} {
+		if _, err := tree.NewNode(name, "", productNs, node.Leaf); err != nil {
+			t.Fatalf("create leaf fail: %s", err.Error())
+		}
+	}
+	if err := tree.SetResource("web."+productNs, model.Machine, model.ResourceList{
+		{"hostname": "h1", "ip": "10.0.0.1"},
+	}); err != nil {
+		t.Fatalf("set machine fail: %s", err.Error())
+	}
+
+	// case 1: not allow remove the init node.
+	if _, err := tree.RemovePreview("pool."+node.RootNode, false); err != common.ErrNotAllowDel {
+		t.Fatalf("preview remove pool not match with expect: %v", err)
+	}
+
+	// case 2: remove with a stale token.
+	plan, err := tree.RemovePreview(productNs, true)
+	if err != nil || len(plan.Nodes) != 3 || len(plan.Nodes[1].Machines) != 1 || len(plan.Nodes[1].Alarms) == 0 {
+		t.Fatalf("preview not match with expect: %+v, %v", plan, err)
+	}
+	plan.Token = plan.GenToken()
+	if err := tree.SetResource("api."+productNs, model.Machine, model.ResourceList{
+		{"hostname": "h2", "ip": "10.0.0.2"},
+	}); err != nil {
+		t.Fatalf("set machine fail: %s", err.Error())
+	}
+	if _, err := tree.RemoveNodeRecursive(plan, "user1", time.Hour, nil); err != common.ErrTokenMismatch {
+		t.Fatalf("remove with stale token not match with expect: %v", err)
+	}
+
+	// case 3: remove to trash and move the machines to pool.
+	webID, _ := tree.getNodeIDByNS("web." + productNs)
+	plan, _ = tree.RemovePreview(productNs, true)
+	plan.Token = plan.GenToken()
+	trash, err := tree.RemoveNodeRecursive(plan, "user1", time.Hour, nil)
+	if err != nil {
+		t.Fatalf("remove recursive fail: %v", err)
+	}
+	if _, err := tree.GetNodeByNS(productNs); err == nil {
+		t.Fatal("ns still exist after remove")
+	}
+	machines, err := tree.GetResourceList("pool."+node.RootNode, model.Machine)
+	if err != nil || len(*machines) != 2 {
+		t.Fatalf("machine not moved to pool: %+v, %v", machines, err)
+	}
+	if trash.NS != productNs || len(trash.NodeIDs) != 3 {
+		t.Fatalf("trash not match with expect: %+v", trash)
+	}
+	if v, err := tree.getByteFromStore(webID, model.Machine); err != nil || len(v) != 0 {
+		t.Fatalf("machine moved to pool should be cleared from the leaf: %s, %v", v, err)
+	}
+
+	// case 4: restore from trash, the machines stay in pool.
+	if ns, err := tree.RestoreTrash(trash.ID); err != nil || ns != productNs {
+		t.Fatalf("restore trash fail: %s, %v", ns, err)
+	}
+	if machines, err := tree.GetResourceList("web."+productNs, model.Machine); err != nil || len(*machines) != 0 {
+		t.Fatalf("machine of restored leaf not match with expect: %+v, %v", machines, err)
+	}
+	if alarms, err := tree.GetResourceList("web."+productNs, model.Alarm); err != nil || len(*alarms) == 0 {
+		t.Fatalf("alarm of restored leaf not match with expect: %+v, %v", alarms, err)
+	}
+
+	// case 5: the buckets are cleared when the trash purged.
+	plan, _ = tree.RemovePreview(productNs, false)
+	plan.Token = plan.GenToken()
+	if trash, err = tree.RemoveNodeRecursive(plan, "user1", time.Hour, nil); err != nil {
+		t.Fatalf("remove recursive fail: %v", err)
+	}
+	if v, err := tree.getByteFromStore(webID, model.Alarm); err != nil || len(v) == 0 {
+		t.Fatalf("bucket should be kept until purged: %v", err)
+	}
+	if err := tree.PurgeTrash(trash.ID); err != nil {
+		t.Fatalf("purge trash fail: %v", err)
+	}
+	if v, _ := tree.getByteFromStore(webID, model.Alarm); len(v) != 0 {
+		t.Fatal("bucket should be cleared after purged")
+	}
+}
@@ -5,8 +5,13 @@ import (
 )
 
 // SetResource set the resource list to the ns.
+// Template of NonLeaf ns is propagated to its descendants after changed.
 func (t *Tree) SetResource(ns, resType string, l model.ResourceList) error {
-	return t.resource.SetResource(ns, resType, l)
+	if err := t.resource.SetResource(ns, resType, l); err != nil {
+		return err
+	}
+	t.propagateIfTemplate(ns, resType)
+	return nil
 }
 
 // GetResource return the one resource of the ns.
@@ -21,17 +26,30 @@ func (t *Tree) GetResourceList(ns, resourceType string) (*model.ResourceList, er
 
 // UpdateResource update one resource by updateMap.
 func (t *Tree) UpdateResource(ns, resType, resID string, updateMap map[string]string) error {
-	return t.resource.UpdateResource(ns, resType, resID, updateMap)
+	if err := t.resource.UpdateResource(ns, resType, resID, updateMap); err != nil {
+		return err
+	}
+	t.propagateIfTemplate(ns, resType)
+	return nil
 }
 
 // AppendResource append resources to a ns.
 func (t *Tree) AppendResource(ns, resType string, appendRes ...model.Resource) error {
-	return t.resource.AppendResource(ns, resType, appendRes...)
+	if err := t.resource.AppendResource(ns, resType, appendRes...); err != nil {
+		return err
+	}
+	t.propagateIfTemplate(ns, resType)
+	return nil
 }
 
 // MoveResource move one resource fo an other ns, the resouce will be removed from the old ns.
 func (t *Tree) MoveResource(oldNs, newNs, resType string, resourceIDs ...string) error {
-	return t.resource.MoveResource(oldNs, newNs, resType, resourceIDs...)
+	if err := t.resource.MoveResource(oldNs, newNs, resType, resourceIDs...); err != nil {
+		return err
+	}
+	t.propagateIfTemplate(oldNs, resType)
+	t.propagateIfTemplate(newNs, resType)
+	return nil
 }
 
 // SearchResource search any preperty resource in the ns and its child ns.
@@ -41,10 +59,18 @@ func (t *Tree) SearchResource(ns, resType string, search model.ResourceSearch) (
 
 // CopyResource copy one resource from one ns to the other ns, the resource will still exist in the old ns.
 func (t *Tree) CopyResource(fromNs, toNs, resType string, resourceIDs ...string) error {
-	return t.resource.CopyResource(fromNs, toNs, resType, resourceIDs...)
+	if err := t.resource.CopyResource(fromNs, toNs, resType, resourceIDs...); err != nil {
+		return err
+	}
+	t.propagateIfTemplate(toNs, resType)
+	return nil
 }
 
 // RemoveResource remove one resource from a node.
 func (t *Tree) RemoveResource(ns, resourceType string, resID ...string) error {
-	return t.resource.RemoveResource(ns, resourceType, resID...)
+	if err := t.resource.RemoveResource(ns, resourceType, resID...); err != nil {
+		return err
+	}
+	t.propagateIfTemplate(ns, resourceType)
+	return nil
 }
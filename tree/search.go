@@ -0,0 +1,65 @@
+package tree
+
+import (
+	"sort"
+
+	"github.com/lodastack/registry/common"
+	"github.com/lodastack/registry/model"
+)
+
+// searchTypes return the resource types a global search look up, all types if not set.
+func searchTypes(types []string) ([]string, error) {
+	if len(types) == 0 {
+		for resType := range model.PkProperty {
+			types = append(types, resType)
+		}
+		sort.Strings(types)
+		return types, nil
+	}
+	for _, resType := range types {
+		if _, ok := model.PkProperty[resType]; !ok {
+			return nil, common.ErrInvalidParam
+		}
+	}
+	return types, nil
+}
+
+// GlobalSearch search the resources of the types in all leaves under the ns,
+// return the hits grouped by type and ns, ranked by match quality.
+// The ns and type not allowed by the search are skipped before the limit applied.
+func (t *Tree) GlobalSearch(ns string, search model.GlobalSearch, types ...string) (model.GlobalSearchResult, error) {
+	if search.Query == "" {
+		return model.GlobalSearchResult{}, common.ErrInvalidParam
+	}
+	types, err := searchTypes(types)
+	if err != nil {
+		return model.GlobalSearchResult{}, err
+	}
+	leafIDs, err := t.LeafChildIDs(ns)
+	if err != nil {
+		if err == common.ErrNoLeafChild {
+			return model.NewSearchCollector(search).Result(), nil
+		}
+		return model.GlobalSearchResult{}, err
+	}
+
+	collector := model.NewSearchCollector(search)
+	for _, leafID := range leafIDs {
+		leafNs, err := t.getNodeNSByID(leafID)
+		if err != nil {
+			return model.GlobalSearchResult{}, err
+		}
+		for _, resType := range types {
+			if !search.Allowed(leafNs, resType) {
+				continue
+			}
+			rl, err := t.getResourceListByNodeID(leafID, resType)
+			if err != nil {
+				t.logger.Errorf("global search read ns %s type %s fail: %s", leafNs, resType, err.Error())
+				return model.GlobalSearchResult{}, err
+			}
+			collector.Collect(leafNs, resType, rl)
+		}
+	}
+	return collector.Result(), nil
+}
@@ -0,0 +1,66 @@
+package tree
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/tree/node"
+	"github.com/lodastack/registry/tree/test_sample"
+)
+
+func TestGlobalSearch(t *testing.T) {
+	s := test_sample.MustNewStore(t)
+	defer os.RemoveAll(s.Path())
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	tree, err := NewTree(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leafNs := "web." + node.RootNode
+	if _, err := tree.NewNode("web", "", node.RootNode, node.Leaf); err != nil {
+		t.Fatalf("create leaf fail: %s", err.Error())
+	}
+	if err := tree.SetResource(leafNs, model.Machine, model.ResourceList{
+		{"hostname": "web-1", "ip": "10.8.0.1"},
+	}); err != nil {
+		t.Fatalf("set machine fail: %s", err.Error())
+	}
+	if err := tree.SetResource(leafNs, model.Deploy, model.ResourceList{
+		{"name": "web-deploy", "hosts": "10.8.0.1"},
+	}); err != nil {
+		t.Fatalf("set deploy fail: %s", err.Error())
+	}
+
+	result, err := tree.GlobalSearch(node.RootNode, model.GlobalSearch{Query: "10.8.0.1"})
+	if err != nil || result.Total != 2 || len(result.Groups) != 2 {
+		t.Fatalf("global search not match with expect: %+v, %v", result, err)
+	}
+	for _, g := range result.Groups {
+		if g.NS != leafNs || g.Score != model.MatchExact {
+			t.Fatalf("search group not match with expect: %+v", g)
+		}
+	}
+
+	if result, err = tree.GlobalSearch(node.RootNode, model.GlobalSearch{Query: "10.8.0.1"}, model.Machine); err != nil ||
+		len(result.Groups) != 1 || result.Groups[0].Type != model.Machine {
+		t.Fatalf("global search by type not match with expect: %+v, %v", result, err)
+	}
+	// the type not allowed is skipped before the limit, the deploy hit ranks first otherwise.
+	search := model.GlobalSearch{Query: "10.8.0.1", Limit: 1, Allow: func(ns, resType string) bool {
+		return resType != model.Deploy
+	}}
+	if result, err = tree.GlobalSearch(node.RootNode, search); err != nil || result.Total != 1 || result.Truncated ||
+		len(result.Groups) != 1 || result.Groups[0].Type != model.Machine {
+		t.Fatalf("global search with filter not match with expect: %+v, %v", result, err)
+	}
+	if _, err = tree.GlobalSearch(node.RootNode, model.GlobalSearch{Query: "10.8.0.1"}, "unknown"); err == nil {
+		t.Fatal("global search unknown type should fail")
+	}
+}
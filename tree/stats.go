@@ -0,0 +1,152 @@
+package tree
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/tree/cluster"
+	"github.com/lodastack/registry/tree/node"
+
+	m "github.com/lodastack/store/model"
+)
+
+// statsTTL bound how long the stats may miss the change made by other registry.
+// The change made by this registry invalidate the stats at once.
+var statsTTL = time.Minute
+
+type statsEntry struct {
+	gen   uint64
+	at    time.Time
+	stats map[string]model.NsStats
+}
+
+// statsCache cache the stats of ns, the generation is increased by every write.
+type statsCache struct {
+	sync.Mutex
+	gen     uint64
+	entries map[string]statsEntry
+}
+
+func newStatsCache() *statsCache {
+	return &statsCache{entries: map[string]statsEntry{}}
+}
+
+func (c *statsCache) invalidate() {
+	c.Lock()
+	defer c.Unlock()
+	c.gen++
+	c.entries = map[string]statsEntry{}
+}
+
+func (c *statsCache) generation() uint64 {
+	c.Lock()
+	defer c.Unlock()
+	return c.gen
+}
+
+func (c *statsCache) get(ns string) (map[string]model.NsStats, bool) {
+	c.Lock()
+	defer c.Unlock()
+	e, ok := c.entries[ns]
+	if !ok || e.gen != c.gen || time.Since(e.at) > statsTTL {
+		return nil, false
+	}
+	return e.stats, true
+}
+
+// set cache the stats computed at generation gen, discarded if written since.
+func (c *statsCache) set(ns string, gen uint64, stats map[string]model.NsStats) {
+	c.Lock()
+	defer c.Unlock()
+	if gen != c.gen {
+		return
+	}
+	c.entries[ns] = statsEntry{gen: gen, at: time.Now(), stats: stats}
+}
+
+// watchedCluster invalidate the stats cache on every write except the agent report.
+type watchedCluster struct {
+	cluster.Inf
+	stats *statsCache
+}
+
+func (c *watchedCluster) RemoveBucket(name []byte) error {
+	defer c.stats.invalidate()
+	return c.Inf.RemoveBucket(name)
+}
+
+func (c *watchedCluster) Update(bucket []byte, key []byte, value []byte) error {
+	if string(bucket) != reportBucket {
+		defer c.stats.invalidate()
+	}
+	return c.Inf.Update(bucket, key, value)
+}
+
+func (c *watchedCluster) RemoveKey(bucket, key []byte) error {
+	defer c.stats.invalidate()
+	return c.Inf.RemoveKey(bucket, key)
+}
+
+func (c *watchedCluster) Batch(rows []m.Row) error {
+	defer c.stats.invalidate()
+	return c.Inf.Batch(rows)
+}
+
+// leafStats return the stats of the resources in the leaf.
+func (t *Tree) leafStats(nodeID string) (model.NsStats, error) {
+	stats := model.NewNsStats()
+	for _, resType := range model.StatsTypes {
+		rl, err := t.getResourceListByNodeID(nodeID, resType)
+		if err != nil {
+			return stats, err
+		}
+		stats.Count(resType, rl)
+	}
+	return stats, nil
+}
+
+// NsStats return the ns-stats map of the ns and all its children.
+// The stats of NonLeaf ns is the roll-up of its leaves.
+func (t *Tree) NsStats(ns string) (map[string]model.NsStats, error) {
+	if stats, ok := t.stats.get(ns); ok {
+		return stats, nil
+	}
+	gen := t.stats.generation()
+	n, err := t.GetNodeByNS(ns)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := map[string]model.NsStats{}
+	if _, err := n.Walk(func(n *node.Node, _ map[string]string) (map[string]string, error) {
+		if n.IsLeaf() {
+			stats, err := t.leafStats(n.ID)
+			if err != nil {
+				t.logger.Errorf("count resource of node %s fail: %s", n.ID, err.Error())
+				return nil, err
+			}
+			byID[n.ID] = stats
+			return nil, nil
+		}
+		stats := model.NewNsStats()
+		for _, child := range n.Children {
+			stats.Merge(byID[child.ID])
+		}
+		byID[n.ID] = stats
+		return nil, nil
+	}); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]model.NsStats, len(byID))
+	for id, stats := range byID {
+		childNs, err := t.getNodeNSByID(id)
+		if err != nil {
+			return nil, err
+		}
+		result[childNs] = stats
+	}
+	t.stats.set(ns, gen, result)
+	return result, nil
+}
@@ -0,0 +1,87 @@
+package tree
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/tree/node"
+	"github.com/lodastack/registry/tree/test_sample"
+)
+
+func TestNsStats(t *testing.T) {
+	s := test_sample.MustNewStore(t)
+	defer os.RemoveAll(s.Path())
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	tree, err := NewTree(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	teamNs := "team." + node.RootNode
+	webNs, dbNs := "web."+teamNs, "db."+teamNs
+	if _, err := tree.NewNode("team", "", node.RootNode, node.NonLeaf); err != nil {
+		t.Fatalf("create nonleaf fail: %s", err.Error())
+	}
+	for _, name := range []string{"web", "db"} {
+		if _, err := tree.NewNode(name, "", teamNs, node.Leaf); err != nil {
+			t.Fatalf("create leaf fail: %s", err.Error())
+		}
+	}
+	if err := tree.SetResource(webNs, model.Machine, model.ResourceList{
+		{"hostname": "web-1", model.HostStatusProp: model.Online},
+		{"hostname": "web-2", model.HostStatusProp: model.Dead},
+	}); err != nil {
+		t.Fatalf("set machine fail: %s", err.Error())
+	}
+	if err := tree.SetResource(dbNs, model.Machine, model.ResourceList{
+		{"hostname": "db-1", model.HostStatusProp: model.Online},
+	}); err != nil {
+		t.Fatalf("set machine fail: %s", err.Error())
+	}
+
+	stats, err := tree.NsStats(teamNs)
+	if err != nil {
+		t.Fatalf("get stats fail: %s", err.Error())
+	}
+	if len(stats) != 3 {
+		t.Fatalf("stats should have 3 ns: %+v", stats)
+	}
+	team, web := stats[teamNs], stats[webNs]
+	if web.Resources[model.Machine] != 2 || web.Machines[model.Dead] != 1 {
+		t.Fatalf("stats of %s not match with expect: %+v", webNs, web)
+	}
+	if team.Resources[model.Machine] != 3 || team.Machines[model.Online] != 2 || team.Machines[model.Dead] != 1 {
+		t.Fatalf("stats of %s not match with expect: %+v", teamNs, team)
+	}
+	if team.Alarms.Enabled+team.Alarms.Disabled != team.Resources[model.Alarm] ||
+		team.Resources[model.Alarm] != web.Resources[model.Alarm]+stats[dbNs].Resources[model.Alarm] {
+		t.Fatalf("alarm stats of %s not match with expect: %+v", teamNs, team)
+	}
+
+	// the cached stats is invalidated after resource change.
+	if err := tree.AppendResource(dbNs, model.Machine, model.Resource{"hostname": "db-2", model.HostStatusProp: model.Offline}); err != nil {
+		t.Fatalf("append machine fail: %s", err.Error())
+	}
+	if stats, err = tree.NsStats(teamNs); err != nil || stats[teamNs].Machines[model.Offline] != 1 {
+		t.Fatalf("stats not updated after resource change: %+v, %v", stats[teamNs], err)
+	}
+
+	// the node counts are rolled up from the stats of the leaves.
+	teamNode, err := tree.GetNodeByNS(teamNs)
+	if err != nil {
+		t.Fatalf("get node fail: %s", err.Error())
+	}
+	counts, err := tree.NodeCounts(teamNs, teamNode)
+	if err != nil {
+		t.Fatalf("get node counts fail: %s", err.Error())
+	}
+	if c := counts[teamNode.ID]; c.Leaves != 2 || c.Machines != 4 {
+		t.Fatalf("counts of %s not match with expect: %+v", teamNs, c)
+	}
+}
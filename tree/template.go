@@ -0,0 +1,251 @@
+package tree
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/lodastack/registry/common"
+	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/tree/node"
+
+	m "github.com/lodastack/store/model"
+)
+
+func isTemplate(resType string) bool {
+	return len(resType) > len(template) && strings.HasPrefix(resType, template)
+}
+
+// childResType return the resource type the template saved as in the child node.
+// Leaf child save the template as resource, NonLeaf child save it as template.
+func childResType(child *node.Node, tmplType string) string {
+	if child.IsLeaf() {
+		return tmplType[len(template):]
+	}
+	return tmplType
+}
+
+// inheritRecord return the pk-fingerprint map of the template resources propagated to the node.
+func (t *Tree) inheritRecord(nodeID, resType string) (map[string]string, error) {
+	record := map[string]string{}
+	v, err := t.getByteFromStore(nodeID, model.InheritPrefix+resType)
+	if err != nil || len(v) == 0 {
+		return record, err
+	}
+	err = json.Unmarshal(v, &record)
+	return record, err
+}
+
+func inheritRecordRow(nodeID, resType string, record map[string]string) (m.Row, error) {
+	v, err := json.Marshal(record)
+	if err != nil {
+		return m.Row{}, err
+	}
+	return m.Row{Bucket: []byte(nodeID), Key: []byte(model.InheritPrefix + resType), Value: v}, nil
+}
+
+// genInheritRecord return the inherit record of the template resources.
+func genInheritRecord(tmplType string, tmpl model.ResourceList) map[string]string {
+	pk := model.PkOfType(tmplType)
+	record := make(map[string]string, len(tmpl))
+	for _, r := range tmpl {
+		if pkValue, _ := r.ReadProperty(pk); pkValue != "" {
+			record[pkValue] = model.Fingerprint(tmplType, r)
+		}
+	}
+	return record
+}
+
+// leafAlarmTemplate return the alarm template as generated in the leaf ns, so that the
+// fingerprint of the template matches the leaf alarm which has the generated property.
+// The template resource which could not be generated is kept as it is.
+func leafAlarmTemplate(ns string, tmpl model.ResourceList) model.ResourceList {
+	generated := make(model.ResourceList, len(tmpl))
+	for i, r := range tmpl {
+		data := make(map[string]string, len(r))
+		for k, v := range r {
+			data[k] = v
+		}
+		alarm, err := GenAlarmFromTemplate(ns, data, "")
+		if err != nil {
+			generated[i] = r
+			continue
+		}
+		generated[i] = alarm
+	}
+	return generated
+}
+
+// propagate merge the template of parent into every child, and recurse into the NonLeaf child.
+func (t *Tree) propagate(parent *node.Node, parentNs, tmplType string, tmpl model.ResourceList, rows *[]m.Row, changes *[]model.ApplyChange) error {
+	for _, child := range parent.Children {
+		childNs := node.Join([]string{child.Name, parentNs})
+		resType := childResType(child, tmplType)
+
+		live, err := t.getResourceListByNodeID(child.ID, resType)
+		if err != nil {
+			return err
+		}
+		record, err := t.inheritRecord(child.ID, resType)
+		if err != nil {
+			t.logger.Errorf("read inherit record of ns %s type %s fail: %v", childNs, resType, err)
+		}
+		childTmpl := tmpl
+		if child.IsLeaf() && resType == model.Alarm {
+			childTmpl = leafAlarmTemplate(childNs, tmpl)
+		}
+		result, childChanges, newRecord, err := model.InheritTemplate(childNs, resType, childTmpl, live, record)
+		if err != nil {
+			t.logger.Errorf("propagate template %s to ns %s fail: %v", tmplType, childNs, err)
+			return err
+		}
+
+		changed := false
+		for i := range childChanges {
+			if childChanges[i].Action == model.InheritOverridden {
+				continue
+			}
+			changed = true
+			if resType != model.Alarm || childChanges[i].Action == model.ApplyDelete {
+				continue
+			}
+			// regenerate the alarm of the leaf as initResourceOrTemplate does.
+			for j := range result {
+				if result[j][model.PkProperty[model.Alarm]] != childChanges[i].Pk {
+					continue
+				}
+				id, _ := result[j].ID()
+				if result[j], err = GenAlarmFromTemplate(childNs, result[j], id); err != nil {
+					return err
+				}
+				childChanges[i].After = result[j]
+			}
+		}
+		*changes = append(*changes, childChanges...)
+
+		if changed {
+			resByte := []byte{}
+			if len(result) != 0 {
+				if resByte, err = result.Marshal(); err != nil {
+					return err
+				}
+			}
+			*rows = append(*rows, m.Row{Bucket: []byte(child.ID), Key: []byte(resType), Value: resByte})
+		}
+		recordRow, err := inheritRecordRow(child.ID, resType, newRecord)
+		if err != nil {
+			return err
+		}
+		*rows = append(*rows, recordRow)
+
+		if !child.IsLeaf() {
+			if err := t.propagate(child, childNs, tmplType, result, rows, changes); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (t *Tree) getResourceListByNodeID(nodeID, resType string) (model.ResourceList, error) {
+	rl := model.ResourceList{}
+	v, err := t.getByteFromStore(nodeID, resType)
+	if err != nil {
+		return nil, err
+	}
+	if err = rl.Unmarshal(v); err != nil && err != common.ErrEmptyResource {
+		return nil, err
+	}
+	return rl, nil
+}
+
+// PropagateTemplate propagate the template of the NonLeaf ns to all its descendants.
+// Template resource added/updated/removed since last propagate is added/updated/removed
+// in the descendants, resource overridden by the descendant is not changed and reported as overridden.
+// Return the changes without any write if dryRun.
+func (t *Tree) PropagateTemplate(ns, tmplType string, dryRun bool) ([]model.ApplyChange, error) {
+	t.Mu.Lock()
+	defer t.Mu.Unlock()
+	return t.propagateTemplate(ns, tmplType, dryRun)
+}
+
+// propagateTemplate read the template and the descendants, diff and write them in one batch.
+// The caller should hold the tree lock.
+func (t *Tree) propagateTemplate(ns, tmplType string, dryRun bool) ([]model.ApplyChange, error) {
+	changes := []model.ApplyChange{}
+	if !isTemplate(tmplType) || model.PkOfType(tmplType) == "" {
+		return changes, common.ErrInvalidParam
+	}
+	n, err := t.GetNodeByNS(ns)
+	if err != nil {
+		return changes, err
+	}
+	if n.IsLeaf() {
+		return changes, common.ErrInvalidParam
+	}
+	tmpl, err := t.getResourceListByNodeID(n.ID, tmplType)
+	if err != nil {
+		return changes, err
+	}
+
+	rows := []m.Row{}
+	if err := t.propagate(n, ns, tmplType, tmpl, &rows, &changes); err != nil {
+		return changes, err
+	}
+	if dryRun || len(rows) == 0 {
+		return changes, nil
+	}
+	if err := t.cluster.Batch(rows); err != nil {
+		t.logger.Errorf("propagate template %s of ns %s fail: %s", tmplType, ns, err.Error())
+		return changes, err
+	}
+	return changes, nil
+}
+
+// propagateIfTemplate propagate the template after it changed.
+func (t *Tree) propagateIfTemplate(ns, resType string) {
+	if !isTemplate(resType) || model.PkOfType(resType) == "" {
+		return
+	}
+	if _, err := t.PropagateTemplate(ns, resType, false); err != nil {
+		t.logger.Errorf("propagate template %s of ns %s fail: %s", resType, ns, err.Error())
+	}
+}
+
+// EffectiveResource return the resource list of the ns annotated with its origin,
+// local or inherited from the template of the ancestor, and whether it diverges from the template.
+func (t *Tree) EffectiveResource(ns, resType string) ([]model.EffectiveResource, error) {
+	if model.PkOfType(resType) == "" {
+		return nil, common.ErrInvalidParam
+	}
+	n, err := t.GetNodeByNS(ns)
+	if err != nil {
+		return nil, err
+	}
+	rl, err := t.getResourceListByNodeID(n.ID, resType)
+	if err != nil {
+		return nil, err
+	}
+	tmplType := resType
+	if !isTemplate(tmplType) {
+		tmplType = template + resType
+	}
+
+	ancestors := []model.AncestorTemplate{}
+	elems := node.Split(ns)
+	for i := 1; i < len(elems); i++ {
+		ancestorNs := node.Join(elems[i:])
+		ancestor, err := t.GetNodeByNS(ancestorNs)
+		if err != nil {
+			return nil, err
+		}
+		tmpl, err := t.getResourceListByNodeID(ancestor.ID, tmplType)
+		if err != nil {
+			return nil, err
+		}
+		if n.IsLeaf() && resType == model.Alarm {
+			tmpl = leafAlarmTemplate(ns, tmpl)
+		}
+		ancestors = append(ancestors, model.AncestorTemplate{NS: ancestorNs, Template: tmpl})
+	}
+	return model.EffectiveResourceList(resType, rl, ancestors)
+}
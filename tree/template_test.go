@@ -0,0 +1,174 @@
+package tree
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/tree/node"
+	"github.com/lodastack/registry/tree/test_sample"
+)
+
+func TestPropagateTemplate(t *testing.T) {
+	s := test_sample.MustNewStore(t)
+	defer os.RemoveAll(s.Path())
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	tree, err := NewTree(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	productNs := "product." + node.RootNode
+	leafNs := "web." + productNs
+	tmplType := template + model.Collect
+	if _, err := tree.NewNode("product", "", node.RootNode, node.NonLeaf); err != nil {
+		t.Fatalf("create nonleaf fail: %s", err.Error())
+	}
+	if _, err := tree.NewNode("web", "", productNs, node.Leaf); err != nil {
+		t.Fatalf("create leaf fail: %s", err.Error())
+	}
+	tmpl, err := tree.GetResourceList(productNs, tmplType)
+	if err != nil {
+		t.Fatalf("get template fail: %s", err.Error())
+	}
+	base := len(*tmpl)
+
+	// case 1: template added after the leaf created reach the leaf.
+	if err := tree.AppendResource(productNs, tmplType, model.Resource{"name": "PORT.test.8080", "interval": "60"}); err != nil {
+		t.Fatalf("append template fail: %s", err.Error())
+	}
+	rl, _ := tree.GetResourceList(leafNs, model.Collect)
+	if len(*rl) != base+1 {
+		t.Fatalf("template not propagated to leaf, leaf collect: %+v", *rl)
+	}
+
+	// case 2: leaf override the resource, template update not clobber it.
+	res, _ := tree.GetResourceList(leafNs, model.Collect)
+	var leafID string
+	for _, r := range *res {
+		if r["name"] == "PORT.test.8080" {
+			leafID, _ = r.ID()
+		}
+	}
+	if err := tree.UpdateResource(leafNs, model.Collect, leafID, map[string]string{"interval": "10"}); err != nil {
+		t.Fatalf("update leaf collect fail: %s", err.Error())
+	}
+	tmpl, _ = tree.GetResourceList(productNs, tmplType)
+	for i := range *tmpl {
+		if (*tmpl)[i]["name"] == "PORT.test.8080" {
+			(*tmpl)[i]["interval"] = "30"
+		}
+	}
+	changes, err := tree.PropagateTemplate(productNs, tmplType, true)
+	if err != nil || len(changes) != 1 || changes[0].Action != model.InheritOverridden {
+		t.Fatalf("preview not match with expect, changes: %+v, error: %v", changes, err)
+	}
+	if err := tree.SetResource(productNs, tmplType, *tmpl); err != nil {
+		t.Fatalf("set template fail: %s", err.Error())
+	}
+	if r, _ := tree.GetResource(leafNs, model.Collect, leafID); len(r) != 1 || r[0]["interval"] != "10" {
+		t.Fatalf("overridden resource clobbered: %+v", r)
+	}
+
+	// case 3: resource not overridden follow the template removal.
+	if _, err := tree.NewNode("api", "", productNs, node.Leaf); err != nil {
+		t.Fatalf("create leaf fail: %s", err.Error())
+	}
+	apiNs := "api." + productNs
+	if rl, _ = tree.GetResourceList(apiNs, model.Collect); len(*rl) != base+1 {
+		t.Fatalf("new leaf not inherit the template: %+v", *rl)
+	}
+	tmpl, _ = tree.GetResourceList(productNs, tmplType)
+	for i := range *tmpl {
+		if (*tmpl)[i]["name"] == "PORT.test.8080" {
+			*tmpl = append((*tmpl)[:i], (*tmpl)[i+1:]...)
+			break
+		}
+	}
+	if err := tree.SetResource(productNs, tmplType, *tmpl); err != nil {
+		t.Fatalf("set template fail: %s", err.Error())
+	}
+	if rl, _ = tree.GetResourceList(apiNs, model.Collect); len(*rl) != base {
+		t.Fatalf("template removal not propagated: %+v", *rl)
+	}
+	if rl, _ = tree.GetResourceList(leafNs, model.Collect); len(*rl) != base+1 {
+		t.Fatalf("overridden resource removed: %+v", *rl)
+	}
+}
+
+func TestPropagateAlarmTemplate(t *testing.T) {
+	s := test_sample.MustNewStore(t)
+	defer os.RemoveAll(s.Path())
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	tree, err := NewTree(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	productNs := "product." + node.RootNode
+	leafNs := "web." + productNs
+	tmplType := template + model.Alarm
+	if _, err := tree.NewNode("product", "", node.RootNode, node.NonLeaf); err != nil {
+		t.Fatalf("create nonleaf fail: %s", err.Error())
+	}
+	if _, err := tree.NewNode("web", "", productNs, node.Leaf); err != nil {
+		t.Fatalf("create leaf fail: %s", err.Error())
+	}
+	alarm := model.Resource{"name": "cpu.idle < 10", "trigger": "threshold", "every": "1m", "period": "1m",
+		"measurement": "cpu.idle", "func": "mean", "expression": "<", "value": "10", "level": "2", "alert": "sms"}
+	if err := tree.AppendResource(productNs, tmplType, alarm); err != nil {
+		t.Fatalf("append template fail: %s", err.Error())
+	}
+
+	leafAlarm := func() model.Resource {
+		rl, _ := tree.GetResourceList(leafNs, model.Alarm)
+		for _, r := range *rl {
+			if r["name"] == "cpu.idle < 10" {
+				return r
+			}
+		}
+		t.Fatalf("template not propagated to leaf, leaf alarm: %+v", *rl)
+		return nil
+	}
+	notDiverged := func() {
+		effective, err := tree.EffectiveResource(leafNs, model.Alarm)
+		if err != nil {
+			t.Fatalf("get effective resource fail: %s", err.Error())
+		}
+		for _, e := range effective {
+			if e.Resource["name"] == "cpu.idle < 10" && (e.Origin != model.OriginInherited || e.Diverged) {
+				t.Fatalf("propagated alarm should be inherited and not diverged: %+v", e)
+			}
+		}
+	}
+	if r := leafAlarm(); r["db"] != "collect."+leafNs {
+		t.Fatalf("leaf alarm not generated in the leaf ns: %+v", r)
+	}
+	notDiverged()
+
+	// update the template twice, the leaf follow both.
+	for _, value := range []string{"20", "30"} {
+		tmpl, _ := tree.GetResourceList(productNs, tmplType)
+		for i := range *tmpl {
+			if (*tmpl)[i]["name"] == "cpu.idle < 10" {
+				(*tmpl)[i]["value"] = value
+			}
+		}
+		if err := tree.SetResource(productNs, tmplType, *tmpl); err != nil {
+			t.Fatalf("set template fail: %s", err.Error())
+		}
+		if r := leafAlarm(); r["value"] != value {
+			t.Fatalf("template update to %s not propagated, leaf alarm: %+v", value, r)
+		}
+		notDiverged()
+	}
+}
@@ -0,0 +1,234 @@
+package tree
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/lodastack/registry/common"
+	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/tree/node"
+
+	m "github.com/lodastack/store/model"
+)
+
+const (
+	// trashBucket save the removed ns until purged.
+	trashBucket = "trash"
+)
+
+func (t *Tree) initTrashBucket() error {
+	if err := t.cluster.CreateBucketIfNotExist([]byte(trashBucket)); err != nil {
+		t.logger.Errorf("tree init %s CreateBucketIfNotExist fail: %s", trashBucket, err.Error())
+		return err
+	}
+	return nil
+}
+
+// TrashNode remove the ns from the tree and keep it in trash for retention,
+// the buckets of the node are not removed until purged.
+// groups is the removed groups of the ns, extraRows (e.g. the rows remove the groups)
+// are written with the tree and the trash in one batch.
+func (t *Tree) TrashNode(ns, operator string, retention time.Duration, groups []byte, extraRows ...m.Row) (model.Trash, error) {
+	trash := model.Trash{}
+	parentNs, err := getParentNS(ns)
+	if err != nil {
+		t.logger.Errorf("remove ns fail because the ns is root node or invalid, ns: %s", ns)
+		return trash, err
+	}
+	if err := t.allowRemoveNS(ns); err != nil {
+		return trash, err
+	}
+
+	t.Mu.Lock()
+	defer t.Mu.Unlock()
+	allNodes, err := t.AllNodes()
+	if err != nil {
+		t.logger.Error("get all nodes error when TrashNode")
+		return trash, err
+	}
+	removed, err := allNodes.GetByNS(ns)
+	if err != nil {
+		return trash, err
+	}
+	parent, err := allNodes.GetByNS(parentNs)
+	if err != nil {
+		return trash, err
+	}
+	if err := parent.RemoveChildNode(removed.ID); err != nil {
+		t.logger.Errorf("delete node fail, parent ns: %s, delete ID: %s, error: %s", parentNs, removed.ID, err.Error())
+		return trash, err
+	}
+	trash, row, err := trashRow(removed, ns, operator, retention, groups)
+	if err != nil {
+		return trash, err
+	}
+	rows := append([]m.Row{row}, extraRows...)
+	if err := t.batchWithTree(allNodes, rows); err != nil {
+		t.logger.Errorf("move ns %s to trash fail: %s", ns, err.Error())
+		return trash, err
+	}
+	t.logger.Infof("move ns %s to trash %s success", ns, trash.ID)
+	return trash, nil
+}
+
+// trashRow return the trash of the removed ns and the row which saves it.
+func trashRow(removed *node.Node, ns, operator string, retention time.Duration, groups []byte) (model.Trash, m.Row, error) {
+	nodeByte, err := removed.MarshalJSON()
+	if err != nil {
+		return model.Trash{}, m.Row{}, err
+	}
+	now := time.Now()
+	trash := model.Trash{
+		ID:        common.GenUUID(),
+		NS:        ns,
+		Node:      nodeByte,
+		Groups:    groups,
+		Operator:  operator,
+		DeletedAt: now,
+		ExpireAt:  now.Add(retention),
+	}
+	removed.Walk(func(n *node.Node, _ map[string]string) (map[string]string, error) {
+		trash.NodeIDs = append(trash.NodeIDs, n.ID)
+		return nil, nil
+	})
+	trashByte, err := json.Marshal(trash)
+	if err != nil {
+		return trash, m.Row{}, err
+	}
+	return trash, m.Row{Bucket: []byte(trashBucket), Key: []byte(trash.ID), Value: trashByte}, nil
+}
+
+// ListTrash return the removed ns in trash, the latest removed first.
+func (t *Tree) ListTrash() ([]model.Trash, error) {
+	trashMap, err := t.cluster.ViewPrefix([]byte(trashBucket), []byte{})
+	if err != nil {
+		return nil, err
+	}
+	trashList := make([]model.Trash, 0, len(trashMap))
+	for _, v := range trashMap {
+		if len(v) == 0 {
+			continue
+		}
+		trash := model.Trash{}
+		if err := json.Unmarshal(v, &trash); err != nil {
+			return nil, err
+		}
+		trashList = append(trashList, trash)
+	}
+	sort.Slice(trashList, func(i, j int) bool {
+		return trashList[i].DeletedAt.After(trashList[j].DeletedAt)
+	})
+	return trashList, nil
+}
+
+// GetTrash return the trash by ID.
+func (t *Tree) GetTrash(id string) (model.Trash, error) {
+	trash := model.Trash{}
+	v, err := t.cluster.View([]byte(trashBucket), []byte(id))
+	if err != nil {
+		return trash, err
+	}
+	if len(v) == 0 {
+		return trash, common.ErrTrashNotFound
+	}
+	err = json.Unmarshal(v, &trash)
+	return trash, err
+}
+
+// RestoreTrash put the removed ns back to its parent, return the ns restored.
+// extraRows (e.g. the rows restore the groups) are written with the tree in one batch.
+func (t *Tree) RestoreTrash(id string, extraRows ...m.Row) (string, error) {
+	trash, err := t.GetTrash(id)
+	if err != nil {
+		return "", err
+	}
+	parentNs, err := getParentNS(trash.NS)
+	if err != nil {
+		return "", err
+	}
+
+	t.Mu.Lock()
+	defer t.Mu.Unlock()
+	allNodes, err := t.AllNodes()
+	if err != nil {
+		t.logger.Error("get all nodes error when RestoreTrash")
+		return "", err
+	}
+	if allNodes.Exist(trash.NS) {
+		return "", common.ErrNodeAlreadyExist
+	}
+	parent, err := allNodes.GetByNS(parentNs)
+	if err != nil {
+		t.logger.Errorf("restore ns %s fail, parent not found: %v", trash.NS, err)
+		return "", err
+	}
+	if parent.IsLeaf() {
+		return "", common.ErrCreateNodeUnderLeaf
+	}
+	restored := &node.Node{}
+	if err := restored.UnmarshalJSON(trash.Node); err != nil {
+		return "", err
+	}
+	parent.Children = append(parent.Children, restored)
+	rows := []m.Row{{Bucket: []byte(trashBucket), Key: []byte(id), Value: []byte{}}}
+	rows = append(rows, extraRows...)
+	if err := t.batchWithTree(allNodes, rows); err != nil {
+		t.logger.Errorf("restore ns %s fail: %s", trash.NS, err.Error())
+		return "", err
+	}
+	t.logger.Infof("restore ns %s from trash %s success", trash.NS, id)
+	return trash.NS, nil
+}
+
+// PurgeTrash remove the trash and the buckets of its nodes.
+// The data in the buckets is cleared with the trash in one batch,
+// the empty buckets are dropped after.
+func (t *Tree) PurgeTrash(id string) error {
+	trash, err := t.GetTrash(id)
+	if err != nil {
+		return err
+	}
+	rows := []m.Row{{Bucket: []byte(trashBucket), Key: []byte(id), Value: []byte{}}}
+	for _, nodeID := range trash.NodeIDs {
+		kv, err := t.cluster.ViewPrefix([]byte(nodeID), []byte{})
+		if err != nil {
+			t.logger.Errorf("read bucket %s of ns %s fail: %s", nodeID, trash.NS, err.Error())
+			continue
+		}
+		for k := range kv {
+			rows = append(rows, m.Row{Bucket: []byte(nodeID), Key: []byte(k), Value: []byte{}})
+		}
+	}
+	if err := t.cluster.Batch(rows); err != nil {
+		t.logger.Errorf("purge trash %s fail: %s", id, err.Error())
+		return err
+	}
+	for _, nodeID := range trash.NodeIDs {
+		if err := t.removeNodeResourceFromStore(nodeID); err != nil {
+			t.logger.Errorf("remove bucket %s of ns %s fail: %s", nodeID, trash.NS, err.Error())
+		}
+	}
+	t.logger.Infof("purge ns %s from trash %s success", trash.NS, id)
+	return nil
+}
+
+// PurgeExpiredTrash purge the trash expired, return the number of trash purged.
+func (t *Tree) PurgeExpiredTrash() (int, error) {
+	trashList, err := t.ListTrash()
+	if err != nil {
+		return 0, err
+	}
+	var purged int
+	now := time.Now()
+	for _, trash := range trashList {
+		if trash.ExpireAt.After(now) {
+			continue
+		}
+		if err := t.PurgeTrash(trash.ID); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
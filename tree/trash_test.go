@@ -0,0 +1,64 @@
+package tree
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/lodastack/registry/common"
+	"github.com/lodastack/registry/model"
+	"github.com/lodastack/registry/tree/node"
+	"github.com/lodastack/registry/tree/test_sample"
+)
+
+func TestTrashNode(t *testing.T) {
+	s := test_sample.MustNewStore(t)
+	defer os.RemoveAll(s.Path())
+	if err := s.Open(true); err != nil {
+		t.Fatalf("failed to open single-node store: %s", err.Error())
+	}
+	defer s.Close(true)
+	s.WaitForLeader(10 * time.Second)
+	tree, err := NewTree(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leafNs := "web." + node.RootNode
+	if _, err := tree.NewNode("web", "", node.RootNode, node.Leaf); err != nil {
+		t.Fatalf("create leaf fail: %s", err.Error())
+	}
+	before, _ := tree.GetResourceList(leafNs, model.Alarm)
+
+	// case 1: trash and restore.
+	trash, err := tree.TrashNode(leafNs, "user1", time.Hour, nil)
+	if err != nil {
+		t.Fatalf("trash node fail: %s", err.Error())
+	}
+	if _, err := tree.GetNodeByNS(leafNs); err == nil {
+		t.Fatal("ns still exist after trash")
+	}
+	if trashList, err := tree.ListTrash(); err != nil || len(trashList) != 1 || trashList[0].NS != leafNs {
+		t.Fatalf("list trash not match with expect: %+v, %v", trashList, err)
+	}
+	if ns, err := tree.RestoreTrash(trash.ID); err != nil || ns != leafNs {
+		t.Fatalf("restore trash fail: %s, %v", ns, err)
+	}
+	if alarms, err := tree.GetResourceList(leafNs, model.Alarm); err != nil || len(*alarms) != len(*before) {
+		t.Fatalf("resource after restore not match with expect: %v, %v", alarms, err)
+	}
+	if _, err := tree.GetTrash(trash.ID); err != common.ErrTrashNotFound {
+		t.Fatalf("trash still exist after restore: %v", err)
+	}
+
+	// case 2: purge the expired trash.
+	if _, err := tree.TrashNode(leafNs, "user1", -time.Hour, nil); err != nil {
+		t.Fatalf("trash node fail: %s", err.Error())
+	}
+	if purged, err := tree.PurgeExpiredTrash(); err != nil || purged != 1 {
+		t.Fatalf("purge expired trash not match with expect: %d, %v", purged, err)
+	}
+	if trashList, _ := tree.ListTrash(); len(trashList) != 0 {
+		t.Fatalf("trash not purged: %+v", trashList)
+	}
+}
@@ -13,6 +13,8 @@ import (
 	"github.com/lodastack/registry/tree/machine"
 	"github.com/lodastack/registry/tree/node"
 	"github.com/lodastack/registry/tree/resource"
+
+	m "github.com/lodastack/store/model"
 )
 
 var (
@@ -32,6 +34,7 @@ type Tree struct {
 	node     node.Inf
 	resource resource.Inf
 	machine  machine.Inf
+	stats    *statsCache
 	Mu       sync.RWMutex
 
 	reports ReportInfo
@@ -39,18 +42,21 @@ type Tree struct {
 }
 
 // NewTree return Tree obj.
-func NewTree(cluster cluster.Inf) (*Tree, error) {
+func NewTree(c cluster.Inf) (*Tree, error) {
+	stats := newStatsCache()
+	cluster := &watchedCluster{Inf: c, stats: stats}
 	nodeInf := node.NewNode(cluster)
 	logger := log.New(config.C.LogConf.Level, "tree", model.LogBackend)
 	r := resource.NewResource(cluster, nodeInf, logger)
 	t := Tree{
 		Nodes: &node.Node{
-			node.NodeProperty{ID: rootNodeID, Name: node.RootNode, Type: node.NonLeaf, MachineReg: node.NotMatchMachine},
-			[]*node.Node{}},
+			NodeProperty: node.NodeProperty{ID: rootNodeID, Name: node.RootNode, Type: node.NonLeaf, MachineReg: node.NotMatchMachine},
+			Children:     []*node.Node{}},
 		cluster:  cluster,
 		node:     nodeInf,
 		resource: r,
 		machine:  machine.NewMachine(nodeInf, r, logger),
+		stats:    stats,
 		Mu:       sync.RWMutex{},
 		logger:   logger,
 		reports:  ReportInfo{sync.RWMutex{}, make(map[string]model.Report)},
@@ -63,6 +69,12 @@ func (t *Tree) init() error {
 	if err := t.initNodeBucket(); err != nil {
 		return err
 	}
+	if err := t.initApplyBucket(); err != nil {
+		return err
+	}
+	if err := t.initTrashBucket(); err != nil {
+		return err
+	}
 	return t.initReportBucket()
 }
 
@@ -72,8 +84,8 @@ func (t *Tree) initNodeBucket() error {
 		t.logger.Errorf("tree %s CreateBucketIfNotExist fail: %s", node.NodeDataBucketID, err.Error())
 		return err
 	}
-	if err := t.initNodeData(node.NodeDataKey); err != nil {
-		t.logger.Error("init nodeDataKey fail:", err.Error())
+	if err := t.initNodeData(); err != nil {
+		t.logger.Error("init node data fail:", err.Error())
 		return err
 	}
 
@@ -81,16 +93,17 @@ func (t *Tree) initNodeBucket() error {
 }
 
 // initialization tree node data and if empty.
-func (t *Tree) initNodeData(key string) error {
-	v, err := t.cluster.View([]byte(node.NodeDataBucketID), []byte(key))
+// The tree saved as one blob by old version is migrated to node records.
+func (t *Tree) initNodeData() error {
+	inited, err := t.node.Init()
 	if err != nil {
 		return err
 	}
-	if len(v) != 0 {
+	if inited {
 		return nil
 	}
 
-	t.logger.Info(key, "is not inited, begin to init")
+	t.logger.Info("node data is not inited, begin to init")
 
 	// Create rootNode map/bucket and init template.
 	if _, err := t.NewNode(node.RootNode, "", "-", node.Root); err != nil {
@@ -164,13 +177,25 @@ func (t *Tree) initReportBucket() error {
 
 // Save Nodes to store.
 func (t *Tree) saveTree() error {
-	treeByte, err := t.Nodes.MarshalJSON()
-	if err != nil {
+	if err := t.node.Save(t.Nodes); err != nil {
 		t.logger.Errorf("Tree save fail: %s\n", err.Error())
 		return err
 	}
-	// TODO: purge cache or not
-	return t.node.Save(treeByte)
+	return nil
+}
+
+// batchWithTree save the changed nodes of allNodes with the rows in one batch.
+func (t *Tree) batchWithTree(allNodes *node.Node, rows []m.Row) error {
+	treeRows, version, err := t.node.Rows(allNodes)
+	if err != nil {
+		t.logger.Errorf("Tree save fail: %s", err.Error())
+		return err
+	}
+	if err := t.cluster.Batch(append(treeRows, rows...)); err != nil {
+		return err
+	}
+	t.Nodes = allNodes
+	return t.node.Commit(allNodes, version)
 }
 
 // Create bucket for node.
@@ -305,8 +330,8 @@ func (t *Tree) RemoveNode(ns string) error {
 // First property argument is used as the machineReg.
 func (t *Tree) NewNode(name, comment, parentNs string, nodeType int, machineRegistRule ...string) (string, error) {
 	newNode := node.Node{
-		node.NodeProperty{},
-		[]*node.Node{},
+		NodeProperty: node.NodeProperty{},
+		Children:     []*node.Node{},
 	}
 	newNode.Comment = comment
 	if nodeType == node.Root {
@@ -446,6 +471,26 @@ func (t *Tree) initResourceOrTemplate(newNode node.Node, nodeType int, parentNs,
 			t.logger.Errorf("SetResourceByNs fail when newnode %s, error: %s", newNode.ID, err.Error())
 			return err
 		}
+		if err = t.initInheritRecord(newNode.ID, templateName, resourceName, templateValue); err != nil {
+			t.logger.Errorf("init inherit record fail when newnode %s, error: %s", newNode.ID, err.Error())
+			return err
+		}
 	}
 	return err
 }
+
+// initInheritRecord record the template resources the new node inherit from its parent.
+func (t *Tree) initInheritRecord(nodeID, templateName, resourceName string, templateValue []byte) error {
+	if model.PkOfType(templateName) == "" {
+		return nil
+	}
+	rl := model.ResourceList{}
+	if err := rl.Unmarshal(templateValue); err != nil && err != common.ErrEmptyResource {
+		return err
+	}
+	row, err := inheritRecordRow(nodeID, resourceName, genInheritRecord(templateName, rl))
+	if err != nil {
+		return err
+	}
+	return t.setByteToStore(nodeID, string(row.Key), row.Value)
+}